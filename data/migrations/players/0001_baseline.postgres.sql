@@ -0,0 +1,13 @@
+-- The player tables as they were before the server kept track of its schema version.  Databases that predate
+-- schema_version already have all of this, and are adopted at this version instead of having it applied.
+CREATE SEQUENCE IF NOT EXISTS id;
+CREATE TABLE IF NOT EXISTS player(id integer DEFAULT nextval('id') PRIMARY KEY, username text, userhash bigint, password text, x integer, y integer, group_id integer);
+CREATE TABLE IF NOT EXISTS appearance(playerid integer, haircolour integer, topcolour integer, trousercolour integer, skincolour integer, head integer, body integer);
+CREATE TABLE IF NOT EXISTS stats(playerid integer, num integer, cur integer, exp integer);
+CREATE TABLE IF NOT EXISTS inventory(playerid integer, itemid integer, amount bigint, wielded boolean);
+CREATE TABLE IF NOT EXISTS bank(playerid integer, itemid integer, amount bigint);
+CREATE TABLE IF NOT EXISTS contacts(playerid integer, playerhash bigint, type text);
+CREATE TABLE IF NOT EXISTS player_attr(player_id integer, name text, value text);
+CREATE TABLE IF NOT EXISTS recovery_questions(userhash bigint, question1 text, question2 text, question3 text, question4 text, question5 text, answer1 text, answer2 text, answer3 text, answer4 text, answer5 text);
+CREATE TABLE IF NOT EXISTS ban(userhash bigint, address text, reason text, moderator text, issued bigint, expires bigint);
+CREATE TABLE IF NOT EXISTS login_history(userhash bigint, time bigint, ip text, version integer, websocket boolean, reason text);
@@ -0,0 +1,2 @@
+-- Tracks who is online, for OnlineCount and the website.
+ALTER TABLE player ADD COLUMN IF NOT EXISTS loggedIn boolean NOT NULL DEFAULT FALSE;
@@ -0,0 +1,11 @@
+-- Saves only write the slots that changed, so items need to know which slot they are in, rather than relying on the
+-- order they were inserted in.
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS position integer;
+ALTER TABLE bank ADD COLUMN IF NOT EXISTS position integer;
+UPDATE inventory SET position=slots.position FROM (SELECT ctid, row_number() OVER (PARTITION BY playerid ORDER BY ctid) - 1 AS position FROM inventory) AS slots WHERE inventory.ctid=slots.ctid;
+UPDATE bank SET position=slots.position FROM (SELECT ctid, row_number() OVER (PARTITION BY playerid ORDER BY ctid) - 1 AS position FROM bank) AS slots WHERE bank.ctid=slots.ctid;
+CREATE INDEX IF NOT EXISTS inventory_slot ON inventory(playerid, position);
+CREATE INDEX IF NOT EXISTS bank_slot ON bank(playerid, position);
+CREATE INDEX IF NOT EXISTS player_attr_name ON player_attr(player_id, name);
+CREATE INDEX IF NOT EXISTS contacts_player ON contacts(playerid, type);
+CREATE INDEX IF NOT EXISTS stats_skill ON stats(playerid, num);
@@ -0,0 +1,10 @@
+-- Saves only write the slots that changed, so items need to know which slot they are in, rather than relying on the
+-- order they were inserted in.  The bank already had a position column, that nothing used.
+ALTER TABLE inventory ADD COLUMN position integer;
+UPDATE inventory SET position=(SELECT COUNT(*) FROM inventory AS i WHERE i.playerid=inventory.playerid AND i.rowid < inventory.rowid);
+UPDATE bank SET position=(SELECT COUNT(*) FROM bank AS b WHERE b.playerid=bank.playerid AND b.rowid < bank.rowid);
+CREATE INDEX IF NOT EXISTS inventory_slot ON inventory(playerid, position);
+CREATE INDEX IF NOT EXISTS bank_slot ON bank(playerid, position);
+CREATE INDEX IF NOT EXISTS player_attr_name ON player_attr(player_id, name);
+CREATE INDEX IF NOT EXISTS contacts_player ON contacts(playerid, type);
+CREATE INDEX IF NOT EXISTS stats_skill ON stats(playerid, num);
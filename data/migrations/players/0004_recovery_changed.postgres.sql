@@ -0,0 +1,3 @@
+-- Keeps track of when recovery questions were set, so the welcome box can warn about questions set recently.
+-- Databases made from the baseline before this migration existed already have it.
+ALTER TABLE recovery_questions ADD COLUMN IF NOT EXISTS changed bigint;
@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spkaeros/rscgo/pkg/game/headless"
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/capture"
+	"github.com/spkaeros/rscgo/pkg/game/net/packets"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+)
+
+const usage = `Usage: go run pkg/capture.go [flags] <print|replay> <capture file>
+
+print	Pretty-prints every packet in the capture, with its name and decoded fields where it has a schema.
+replay	Boots a headless server, logs a fresh account in, and sends it every packet that the captured client sent,
+	spaced out over as many ticks as they were originally.  Everything the server sends back gets printed.
+
+Flags:
+`
+
+var (
+	packetsFile = flag.String("packets", "data/packets.toml", "the packets file to take packet names from")
+	full        = flag.Bool("full", false, "print whole payloads, rather than only the first 32 bytes")
+	user        = flag.String("user", "replay", "the account to log in as when replaying")
+)
+
+//inboundNames The names of the packets that clients send, by opcode.  Names from the packets file win over the
+// logical names in the protocol package.
+var inboundNames, outboundNames [256]string
+
+func loadNames() {
+	for name, opcode := range protocol.Inbound {
+		inboundNames[opcode] = name
+	}
+	for name, opcode := range protocol.Outbound {
+		outboundNames[opcode] = name
+	}
+	var file struct {
+		Packets []struct {
+			Name   string `toml:"name"`
+			Opcode int    `toml:"opcode"`
+		} `toml:"packets"`
+	}
+	if _, err := toml.DecodeFile(*packetsFile, &file); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not read packet names from", *packetsFile+":", err)
+		return
+	}
+	for _, p := range file.Packets {
+		inboundNames[byte(p.Opcode)] = p.Name
+	}
+}
+
+//describe Formats one packet for printing.
+func describe(elapsed time.Duration, inbound bool, opcode byte, payload []byte) string {
+	direction, name := "->", outboundNames[opcode]
+	if inbound {
+		direction, name = "<-", inboundNames[opcode]
+	}
+	if name == "" {
+		name = "unknown"
+	}
+	line := fmt.Sprintf("%10.3fs %s %3d %-22s", elapsed.Seconds(), direction, opcode, name)
+	if inbound {
+		if m, err := packets.Decode(opcode, net.NewPacket(opcode, payload)); err != nil {
+			return line + " MALFORMED: " + err.Error()
+		} else if m != nil {
+			return line + fmt.Sprintf(" %+v", m)
+		}
+	}
+	if len(payload) > 32 && !*full {
+		return line + fmt.Sprintf(" [% x ...] (%d bytes)", payload[:32], len(payload))
+	}
+	return line + fmt.Sprintf(" [% x]", payload)
+}
+
+func printCapture(r *capture.Reader) error {
+	fmt.Printf("Capture of %s (revision %d), started %s\n", r.Header.Username, r.Header.Version, r.Header.Started.Format(time.RFC1123))
+	for {
+		f, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(describe(f.Time.Sub(r.Header.Started), f.Inbound, f.Opcode, f.Payload))
+	}
+}
+
+func replay(r *capture.Reader) error {
+	s, err := headless.Boot()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	c, err := s.Login(*user, *user)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	started := time.Now()
+	flush := func() {
+		for _, p := range c.Received() {
+			fmt.Println(describe(time.Since(started), false, p.Opcode, p.FrameBuffer))
+		}
+	}
+	flush()
+	// the first inbound frame gets sent straight away, and every one after it that many ticks after the last
+	var last time.Time
+	for {
+		f, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !f.Inbound {
+			continue
+		}
+		if !last.IsZero() {
+			if ticks := int(f.Time.Sub(last) / world.TickMillis); ticks > 0 {
+				s.Advance(ticks)
+				flush()
+			}
+		}
+		last = f.Time
+		fmt.Println(describe(time.Since(started), true, f.Opcode, f.Payload))
+		if err := c.Send(net.NewEmptyPacket(f.Opcode).AddBytes(f.Payload)); err != nil {
+			return err
+		}
+	}
+	s.Advance(2)
+	flush()
+	return nil
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	r, err := capture.Open(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Could not open capture:", err)
+		os.Exit(1)
+	}
+	defer r.Close()
+	loadNames()
+
+	switch flag.Arg(0) {
+	case "print":
+		err = printCapture(r)
+	case "replay":
+		err = replay(r)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Capture", flag.Arg(0), "failed:", err)
+		os.Exit(1)
+	}
+}
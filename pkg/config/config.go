@@ -1,19 +1,56 @@
 package config
 
+import "time"
+
 //TomlConfig A data structure representing the RSCGo TOML configuration file.
 var TomlConfig struct {
-	DataDir           string `toml:"data_directory"`
-	DbioDefs          string `toml:"dbio_defs"`
-	Version           int    `toml:"version"`
-	Port              int    `toml:"port"`
-	MaxPlayers        int    `toml:"max_players"`
-	PacketHandlerFile string `toml:"packet_handler_table"`
+	DataDir           string   `toml:"data_directory"`
+	DbioDefs          string   `toml:"dbio_defs"`
+	Version           int      `toml:"version"`
+	OpcodeCipher      bool     `toml:"opcode_cipher"`
+	Port              int      `toml:"port"`
+	TCPPort           int      `toml:"tcpPort"`
+	MaxPlayers        int      `toml:"max_players"`
+	PacketHandlerFile string   `toml:"packet_handler_table"`
+	RevisionFile      string   `toml:"revisions"`
+	AutosaveInterval  int      `toml:"autosave_interval"`
+	MetricsAddress    string   `toml:"metrics_address"`
+	ReconnectWindow   int      `toml:"reconnect_window"`
+	ReservedNames     []string `toml:"reserved_names"`
+	CaptureDirectory  string   `toml:"capture_directory"`
 	Database          struct {
 		PlayerDriver string `toml:"player_driver"`
 		WorldDriver  string `toml:"world_driver"`
 		PlayerDB     string `toml:"player_db"`
 		WorldDB      string `toml:"world_db"`
+		PlayerJournal string `toml:"player_journal"`
 	} `toml:"database"`
+	TLS struct {
+		Enabled bool   `toml:"enabled"`
+		Cert    string `toml:"cert"`
+		Key     string `toml:"key"`
+	} `toml:"tls"`
+	Throttle struct {
+		LoginAttempts    int    `toml:"login_attempts"`
+		LoginWindow      int    `toml:"login_window"`
+		AccountAttempts  int    `toml:"account_attempts"`
+		AccountWindow    int    `toml:"account_window"`
+		RegisterAttempts int    `toml:"register_attempts"`
+		RegisterWindow   int    `toml:"register_window"`
+		Lockout          int    `toml:"lockout"`
+		MaxLockout       int    `toml:"max_lockout"`
+		Directory        string `toml:"directory"`
+	} `toml:"throttle"`
+	Timeouts struct {
+		Query int `toml:"query"`
+		Load  int `toml:"load"`
+		Save  int `toml:"save"`
+		Login int `toml:"login"`
+	} `toml:"timeouts"`
+	Proxy struct {
+		Enabled bool     `toml:"enabled"`
+		Trusted []string `toml:"trusted"`
+	} `toml:"proxy"`
 	Crypto struct {
 		RsaKeyFile     string `toml:"rsa_key"`
 		HashSalt       string `toml:"hash_salt"`
@@ -49,20 +86,122 @@ func Verbose() bool {
 	return Verbosity > 0
 }
 
-//Port Returns the primary TCP/IP port to listen for incoming connections on
-func Port() int {
-	return TomlConfig.Port
+//TCPPort Returns the TCP/IP port to listen for incoming raw TCP connections on, or 0 to not listen for them
+func TCPPort() int {
+	return TomlConfig.TCPPort
 }
 
-//WSPort Returns the primary TCP/IP port to listen for incoming websocket connections on
+//WSPort Returns the TCP/IP port to listen for incoming websocket connections on, or 0 to not listen for them
 func WSPort() int {
-	return TomlConfig.Port + 1
+	return TomlConfig.Port
 }
 
 func MaxPlayers() int {
 	return TomlConfig.MaxPlayers
 }
 
+//AutosaveInterval Returns how often every online player gets saved in the background.  Zero means never.
+func AutosaveInterval() time.Duration {
+	return time.Duration(TomlConfig.AutosaveInterval) * time.Second
+}
+
+//ReconnectWindow Returns how long a player that lost its connection stays in the world, waiting for its client to
+// reconnect.  Zero means players get logged out as soon as their connection is lost.
+func ReconnectWindow() time.Duration {
+	return time.Duration(TomlConfig.ReconnectWindow) * time.Second
+}
+
+//QueryTimeout Returns how long any one quick database operation, such as checking a password, may take.
+// Zero means no limit.
+func QueryTimeout() time.Duration {
+	return time.Duration(TomlConfig.Timeouts.Query) * time.Second
+}
+
+//LoadTimeout Returns how long loading a whole player, or a table of game data, from the database may take.
+// Zero means no limit.
+func LoadTimeout() time.Duration {
+	return time.Duration(TomlConfig.Timeouts.Load) * time.Second
+}
+
+//SaveTimeout Returns how long saving a whole player to the database may take.  Zero means no limit.
+func SaveTimeout() time.Duration {
+	return time.Duration(TomlConfig.Timeouts.Save) * time.Second
+}
+
+//LoginTimeout Returns how long the database gets to do everything one login needs from it, before the client is told
+// that the server rejected it.  Zero means no limit.
+func LoginTimeout() time.Duration {
+	return time.Duration(TomlConfig.Timeouts.Login) * time.Second
+}
+
+//MetricsAddress Returns the address to serve Prometheus metrics on, at /metrics.  Empty means metrics are not served.
+func MetricsAddress() string {
+	return TomlConfig.MetricsAddress
+}
+
+//ReservedNames Returns the words that nobody can register a username containing.
+func ReservedNames() []string {
+	return TomlConfig.ReservedNames
+}
+
+//CaptureDirectory Returns the directory that packet captures started with ::capture get written to.
+func CaptureDirectory() string {
+	return TomlConfig.CaptureDirectory
+}
+
+//TLSEnabled Returns true if the game listener should accept TLS connections, alongside plain ones.
+func TLSEnabled() bool {
+	return TomlConfig.TLS.Enabled
+}
+
+//TLSCert Returns the path to the PEM encoded certificate chain to use for TLS connections.
+func TLSCert() string {
+	return TomlConfig.TLS.Cert
+}
+
+//TLSKey Returns the path to the PEM encoded private key to use for TLS connections.
+func TLSKey() string {
+	return TomlConfig.TLS.Key
+}
+
+//ThrottleLogin Returns how many failed logins each address gets within how long, before it is locked out.
+func ThrottleLogin() (int, time.Duration) {
+	return TomlConfig.Throttle.LoginAttempts, time.Duration(TomlConfig.Throttle.LoginWindow) * time.Second
+}
+
+//ThrottleAccount Returns how many failed logins each account gets within how long, before it is locked out.
+func ThrottleAccount() (int, time.Duration) {
+	return TomlConfig.Throttle.AccountAttempts, time.Duration(TomlConfig.Throttle.AccountWindow) * time.Second
+}
+
+//ThrottleRegister Returns how many accounts each address may create within how long, before it is locked out of
+// creating more.
+func ThrottleRegister() (int, time.Duration) {
+	return TomlConfig.Throttle.RegisterAttempts, time.Duration(TomlConfig.Throttle.RegisterWindow) * time.Second
+}
+
+//ThrottleLockout Returns how long the first lockout lasts, and the longest that any lockout may last.
+func ThrottleLockout() (time.Duration, time.Duration) {
+	return time.Duration(TomlConfig.Throttle.Lockout) * time.Second, time.Duration(TomlConfig.Throttle.MaxLockout) * time.Second
+}
+
+//ThrottleDirectory Returns the directory that throttles are kept in across restarts, or an empty string to keep them
+// in memory only.
+func ThrottleDirectory() string {
+	return TomlConfig.Throttle.Directory
+}
+
+//ProxyEnabled Returns true if clients connecting through a trusted proxy should be seen with the address that the
+// proxy says they have, rather than the address of the proxy.
+func ProxyEnabled() bool {
+	return TomlConfig.Proxy.Enabled
+}
+
+//ProxyTrusted Returns the addresses and CIDR ranges of the proxies that are trusted to say where their clients are.
+func ProxyTrusted() []string {
+	return TomlConfig.Proxy.Trusted
+}
+
 func DataDir() string {
 	return TomlConfig.DataDir
 }
@@ -71,10 +210,21 @@ func Version() int {
 	return TomlConfig.Version
 }
 
+//OpcodeCipher Returns true if clients of the server's own revision encrypt packet opcodes with ISAAC in both
+// directions, once they log in.
+func OpcodeCipher() bool {
+	return TomlConfig.OpcodeCipher
+}
+
 func PacketHandlers() string {
 	return TomlConfig.PacketHandlerFile
 }
 
+//Revisions Returns the path to the file describing the client revisions supported besides Version.
+func Revisions() string {
+	return TomlConfig.RevisionFile
+}
+
 func HashLength() int {
 	return TomlConfig.Crypto.HashLength
 }
@@ -103,6 +253,12 @@ func PlayerDriver() string {
 	return TomlConfig.Database.PlayerDriver
 }
 
+//PlayerJournal Returns the file that player saves get journaled to, before they are applied to the player database.
+// Empty if saves should go straight to the player database.
+func PlayerJournal() string {
+	return TomlConfig.Database.PlayerJournal
+}
+
 func WorldDriver() string {
 	return TomlConfig.Database.WorldDriver
 }
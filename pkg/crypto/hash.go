@@ -1,19 +1,109 @@
 package crypto
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
+	"fmt"
 	"runtime"
+	"strings"
 
 	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/log"
 	"golang.org/x/crypto/argon2"
 )
 
-//Hash Takes a plaintext password as input, returns a hexadecimal string representation of the Argon2id hash as output.
-func Hash(password string) string {
+//SaltLength How many random bytes of salt each password hash gets.
+const SaltLength = 16
+
+//params The Argon2id parameters that a hash was made with.
+type params struct {
+	memory, time uint32
+	threads      uint8
+	keyLength    uint32
+}
+
+//currentParams Returns the Argon2id parameters that new hashes get made with, from the crypto section of the config.
+func currentParams() params {
+	threads := runtime.NumCPU()
+	if threads > 255 {
+		threads = 255
+	}
+	return params{uint32(config.HashMemory() * 1024), uint32(config.HashComplexity()), uint8(threads), uint32(config.HashLength())}
+}
+
+//Hash Takes a plaintext password as input, and returns its Argon2id hash as output, encoded as a PHC string along with
+// its own random salt and the parameters used to make it, e.g:
+//	$argon2id$v=19$m=8192,t=15,p=4$<base64 salt>$<base64 hash>
+// Since the parameters are stored with the hash, they can be changed in the config at any time without breaking
+// existing passwords.
+// Returns an error if no random salt could be read, as a hash without one would be no good to anybody.
+func Hash(password string) (string, error) {
+	salt := make([]byte, SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("could not read random salt for password hash: %v", err)
+	}
+	p := currentParams()
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, p.keyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+//legacyHash Returns the hexadecimal Argon2id hash of password, salted with the global hash_salt from the config.
+// This is how every password used to be hashed, before each hash got its own salt.
+func legacyHash(password string) string {
 	return hex.EncodeToString(argon2.IDKey([]byte(password), []byte(config.HashSalt()), uint32(config.HashComplexity()), uint32(config.HashMemory()*1024), uint8(runtime.NumCPU()), uint32(config.HashLength())))
 }
 
-////NewHash Takes a plaintext password as input, returns a hexadecimal string representation of the Argon2id hash as output, with updated variables.
-//func NewHash(password string) string {
-//	return hex.EncodeToString(argon2.IDKey([]byte(password), []byte(config.HashSalt()), 15, uint32(8*1024), uint8(runtime.NumCPU()), uint32(config.HashLength())))
-//}
+//decode Parses a PHC string made by Hash into its parameters, salt and key.
+func decode(encoded string) (p params, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, fmt.Errorf("not an argon2id PHC string")
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return
+	}
+	p.keyLength = uint32(len(key))
+	return
+}
+
+//Verify Returns true if password is the plaintext of encoded, which is either a PHC string made by Hash, or a
+// legacy hash made with the global hash_salt.
+func Verify(password, encoded string) bool {
+	if !strings.HasPrefix(encoded, "$") {
+		return subtle.ConstantTimeCompare([]byte(legacyHash(password)), []byte(encoded)) == 1
+	}
+	p, salt, key, err := decode(encoded)
+	if err != nil {
+		log.Warn("Could not decode password hash:", err)
+		return false
+	}
+	return subtle.ConstantTimeCompare(argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, p.keyLength), key) == 1
+}
+
+//NeedsRehash Returns true if encoded was not made by Hash with the parameters currently in the config, in which case
+// it should be replaced with a new hash the next time that its plaintext is known, e.g at login.
+func NeedsRehash(encoded string) bool {
+	p, _, _, err := decode(encoded)
+	if err != nil {
+		return true
+	}
+	current := currentParams()
+	// the thread count depends on the host, and changing it does not make a hash any weaker
+	return p.memory != current.memory || p.time != current.time || p.keyLength != current.keyLength
+}
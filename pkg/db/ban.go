@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+	"github.com/spkaeros/rscgo/pkg/log"
+)
+
+//NewBanServiceSql Returns a new ban service, which keeps its bans in the ban table of the default players database.
+// The table is created by the player database migrations.
+func NewBanServiceSql() world.BanService {
+	s := newSqlService(config.PlayerDriver())
+	s.sqlOpen(config.PlayerDB())
+	return s
+}
+
+//NewBanService Returns a new ban service, kept alongside the players in whatever dbio.conf selects for them.
+func NewBanService() world.BanService {
+	if config.PlayerDriver() == FileDriver {
+		return NewBanServiceFile()
+	}
+	return NewBanServiceSql()
+}
+
+//BanAdd Saves a new ban, replacing any ban on the same target.
+// Returns true if successful, otherwise returns false.
+func (s *sqlService) BanAdd(ctx context.Context, ban *world.Ban) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		log.Warning.Println("BanAdd(): Could not begin transaction:", err)
+		return false
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM ban WHERE userhash=$1 AND address=$2", int64(ban.UserHash), ban.Address); err != nil {
+		tx.Rollback()
+		log.Warning.Println("BanAdd(): Could not remove old bans:", err)
+		return false
+	}
+	expires := int64(0)
+	if !ban.Permanent() {
+		expires = ban.Expires.Unix()
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO ban(userhash, address, reason, moderator, issued, expires) VALUES($1, $2, $3, $4, $5, $6)",
+		int64(ban.UserHash), ban.Address, ban.Reason, ban.Moderator, ban.Issued.Unix(), expires); err != nil {
+		tx.Rollback()
+		log.Warning.Println("BanAdd(): Could not insert ban:", err)
+		return false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Warning.Println("BanAdd(): Error committing transaction:", err)
+		return false
+	}
+	return true
+}
+
+//BanRemove Removes every ban on the provided account, or address if userHash is 0.
+// Returns true if there was anything to remove.
+func (s *sqlService) BanRemove(ctx context.Context, userHash uint64, address string) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
+	result, err := database.ExecContext(ctx, "DELETE FROM ban WHERE userhash=$1 AND address=$2", int64(userHash), address)
+	if err != nil {
+		log.Warning.Println("BanRemove(): Could not remove bans:", err)
+		return false
+	}
+	count, err := result.RowsAffected()
+	return err == nil && count > 0
+}
+
+//BanList Returns every ban that has not expired yet, newest first.
+// Returns false if the bans could not be read.
+func (s *sqlService) BanList(ctx context.Context) (bans []*world.Ban, ok bool) {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
+	rows, err := database.QueryContext(ctx, "SELECT userhash, address, reason, moderator, issued, expires FROM ban WHERE expires=0 OR expires>$1 ORDER BY issued DESC", time.Now().Unix())
+	if err != nil {
+		log.Warning.Println("BanList(): Could not query bans:", err)
+		return nil, false
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash, issued, expires int64
+		ban := &world.Ban{}
+		if err := rows.Scan(&hash, &ban.Address, &ban.Reason, &ban.Moderator, &issued, &expires); err != nil {
+			log.Warning.Println("BanList(): Could not read ban:", err)
+			continue
+		}
+		ban.UserHash = uint64(hash)
+		ban.Issued = time.Unix(issued, 0)
+		if expires != 0 {
+			ban.Expires = time.Unix(expires, 0)
+		}
+		bans = append(bans, ban)
+	}
+	if err := rows.Err(); err != nil {
+		log.Warning.Println("BanList(): Could not read bans:", err)
+		return nil, false
+	}
+	return bans, true
+}
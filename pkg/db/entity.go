@@ -10,11 +10,11 @@ import (
 )
 
 type EntityService interface {
-	Objects() []definitions.ScenaryDefinition
-	Boundarys() []definitions.BoundaryDefinition
-	Tiles() []definitions.TileDefinition
-	Items() []definitions.ItemDefinition
-	Npcs() []definitions.NpcDefinition
+	Objects(context.Context) []definitions.ScenaryDefinition
+	Boundarys(context.Context) []definitions.BoundaryDefinition
+	Tiles(context.Context) []definitions.TileDefinition
+	Items(context.Context) []definitions.ItemDefinition
+	Npcs(context.Context) []definitions.NpcDefinition
 }
 
 var DefaultEntityService *sqlService
@@ -26,11 +26,12 @@ func ConnectEntityService() {
 }
 
 //Objects attempts to load all the scenary object definitions from the SQL service
-func (s *sqlService) Objects() (objects []definitions.ScenaryDefinition) {
+func (s *sqlService) Objects(ctx context.Context) (objects []definitions.ScenaryDefinition) {
 	s.Lock()
 	defer s.Unlock()
-	s.context = context.Background()
-	rows, err := s.connect(s.context).QueryContext(s.context, "SELECT id, name, description, LOWER(command_one), LOWER(command_two), type, width, height, modelHeight FROM game_objects ORDER BY id")
+	ctx, cancel := withTimeout(ctx, config.LoadTimeout())
+	defer cancel()
+	rows, err := s.connect(ctx).QueryContext(ctx, "SELECT id, name, description, LOWER(command_one), LOWER(command_two), type, width, height, modelHeight FROM game_objects ORDER BY id")
 	if err != nil {
 		log.Warn("Couldn't load entity definitions from sqlService:", err)
 		return
@@ -47,11 +48,12 @@ func (s *sqlService) Objects() (objects []definitions.ScenaryDefinition) {
 }
 
 //Boundarys attempts to load all the boundary game object definitions from the SQL service
-func (s *sqlService) Boundarys() (boundarys []definitions.BoundaryDefinition) {
+func (s *sqlService) Boundarys(ctx context.Context) (boundarys []definitions.BoundaryDefinition) {
 	s.Lock()
 	defer s.Unlock()
-	s.context = context.Background()
-	rows, err := s.connect(s.context).QueryContext(s.context, "SELECT id, name, description, LOWER(command_one), LOWER(command_two), solid, door FROM boundarys ORDER BY id")
+	ctx, cancel := withTimeout(ctx, config.LoadTimeout())
+	defer cancel()
+	rows, err := s.connect(ctx).QueryContext(ctx, "SELECT id, name, description, LOWER(command_one), LOWER(command_two), solid, door FROM boundarys ORDER BY id")
 	if err != nil {
 		log.Warn("Couldn't load entity definitions from sqlService:", err)
 		return
@@ -68,11 +70,12 @@ func (s *sqlService) Boundarys() (boundarys []definitions.BoundaryDefinition) {
 }
 
 //Tiles attempts to load all the tile overlay definitions from the SQL service
-func (s *sqlService) Tiles() (overlays []definitions.TileDefinition) {
+func (s *sqlService) Tiles(ctx context.Context) (overlays []definitions.TileDefinition) {
 	s.Lock()
 	defer s.Unlock()
-	s.context = context.Background()
-	rows, err := s.connect(s.context).QueryContext(s.context, "SELECT colour, unknown, objectType FROM tiles")
+	ctx, cancel := withTimeout(ctx, config.LoadTimeout())
+	defer cancel()
+	rows, err := s.connect(ctx).QueryContext(ctx, "SELECT colour, unknown, objectType FROM tiles")
 	if err != nil {
 		log.Warn("Couldn't load entity definitions from sqlService:", err)
 		return
@@ -89,13 +92,14 @@ func (s *sqlService) Tiles() (overlays []definitions.TileDefinition) {
 }
 
 //Items attempts to load all the item definitions from the SQL service
-func (s *sqlService) Items() (items []definitions.ItemDefinition) {
+func (s *sqlService) Items(ctx context.Context) (items []definitions.ItemDefinition) {
 	s.Lock()
 	defer s.Unlock()
-	s.context = context.Background()
-	db := s.connect(s.context)
+	ctx, cancel := withTimeout(ctx, config.LoadTimeout())
+	defer cancel()
+	db := s.connect(ctx)
 	// defer db.Close()
-	rows, err := db.QueryContext(s.context, "SELECT id, name, description, command, base_price, stackable, special, members FROM items ORDER BY id")
+	rows, err := db.QueryContext(ctx, "SELECT id, name, description, command, base_price, stackable, special, members FROM items ORDER BY id")
 	if err != nil {
 		log.Warn("Couldn't load entity definitions from sqlService:", err)
 		return
@@ -109,7 +113,7 @@ func (s *sqlService) Items() (items []definitions.ItemDefinition) {
 	}
 	rows.Close()
 
-	rows, err = db.QueryContext(s.context, "SELECT id, skillIndex, level FROM item_wieldable_requirements")
+	rows, err = db.QueryContext(ctx, "SELECT id, skillIndex, level FROM item_wieldable_requirements")
 	if err != nil {
 		log.Error.Println("Couldn't load entity information from sql database:", err)
 		return
@@ -124,7 +128,7 @@ func (s *sqlService) Items() (items []definitions.ItemDefinition) {
 	}
 	rows.Close()
 
-	rows, err = db.QueryContext(s.context, "SELECT id, sprite, type, armour_points, magic_points, prayer_points, range_points, weapon_aim_points, weapon_power_points, pos, femaleOnly FROM item_wieldable")
+	rows, err = db.QueryContext(ctx, "SELECT id, sprite, type, armour_points, magic_points, prayer_points, range_points, weapon_aim_points, weapon_power_points, pos, femaleOnly FROM item_wieldable")
 	if err != nil {
 		log.Error.Println("Couldn't load entity information from sql database:", err)
 		return
@@ -140,11 +144,12 @@ func (s *sqlService) Items() (items []definitions.ItemDefinition) {
 }
 
 //Npcs attempts to load all the npc definitions from the SQL service
-func (s *sqlService) Npcs() (npcs []definitions.NpcDefinition) {
+func (s *sqlService) Npcs(ctx context.Context) (npcs []definitions.NpcDefinition) {
 	s.Lock()
 	defer s.Unlock()
-	s.context = context.Background()
-	rows, err := s.connect(s.context).QueryContext(s.context, "SELECT id, name, description, command, hits, attack, strength, defense, hostility FROM npcs ORDER BY id")
+	ctx, cancel := withTimeout(ctx, config.LoadTimeout())
+	defer cancel()
+	rows, err := s.connect(ctx).QueryContext(ctx, "SELECT id, name, description, command, hits, attack, strength, defense, hostility FROM npcs ORDER BY id")
 	if err != nil {
 		log.Warn("Couldn't load entity definitions from sqlService:", err)
 		return
@@ -162,32 +167,33 @@ func (s *sqlService) Npcs() (npcs []definitions.NpcDefinition) {
 
 //LoadObjectDefinitions Loads game object data into memory for quick access.
 func LoadObjectDefinitions() {
-	definitions.ScenaryObjects = DefaultEntityService.Objects()
+	definitions.ScenaryObjects = DefaultEntityService.Objects(context.Background())
 }
 
 //LoadTileDefinitions Loads game tile attribute data into memory for quick access.
 func LoadTileDefinitions() {
-	definitions.TileOverlays = DefaultEntityService.Tiles()
+	definitions.TileOverlays = DefaultEntityService.Tiles(context.Background())
 }
 
 //LoadBoundaryDefinitions Loads game boundary object data into memory for quick access.
 func LoadBoundaryDefinitions() {
-	definitions.BoundaryObjects = DefaultEntityService.Boundarys()
+	definitions.BoundaryObjects = DefaultEntityService.Boundarys(context.Background())
 }
 
 //LoadItemDefinitions Loads game item data into memory for quick access.
 func LoadItemDefinitions() {
-	definitions.Items = DefaultEntityService.Items()
+	definitions.Items = DefaultEntityService.Items(context.Background())
 }
 
 //LoadNpcDefinitions Loads game NPC data into memory for quick access.
 func LoadNpcDefinitions() {
-	definitions.Npcs = DefaultEntityService.Npcs()
+	definitions.Npcs = DefaultEntityService.Npcs(context.Background())
 }
 
 //LoadObjectLocations Loads the game objects into memory from the SQLite3 database.
 func LoadObjectLocations() {
-	ctx := context.Background()
+	ctx, cancel := withTimeout(context.Background(), config.LoadTimeout())
+	defer cancel()
 	rows, err := DefaultEntityService.sqlOpen(config.WorldDB()).QueryContext(ctx, "SELECT id, direction, boundary, x, y FROM game_object_locations")
 	if err != nil {
 		log.Warn("Couldn't load SQLite3 database:", err)
@@ -206,7 +212,8 @@ func LoadObjectLocations() {
 
 //LoadNpcLocations Loads the games NPCs into memory from the SQLite3 database.
 func LoadNpcLocations() {
-	ctx := context.Background()
+	ctx, cancel := withTimeout(context.Background(), config.LoadTimeout())
+	defer cancel()
 	rows, err := DefaultEntityService.sqlOpen(config.WorldDB()).QueryContext(ctx, "SELECT id, startX, minX, maxX, startY, minY, maxY FROM npc_locations")
 	if err != nil {
 		log.Warn("Couldn't load SQLite3 database:", err)
@@ -222,7 +229,8 @@ func LoadNpcLocations() {
 
 //LoadItemLocations Loads the games ground items into memory from the SQLite3 database.
 func LoadItemLocations() {
-	ctx := context.Background()
+	ctx, cancel := withTimeout(context.Background(), config.LoadTimeout())
+	defer cancel()
 	rows, err := DefaultEntityService.sqlOpen(config.WorldDB()).QueryContext(ctx, "SELECT id, amount, x, y, respawn FROM item_locations")
 	if err != nil {
 		log.Warn("Couldn't load SQLite3 database:", err)
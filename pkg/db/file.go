@@ -0,0 +1,626 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/crypto"
+	"github.com/spkaeros/rscgo/pkg/game/entity"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//FileDriver The player_driver to set in dbio.conf to keep players in JSON files, rather than an SQL database.
+// player_db is then the directory to keep them in.
+const FileDriver = "json"
+
+//maxLoginHistory How many login attempts each account document keeps, before the oldest get dropped.
+const maxLoginHistory = 100
+
+//playerDocument Everything persisted about one account, as it is kept in its file.
+type playerDocument struct {
+	ID         int                    `json:"id"`
+	Username   string                 `json:"username"`
+	UserHash   uint64                 `json:"userhash"`
+	Password   string                 `json:"password"`
+	Rank       int                    `json:"rank"`
+	X          int                    `json:"x"`
+	Y          int                    `json:"y"`
+	Online     bool                   `json:"online"`
+	Appearance entity.AppearanceTable `json:"appearance"`
+	// Attributes holds the player_attr values, encoded by encodeAttribute
+	Attributes map[string]string      `json:"attributes"`
+	Friends    []uint64               `json:"friends"`
+	Ignores    []uint64               `json:"ignores"`
+	// Stats holds the current level and experience for each skill, in that order
+	Stats      [18][2]int             `json:"stats"`
+	Inventory  []world.SavedItem      `json:"inventory"`
+	Bank       []world.SavedItem      `json:"bank"`
+	Recovery   *recoveryDocument      `json:"recovery,omitempty"`
+	// Logins holds the most recent login attempts on this account, newest first
+	Logins     []*world.LoginAttempt  `json:"logins,omitempty"`
+}
+
+//recoveryDocument The recovery questions of an account, with their answers hashed the same way as passwords.
+type recoveryDocument struct {
+	Questions []string  `json:"questions"`
+	Answers   []string  `json:"answers"`
+	Changed   time.Time `json:"changed"`
+}
+
+//fileEntry What the index of a fileService knows about an account without reading its file.
+type fileEntry struct {
+	id     int
+	online bool
+}
+
+//fileService A persistence service that keeps one JSON document per account in a directory, named after the
+// accounts username hash, along with a bans.json.  Every write goes to a temporary file that then gets renamed over
+// the old one, so a crash can never leave a half written account behind.  Local files don't hang the way a database
+// server can, so the contexts PlayerService passes in go unused.
+// Implements PlayerService, world.BanService and world.LoginHistoryService.
+type fileService struct {
+	sync.Mutex
+	dir string
+	// index holds every account in dir, by username hash
+	index  map[uint64]*fileEntry
+	nextID int
+}
+
+var (
+	fileServices     = make(map[string]*fileService)
+	fileServicesLock sync.Mutex
+)
+
+//openFileService Returns the file service for the provided directory, creating the directory and indexing the
+// accounts in it the first time it is asked for.  Every service asked for with the same directory shares one
+// instance, so that players, bans and login history never write over each others changes.
+func openFileService(dir string) *fileService {
+	fileServicesLock.Lock()
+	defer fileServicesLock.Unlock()
+	if s, ok := fileServices[dir]; ok {
+		return s
+	}
+	s := &fileService{dir: dir, index: make(map[uint64]*fileEntry), nextID: 1}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Error.Println("Couldn't create player directory (dir: "+dir+"):", err)
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		log.Error.Println("Couldn't index player directory (dir: "+dir+"):", err)
+	}
+	for _, name := range names {
+		hash, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), ".json"), 10, 64)
+		if err != nil {
+			// bans.json, or something else that isn't ours
+			continue
+		}
+		doc, err := s.read(hash)
+		if err != nil {
+			log.Warning.Println("Couldn't index player file", name+":", err)
+			continue
+		}
+		s.index[hash] = &fileEntry{id: doc.ID, online: doc.Online}
+		if doc.ID >= s.nextID {
+			s.nextID = doc.ID + 1
+		}
+	}
+	fileServices[dir] = s
+	return s
+}
+
+//NewPlayerServiceFile Returns a new player service, which keeps every account in its own JSON file in the players
+// directory configured in dbio.conf.
+func NewPlayerServiceFile() PlayerService {
+	return openFileService(config.PlayerDB())
+}
+
+//NewBanServiceFile Returns a new ban service, which keeps every ban in bans.json in the players directory
+// configured in dbio.conf.
+func NewBanServiceFile() world.BanService {
+	return openFileService(config.PlayerDB())
+}
+
+//NewLoginHistoryServiceFile Returns a new login history service, which keeps the most recent login attempts on
+// each account in the accounts own file, in the players directory configured in dbio.conf.
+func NewLoginHistoryServiceFile() world.LoginHistoryService {
+	return openFileService(config.PlayerDB())
+}
+
+//path Returns the name of the file that the account with the provided username hash is kept in.
+func (s *fileService) path(userHash uint64) string {
+	return filepath.Join(s.dir, strconv.FormatUint(userHash, 10)+".json")
+}
+
+//read Reads the document of the account with the provided username hash.  The caller must hold the lock, except
+// while indexing.
+func (s *fileService) read(userHash uint64) (*playerDocument, error) {
+	data, err := ioutil.ReadFile(s.path(userHash))
+	if err != nil {
+		return nil, err
+	}
+	doc := &playerDocument{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+//load Returns the document of the account with the provided username hash, or nil if there is no such account, or
+// it could not be read.  The caller must hold the lock.
+func (s *fileService) load(userHash uint64, caller string) *playerDocument {
+	if _, ok := s.index[userHash]; !ok {
+		return nil
+	}
+	doc, err := s.read(userHash)
+	if err != nil {
+		log.Warning.Println(caller+": Could not read player file:", err)
+		return nil
+	}
+	return doc
+}
+
+//write Saves the document of an account, and updates the index to match.  The caller must hold the lock.
+func (s *fileService) write(doc *playerDocument, caller string) bool {
+	data, err := json.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		log.Warning.Println(caller+": Could not encode player file:", err)
+		return false
+	}
+	if err := writeFile(s.path(doc.UserHash), data); err != nil {
+		log.Warning.Println(caller+": Could not write player file:", err)
+		return false
+	}
+	s.index[doc.UserHash] = &fileEntry{id: doc.ID, online: doc.Online}
+	return true
+}
+
+//writeFile Replaces the contents of the named file with data.  The data goes to a temporary file in the same
+// directory first, which then gets renamed over the named file, so that it only ever holds the old or new contents.
+// Both the file and the directory are synced, so the new contents survive a crash once this returns.
+func writeFile(name string, data []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), name); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	// the rename lives in the directory, which has to be synced for it to stick
+	dir, err := os.Open(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+//PlayerCreate Creates a new account file with the specified credentials, and the same starting stats, items and
+// appearance as PlayerCreate gives accounts in SQL databases.
+// Returns true if successful, otherwise returns false.
+func (s *fileService) PlayerCreate(_ context.Context, username, password, ip string) bool {
+	s.Lock()
+	defer s.Unlock()
+	hash := strutil.Base37.Encode(username)
+	if _, ok := s.index[hash]; ok {
+		log.Info.Println("PlayerCreate(): Player file already exists for", username)
+		return false
+	}
+	doc := &playerDocument{
+		ID:         s.nextID,
+		Username:   username,
+		UserHash:   hash,
+		Password:   password,
+		X:          220,
+		Y:          445,
+		Appearance: entity.DefaultAppearance(),
+		Attributes: map[string]string{"lastIP": "s" + ip},
+		Bank:       []world.SavedItem{{ID: 546, Amount: 96000}, {ID: 373, Amount: 96000}},
+	}
+	for i := range doc.Stats {
+		doc.Stats[i] = [2]int{1, 0}
+	}
+	doc.Stats[entity.StatHits] = [2]int{10, 1156}
+	// 12 inv slots remaining
+	for _, item := range [][2]int{{1263, 1}, {77, 1}, {71, 1}, {6, 1}, {7, 1}, {8, 1}, {9, 1}, {316, 1}, {198, 1},
+		{185, 1}, {184, 1}, {187, 1}, {35, 100}, {33, 100}, {36, 100}, {188, 1}, {189, 1}, {11, 100}} {
+		doc.Inventory = append(doc.Inventory, world.SavedItem{ID: item[0], Amount: item[1]})
+	}
+	if !s.write(doc, "PlayerCreate()") {
+		return false
+	}
+	s.nextID++
+	return true
+}
+
+//PlayerNameExists Returns true if there is an account named username, otherwise returns false.
+func (s *fileService) PlayerNameExists(_ context.Context, username string) bool {
+	s.Lock()
+	defer s.Unlock()
+	_, ok := s.index[strutil.Base37.Encode(username)]
+	return ok
+}
+
+//OnlineCount Returns number of online players total.
+func (s *fileService) OnlineCount(_ context.Context) int {
+	s.Lock()
+	defer s.Unlock()
+	online := 0
+	for _, entry := range s.index {
+		if entry.online {
+			online++
+		}
+	}
+	return online
+}
+
+//PlayerValidLogin Returns true if there is an account with this username hash, and password is the plaintext of
+// its password hash, otherwise returns false.  Old or outdated hashes get replaced with a new one from crypto.Hash.
+func (s *fileService) PlayerValidLogin(_ context.Context, userHash uint64, password string) bool {
+	s.Lock()
+	doc := s.load(userHash, "Validate")
+	s.Unlock()
+	// hashing takes long enough that every other account would be held up if the lock was kept for it
+	if doc == nil || !crypto.Verify(password, doc.Password) {
+		return false
+	}
+	if crypto.NeedsRehash(doc.Password) {
+		// legacy hash, or the hash settings have changed since; this is the only time we know the plaintext to fix it
+		rehashed, err := crypto.Hash(password)
+		if err != nil {
+			log.Warning.Println("Validate: Could not rehash password:", err)
+			return true
+		}
+		s.Lock()
+		defer s.Unlock()
+		// the account could have been saved since, so the rehash goes on top of whatever it holds now
+		if current := s.load(userHash, "Validate"); current != nil && current.Password == doc.Password {
+			current.Password = rehashed
+			s.write(current, "Validate")
+		}
+	}
+	return true
+}
+
+//PlayerChangePassword Updates the password of the account with this username hash to password.
+func (s *fileService) PlayerChangePassword(_ context.Context, userHash uint64, password string) bool {
+	s.Lock()
+	defer s.Unlock()
+	doc := s.load(userHash, "PlayerChangePassword")
+	if doc == nil {
+		return false
+	}
+	doc.Password = password
+	return s.write(doc, "PlayerChangePassword")
+}
+
+//PlayerHasRecoverys Returns true if this username has recovery questions assigned to it, otherwise returns false.
+func (s *fileService) PlayerHasRecoverys(_ context.Context, userHash uint64) bool {
+	s.Lock()
+	defer s.Unlock()
+	doc := s.load(userHash, "PlayerHasRecoverys")
+	return doc != nil && doc.Recovery != nil
+}
+
+//PlayerLoadRecoverys Retrieves the recovery questions assigned to this username if any, otherwise returns nil
+func (s *fileService) PlayerLoadRecoverys(_ context.Context, userHash uint64) []string {
+	s.Lock()
+	defer s.Unlock()
+	doc := s.load(userHash, "PlayerLoadRecoverys")
+	if doc == nil || doc.Recovery == nil {
+		return nil
+	}
+	return append([]string{}, doc.Recovery.Questions...)
+}
+
+//PlayerRecoverysChanged Returns when the recovery questions assigned to this username were last set, and true, or
+// false if there aren't any.
+func (s *fileService) PlayerRecoverysChanged(_ context.Context, userHash uint64) (time.Time, bool) {
+	s.Lock()
+	defer s.Unlock()
+	doc := s.load(userHash, "PlayerRecoverysChanged")
+	if doc == nil || doc.Recovery == nil {
+		return time.Time{}, false
+	}
+	return doc.Recovery.Changed, true
+}
+
+//PlayerValidRecovery Returns true if answers are the answers to every one of the recovery questions assigned to
+// this username, in order, otherwise returns false.
+func (s *fileService) PlayerValidRecovery(_ context.Context, userHash uint64, answers []uint64) bool {
+	if len(answers) != 5 {
+		return false
+	}
+	s.Lock()
+	doc := s.load(userHash, "PlayerValidRecovery")
+	s.Unlock()
+	if doc == nil || doc.Recovery == nil || len(doc.Recovery.Answers) != 5 {
+		return false
+	}
+	valid := true
+	for i, hash := range doc.Recovery.Answers {
+		// check every answer either way, so that how long this takes doesn't give away which one was wrong
+		if !crypto.Verify(strconv.FormatUint(answers[i], 10), hash) {
+			valid = false
+		}
+	}
+	return valid
+}
+
+//SaveRecoveryQuestions Saves new recovery questions to the account, replacing any old ones.  The answers are
+// hashed the same way that passwords are before being saved.
+// Returns true if successful, otherwise returns false.
+func (s *fileService) SaveRecoveryQuestions(_ context.Context, userHash uint64, questions []string, answers []uint64) bool {
+	if len(questions) != 5 || len(answers) != 5 {
+		return false
+	}
+	recovery := &recoveryDocument{Questions: append([]string{}, questions...), Changed: time.Now()}
+	for _, answer := range answers {
+		hash, err := crypto.Hash(strconv.FormatUint(answer, 10))
+		if err != nil {
+			log.Warning.Println("SaveRecoveryQuestions(): Could not hash answer:", err)
+			return false
+		}
+		recovery.Answers = append(recovery.Answers, hash)
+	}
+	s.Lock()
+	defer s.Unlock()
+	doc := s.load(userHash, "SaveRecoveryQuestions()")
+	if doc == nil {
+		return false
+	}
+	doc.Recovery = recovery
+	return s.write(doc, "SaveRecoveryQuestions()")
+}
+
+//PlayerLoad Loads a player from its account file.
+// Returns: true on success, false on failure
+func (s *fileService) PlayerLoad(_ context.Context, player *world.Player) bool {
+	s.Lock()
+	defer s.Unlock()
+	doc := s.load(player.UsernameHash(), "Load error")
+	if doc == nil {
+		return false
+	}
+	player.DatabaseIndex = doc.ID
+	player.SetVar("rank", doc.Rank)
+	player.Appearance = doc.Appearance
+	player.Equips()[0] = player.Appearance.Head
+	player.Equips()[1] = player.Appearance.Body
+	player.SetX(doc.X)
+	player.SetY(doc.Y)
+	for name, value := range doc.Attributes {
+		if val, ok := decodeAttribute(name, value); ok {
+			player.Attributes.SetVar(name, val)
+		}
+	}
+	for _, hash := range doc.Friends {
+		player.FriendList.Add(strutil.Base37.Decode(hash))
+	}
+	player.IgnoreList = append(player.IgnoreList, doc.Ignores...)
+	for _, item := range doc.Inventory {
+		loadItem(player, item.ID, item.Amount, item.Worn)
+	}
+	for _, item := range doc.Bank {
+		player.Bank().Add(item.ID, item.Amount)
+	}
+	for i, levels := range doc.Stats {
+		player.Skills().SetCur(i, levels[0])
+		player.Skills().SetMax(i, entity.ExperienceToLevel(levels[1]))
+		player.Skills().SetExp(i, levels[1])
+	}
+	if doc.Recovery != nil {
+		player.SetVar("recoveryChanged", doc.Recovery.Changed)
+	}
+	doc.Online = true
+	s.write(doc, "Load error")
+	return true
+}
+
+//ping Returns true if the account directory is still there.
+func (s *fileService) ping(context.Context) bool {
+	info, err := os.Stat(s.dir)
+	return err == nil && info.IsDir()
+}
+
+//PlayerSave Saves a player to its account file.  Returns true if the save was written, otherwise returns false.
+func (s *fileService) PlayerSave(ctx context.Context, player *world.Player) bool {
+	return s.PlayerSaveSnapshot(ctx, player.Snapshot())
+}
+
+//PlayerSaveSnapshot Saves a snapshot of a player to its account file.  Returns true if the save was written,
+// otherwise returns false.
+func (s *fileService) PlayerSaveSnapshot(_ context.Context, player *world.PlayerSnapshot) bool {
+	s.Lock()
+	defer s.Unlock()
+	doc := s.load(player.UsernameHash, "Save()")
+	if doc == nil {
+		log.Warning.Println("Save(): Could not save player:", player.Username)
+		return false
+	}
+	doc.X, doc.Y = player.X, player.Y
+	doc.Online = player.Online
+	doc.Appearance = player.Appearance
+	doc.Attributes = make(map[string]string)
+	for name, value := range player.Attributes {
+		doc.Attributes[name] = encodeAttribute(name, value)
+	}
+	doc.Friends = append([]uint64{}, player.Friends...)
+	doc.Ignores = append([]uint64{}, player.Ignores...)
+	doc.Stats = player.Stats
+	doc.Inventory = append([]world.SavedItem{}, player.Inventory...)
+	doc.Bank = append([]world.SavedItem{}, player.Bank...)
+	if !s.write(doc, "Save()") {
+		log.Warning.Println("Save(): Could not save player:", player.Username)
+		return false
+	}
+	return true
+}
+
+//bansPath Returns the name of the file that bans are kept in.
+func (s *fileService) bansPath() string {
+	return filepath.Join(s.dir, "bans.json")
+}
+
+//readBans Returns every ban in the bans file, expired or not.  The caller must hold the lock.
+func (s *fileService) readBans(caller string) (bans []*world.Ban, ok bool) {
+	data, err := ioutil.ReadFile(s.bansPath())
+	if os.IsNotExist(err) {
+		return nil, true
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &bans)
+	}
+	if err != nil {
+		log.Warning.Println(caller+": Could not read bans:", err)
+		return nil, false
+	}
+	return bans, true
+}
+
+//writeBans Replaces the bans file with bans, leaving out any that have expired.  The caller must hold the lock.
+func (s *fileService) writeBans(bans []*world.Ban, caller string) bool {
+	active := []*world.Ban{}
+	for _, ban := range bans {
+		if ban.Active() {
+			active = append(active, ban)
+		}
+	}
+	data, err := json.MarshalIndent(active, "", "\t")
+	if err == nil {
+		err = writeFile(s.bansPath(), data)
+	}
+	if err != nil {
+		log.Warning.Println(caller+": Could not write bans:", err)
+		return false
+	}
+	return true
+}
+
+//BanAdd Saves a new ban, replacing any ban on the same target.
+// Returns true if successful, otherwise returns false.
+func (s *fileService) BanAdd(_ context.Context, ban *world.Ban) bool {
+	s.Lock()
+	defer s.Unlock()
+	bans, ok := s.readBans("BanAdd()")
+	if !ok {
+		return false
+	}
+	kept := []*world.Ban{ban}
+	for _, b := range bans {
+		if b.UserHash != ban.UserHash || b.Address != ban.Address {
+			kept = append(kept, b)
+		}
+	}
+	return s.writeBans(kept, "BanAdd()")
+}
+
+//BanRemove Removes every ban on the provided account, or address if userHash is 0.
+// Returns true if there was anything to remove.
+func (s *fileService) BanRemove(_ context.Context, userHash uint64, address string) bool {
+	s.Lock()
+	defer s.Unlock()
+	bans, ok := s.readBans("BanRemove()")
+	if !ok {
+		return false
+	}
+	var kept []*world.Ban
+	for _, b := range bans {
+		if b.UserHash != userHash || b.Address != address {
+			kept = append(kept, b)
+		}
+	}
+	if len(kept) == len(bans) {
+		return false
+	}
+	return s.writeBans(kept, "BanRemove()")
+}
+
+//BanList Returns every ban that has not expired yet, newest first.
+// Returns false if the bans could not be read.
+func (s *fileService) BanList(_ context.Context) (bans []*world.Ban, ok bool) {
+	s.Lock()
+	all, ok := s.readBans("BanList()")
+	s.Unlock()
+	for _, ban := range all {
+		if ban.Active() {
+			bans = append(bans, ban)
+		}
+	}
+	sort.SliceStable(bans, func(i, j int) bool {
+		return bans[i].Issued.After(bans[j].Issued)
+	})
+	return bans, ok
+}
+
+//LoginAdd Saves a new login attempt to the file of the account it was on.  Attempts on accounts that don't exist
+// have nowhere to go, so they are not kept.
+// Returns true if successful, otherwise returns false.
+func (s *fileService) LoginAdd(_ context.Context, attempt *world.LoginAttempt) bool {
+	s.Lock()
+	defer s.Unlock()
+	doc := s.load(attempt.UserHash, "LoginAdd()")
+	if doc == nil {
+		return false
+	}
+	doc.Logins = append([]*world.LoginAttempt{attempt}, doc.Logins...)
+	if len(doc.Logins) > maxLoginHistory {
+		doc.Logins = doc.Logins[:maxLoginHistory]
+	}
+	return s.write(doc, "LoginAdd()")
+}
+
+//LoginList Returns up to limit of the most recent login attempts on the provided account, newest first.
+func (s *fileService) LoginList(_ context.Context, userHash uint64, limit int) []*world.LoginAttempt {
+	s.Lock()
+	defer s.Unlock()
+	doc := s.load(userHash, "LoginList()")
+	if doc == nil {
+		return nil
+	}
+	if len(doc.Logins) > limit {
+		return doc.Logins[:limit]
+	}
+	return doc.Logins
+}
+
+//LoginLast Returns the most recent successful login to the provided account, or nil if there never was one.
+func (s *fileService) LoginLast(_ context.Context, userHash uint64) *world.LoginAttempt {
+	s.Lock()
+	defer s.Unlock()
+	doc := s.load(userHash, "LoginLast()")
+	if doc == nil {
+		return nil
+	}
+	for _, attempt := range doc.Logins {
+		if attempt.Success() {
+			return attempt
+		}
+	}
+	return nil
+}
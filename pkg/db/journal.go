@@ -0,0 +1,486 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/game/world"
+	"github.com/spkaeros/rscgo/pkg/log"
+)
+
+var (
+	//journalRetryMin How long the journal waits before retrying a save that its backend failed to apply.
+	journalRetryMin = time.Second
+	//journalRetryMax The longest the journal will back off for between retries, however long the backend is down.
+	journalRetryMax = time.Minute
+)
+
+const (
+	//journalAttempts How many times the journal tries to apply a save that a reachable backend refuses, before it gives
+	// up on it and moves it to the failed journal.  Tries made while the backend can't be reached at all don't count.
+	journalAttempts = 10
+	//journalLoadWait How long loading a player waits for their journaled saves to be applied, before giving up.
+	journalLoadWait = 5 * time.Second
+)
+
+//pinger A PlayerService that can tell whether it can be reached at all, which lets the journal tell a backend that is
+// down apart from one that refuses a save.  A backend that can't tell is always taken to be reachable.
+type pinger interface {
+	ping(ctx context.Context) bool
+}
+
+//journalEntry One line of a save journal.  Either a snapshot that was journaled, or a record that the snapshot with
+// the same sequence number has since been applied to the backend.
+type journalEntry struct {
+	Seq      uint64                `json:"seq"`
+	Applied  bool                  `json:"applied,omitempty"`
+	Snapshot *world.PlayerSnapshot `json:"snapshot,omitempty"`
+	// Attributes holds the snapshots attributes, encoded by encodeAttribute, as JSON can't tell an int from a float
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// previous is the snapshot the backend should diff this one against, when it came from this session
+	previous *world.PlayerSnapshot
+	// attempts counts how many times the backend has refused this save while it was reachable
+	attempts int
+	// retry is when the save may next be tried, after the backend refused it
+	retry time.Time
+}
+
+//JournalService A PlayerService that hands player saves to another PlayerService in the background.
+// Every save is appended to a local journal file, and synced to disk, before it is considered saved; a worker then
+// applies the journaled saves to the backend, each players in order, retrying with backoff.  While the backend can't
+// be reached, nothing is ever given up on.  A save that the backend keeps refusing is moved to a failed journal next to
+// the journal, named like it with .failed on the end, for someone to look into.
+// Anything still unapplied when the server stops, or crashes, is applied again the next time the journal is opened.
+// Everything besides saving and loading players goes straight to the backend.
+type JournalService struct {
+	PlayerService
+	path    string
+	journal *os.File
+	// lock guards everything below it, and the journal file
+	lock    sync.Mutex
+	wake    *sync.Cond
+	seq     uint64
+	queue   []*journalEntry
+	// pending counts the unapplied saves of each player, by username hash
+	pending map[uint64]int
+	// failed holds the players whose newest save was given up on, by username hash, until a newer one is applied
+	failed map[uint64]bool
+	// full holds the players whose next save must not be diffed against the previous one, as that was given up on
+	full map[uint64]bool
+	closing bool
+	stopped chan struct{}
+	// ctx is what the worker applies saves with, and gets cancelled when the journal is closed
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+//NewJournalService Returns a new JournalService that journals saves to the file at path before applying them to
+// backend.  Any saves that a previous run journaled but never applied are queued up again first.
+// If the journal can not be opened, logs why and returns backend, which then saves synchronously as usual.
+func NewJournalService(backend PlayerService, path string) PlayerService {
+	s := &JournalService{PlayerService: backend, path: path, pending: make(map[uint64]int), failed: make(map[uint64]bool), full: make(map[uint64]bool), stopped: make(chan struct{})}
+	s.wake = sync.NewCond(&s.lock)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if !s.replay() {
+		s.cancel()
+		return backend
+	}
+	journal, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		log.Error.Println("Couldn't open player save journal (file: "+path+"):", err)
+		s.cancel()
+		return backend
+	}
+	s.journal = journal
+	if len(s.queue) > 0 {
+		log.Debug("Replaying", len(s.queue), "player saves from", path)
+	}
+	go s.work()
+	return s
+}
+
+//replay Reads the journal, queueing every snapshot in it that was never applied, then rewrites it to hold only them.
+// Returns true on success, otherwise logs why and returns false.
+func (s *JournalService) replay() bool {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return true
+	}
+	if err != nil {
+		log.Error.Println("Couldn't read player save journal (file: "+s.path+"):", err)
+		return false
+	}
+	defer file.Close()
+	var entries []*journalEntry
+	applied := make(map[uint64]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<24)
+	for line := 1; scanner.Scan(); line++ {
+		entry := &journalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			// most likely the last line, cut short by a crash before its save was ever acknowledged
+			log.Warning.Println("Skipping unreadable player save journal entry (file: "+s.path+", line: "+strconv.Itoa(line)+"):", err)
+			continue
+		}
+		if entry.Seq > s.seq {
+			s.seq = entry.Seq
+		}
+		if entry.Applied {
+			applied[entry.Seq] = true
+		} else if entry.Snapshot != nil {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Error.Println("Couldn't read player save journal (file: "+s.path+"):", err)
+		return false
+	}
+	var data []byte
+	for _, entry := range entries {
+		if applied[entry.Seq] {
+			continue
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			log.Error.Println("Couldn't rewrite player save journal (file: "+s.path+"):", err)
+			return false
+		}
+		data = append(append(data, line...), '\n')
+		entry.Snapshot.Attributes = make(map[string]interface{})
+		for name, value := range entry.Attributes {
+			if val, ok := decodeAttribute(name, value); ok {
+				entry.Snapshot.Attributes[name] = val
+			}
+		}
+		s.queue = append(s.queue, entry)
+		s.pending[entry.Snapshot.UsernameHash]++
+	}
+	if err := writeFile(s.path, data); err != nil {
+		log.Error.Println("Couldn't rewrite player save journal (file: "+s.path+"):", err)
+		return false
+	}
+	return true
+}
+
+//append Writes entry to the end of the journal, and syncs it to disk.  Must be called with the lock held.
+func (s *JournalService) append(entry *journalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := s.journal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.journal.Sync()
+}
+
+//PlayerSaveSnapshot Journals a snapshot of a player, to be applied to the backend in the background.
+// Returns true once the snapshot is safely on disk, otherwise returns false.  Journaling never waits on the backend, so
+// ctx goes unused.
+func (s *JournalService) PlayerSaveSnapshot(_ context.Context, player *world.PlayerSnapshot) bool {
+	snapshot := *player
+	snapshot.Attributes = nil
+	snapshot.Previous = nil
+	entry := &journalEntry{Snapshot: &snapshot, Attributes: make(map[string]string), previous: player.Previous}
+	for name, value := range player.Attributes {
+		entry.Attributes[name] = encodeAttribute(name, value)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closing {
+		log.Warning.Println("Save(): Player save journal is closed; could not save player:", player.Username)
+		return false
+	}
+	s.seq++
+	entry.Seq = s.seq
+	if err := s.append(entry); err != nil {
+		log.Warning.Println("Save(): Could not journal player "+player.Username+":", err)
+		return false
+	}
+	snapshot.Attributes = player.Attributes
+	if s.full[player.UsernameHash] {
+		// player.Previous never made it to the backend
+		entry.previous = nil
+		delete(s.full, player.UsernameHash)
+	}
+	s.queue = append(s.queue, entry)
+	s.pending[player.UsernameHash]++
+	s.wake.Signal()
+	return true
+}
+
+//PlayerSave Journals a player, to be applied to the backend in the background.
+// Returns true once the save is safely on disk, otherwise returns false.
+func (s *JournalService) PlayerSave(ctx context.Context, player *world.Player) bool {
+	return s.PlayerSaveSnapshot(ctx, player.Snapshot())
+}
+
+//PlayerLoad Loads a player from the backend, once every save journaled for them has been applied to it.
+// Returns false without loading anything if the backend is still behind after a few seconds, or if their newest save
+// was given up on, so that a player never gets handed an older copy of themselves, or as soon as ctx is done.
+func (s *JournalService) PlayerLoad(ctx context.Context, player *world.Player) bool {
+	deadline := time.Now().Add(journalLoadWait)
+	for {
+		s.lock.Lock()
+		pending := s.pending[player.UsernameHash()]
+		failed := s.failed[player.UsernameHash()]
+		s.lock.Unlock()
+		if pending == 0 {
+			if failed {
+				log.Error.Println("Load error: the newest save of " + player.Username() + " was given up on, and is in " + s.path + ".failed; it has to be looked into before they can log in")
+				return false
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			log.Warning.Println("Load error: " + player.Username() + " has " + strconv.Itoa(pending) + " journaled saves that have not been applied yet")
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			log.Warning.Println("Load error: gave up waiting on the journaled saves of "+player.Username()+":", ctx.Err())
+			return false
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return s.PlayerService.PlayerLoad(ctx, player)
+}
+
+//work Applies journaled saves to the backend until the journal is closed.
+// Each players saves are applied oldest first, so that they end up with their newest save, but a player whose save
+// the backend refused is skipped over while it backs off, so that everyone else's saves keep being applied.
+// While the backend can't be reached at all, every save waits on it, however long that takes.  A save that a reachable
+// backend refuses journalAttempts times is tried once more as a full save, then moved to the failed journal.
+func (s *JournalService) work() {
+	defer close(s.stopped)
+	outage := journalRetryMin
+	for {
+		s.lock.Lock()
+		entry, wait := s.next()
+		for entry == nil && !s.closing {
+			if wait > 0 {
+				timer := time.AfterFunc(wait, func() {
+					s.lock.Lock()
+					s.wake.Broadcast()
+					s.lock.Unlock()
+				})
+				s.wake.Wait()
+				timer.Stop()
+			} else {
+				s.wake.Wait()
+			}
+			entry, wait = s.next()
+		}
+		if s.closing {
+			// whatever is left stays journaled until next time
+			s.lock.Unlock()
+			return
+		}
+		snapshot := *entry.Snapshot
+		snapshot.Previous = entry.previous
+		s.lock.Unlock()
+
+		if s.PlayerService.PlayerSaveSnapshot(s.ctx, &snapshot) {
+			outage = journalRetryMin
+			s.lock.Lock()
+			s.remove(entry)
+			delete(s.failed, snapshot.UsernameHash)
+			s.lock.Unlock()
+			continue
+		}
+		if s.ctx.Err() != nil {
+			// the journal is closing, which is no fault of the save
+			return
+		}
+		if !s.reachable() {
+			log.Warning.Println("Save(): Could not reach the player database; retrying journaled saves in", outage)
+			if s.sleep(outage) {
+				return
+			}
+			if outage *= 2; outage > journalRetryMax {
+				outage = journalRetryMax
+			}
+			continue
+		}
+		outage = journalRetryMin
+
+		s.lock.Lock()
+		if entry.attempts++; entry.attempts < journalAttempts {
+			backoff := journalRetryMin << uint(entry.attempts-1)
+			if backoff > journalRetryMax || backoff <= 0 {
+				backoff = journalRetryMax
+			}
+			entry.retry = time.Now().Add(backoff)
+			s.lock.Unlock()
+			log.Warning.Println("Save(): Could not apply journaled save of", snapshot.Username+"; retrying in", backoff)
+			continue
+		}
+		if entry.previous != nil {
+			// the save it was diffed against might be what the backend can't make sense of
+			entry.previous = nil
+			entry.attempts = 0
+			entry.retry = time.Time{}
+			s.lock.Unlock()
+			log.Warning.Println("Save(): Could not apply journaled save of", snapshot.Username+"; retrying it as a full save")
+			continue
+		}
+		s.lock.Unlock()
+		if err := s.fail(entry); err != nil {
+			log.Error.Println("Save(): Could not move journaled save of "+snapshot.Username+" to "+s.path+".failed:", err)
+			s.lock.Lock()
+			entry.retry = time.Now().Add(journalRetryMax)
+			s.lock.Unlock()
+			continue
+		}
+		log.Error.Println("Save(): GAVE UP on the journaled save of " + snapshot.Username + " after " + strconv.Itoa(journalAttempts) + " attempts; it was moved to " + s.path + ".failed, which can be replayed in place of the journal")
+
+		s.lock.Lock()
+		s.remove(entry)
+		// the backend never got this save, so nothing newer can be diffed against it
+		later := false
+		for _, queued := range s.queue {
+			if queued.Snapshot.UsernameHash == snapshot.UsernameHash {
+				queued.previous = nil
+				later = true
+			}
+		}
+		s.full[snapshot.UsernameHash] = true
+		if !later {
+			s.failed[snapshot.UsernameHash] = true
+		}
+		s.lock.Unlock()
+	}
+}
+
+//next Returns the oldest save that can be tried now, skipping every player whose oldest save is backing off.  If there
+// is none, returns how long until one of them can be tried again, or 0 when the queue is empty.
+// Must be called with the lock held.
+func (s *JournalService) next() (*journalEntry, time.Duration) {
+	now := time.Now()
+	waiting := make(map[uint64]bool)
+	var wait time.Duration
+	for _, entry := range s.queue {
+		hash := entry.Snapshot.UsernameHash
+		if waiting[hash] {
+			continue
+		}
+		if until := entry.retry.Sub(now); until > 0 {
+			waiting[hash] = true
+			if wait == 0 || until < wait {
+				wait = until
+			}
+			continue
+		}
+		return entry, 0
+	}
+	return nil, wait
+}
+
+//remove Takes entry off the queue, and marks it applied in the journal.  Must be called with the lock held.
+func (s *JournalService) remove(entry *journalEntry) {
+	for i, queued := range s.queue {
+		if queued == entry {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+	hash := entry.Snapshot.UsernameHash
+	if s.pending[hash]--; s.pending[hash] <= 0 {
+		delete(s.pending, hash)
+	}
+	if len(s.queue) == 0 {
+		// everything in the journal is applied, so there is nothing in it worth replaying
+		if err := s.journal.Truncate(0); err != nil {
+			log.Warning.Println("Couldn't truncate player save journal:", err)
+		}
+	} else if err := s.append(&journalEntry{Seq: entry.Seq, Applied: true}); err != nil {
+		log.Warning.Println("Couldn't mark journaled save as applied:", err)
+	}
+}
+
+//reachable Returns true if the backend can be reached, meaning that a save it just failed was refused rather than
+// lost on the way.
+func (s *JournalService) reachable() bool {
+	if p, ok := s.PlayerService.(pinger); ok {
+		return p.ping(s.ctx)
+	}
+	return true
+}
+
+//fail Appends entry to the failed journal, and syncs it to disk.
+func (s *JournalService) fail(entry *journalEntry) error {
+	snapshot := *entry.Snapshot
+	snapshot.Attributes = nil
+	snapshot.Previous = nil
+	failed := *entry
+	failed.Snapshot = &snapshot
+	line, err := json.Marshal(&failed)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path+".failed", os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//sleep Waits for d to pass.  Returns true if the journal was closed in the meantime, and should give up waiting on
+// its backend.
+func (s *JournalService) sleep(d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		s.lock.Lock()
+		closing := s.closing
+		s.lock.Unlock()
+		if closing {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
+
+//Pending Returns how many journaled saves have not been applied to the backend yet.
+func (s *JournalService) Pending() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.queue)
+}
+
+//Close Stops journaling saves, and waits up to timeout for the ones already journaled to be applied.
+// Returns how many were left unapplied; they stay in the journal, and get applied the next time it is opened.
+func (s *JournalService) Close(timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for s.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	s.lock.Lock()
+	s.closing = true
+	s.wake.Broadcast()
+	s.lock.Unlock()
+	// gives up on any save the backend is still busy with; it stays journaled like the rest
+	s.cancel()
+	<-s.stopped
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.journal.Close(); err != nil {
+		log.Warning.Println("Couldn't close player save journal:", err)
+	}
+	return len(s.queue)
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/game/world"
+)
+
+//flakyService A backend that refuses the saves of some players, and can be taken down altogether.
+type flakyService struct {
+	PlayerService
+	sync.Mutex
+	down    bool
+	refuse  map[uint64]bool
+	applied []world.PlayerSnapshot
+}
+
+func (s *flakyService) PlayerSaveSnapshot(_ context.Context, player *world.PlayerSnapshot) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.down || s.refuse[player.UsernameHash] {
+		return false
+	}
+	s.applied = append(s.applied, *player)
+	return true
+}
+
+func (s *flakyService) ping(context.Context) bool {
+	s.Lock()
+	defer s.Unlock()
+	return !s.down
+}
+
+//saved Returns every save of the player with hash that was applied, oldest first.
+func (s *flakyService) saved(hash uint64) []world.PlayerSnapshot {
+	s.Lock()
+	defer s.Unlock()
+	var list []world.PlayerSnapshot
+	for _, snapshot := range s.applied {
+		if snapshot.UsernameHash == hash {
+			list = append(list, snapshot)
+		}
+	}
+	return list
+}
+
+//journal Opens a journal in front of backend, with backoff short enough to test.
+func journal(t *testing.T, backend PlayerService) *JournalService {
+	t.Helper()
+	min, max := journalRetryMin, journalRetryMax
+	journalRetryMin, journalRetryMax = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() {
+		journalRetryMin, journalRetryMax = min, max
+	})
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := NewJournalService(backend, filepath.Join(dir, "players.journal")).(*JournalService)
+	if !ok {
+		t.Fatal("Could not open journal")
+	}
+	t.Cleanup(func() {
+		s.Close(0)
+		os.RemoveAll(dir)
+	})
+	return s
+}
+
+//eventually Fails the test if cond is still false after a few seconds.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out")
+		}
+	}
+}
+
+func TestJournalOutage(t *testing.T) {
+	backend := &flakyService{down: true}
+	s := journal(t, backend)
+	if !s.PlayerSaveSnapshot(context.Background(), &world.PlayerSnapshot{Username: "outage", UsernameHash: 1}) {
+		t.Fatal("Could not journal save")
+	}
+	// far more tries than it would give up on a refused save after
+	time.Sleep(journalRetryMax * journalAttempts * 4)
+	if s.Pending() != 1 {
+		t.Fatal("Save was dropped while the backend was down")
+	}
+	backend.Lock()
+	backend.down = false
+	backend.Unlock()
+	eventually(t, func() bool {
+		return s.Pending() == 0
+	})
+	if len(backend.saved(1)) != 1 {
+		t.Fatal("Save was never applied")
+	}
+}
+
+func TestJournalRefused(t *testing.T) {
+	backend := &flakyService{refuse: map[uint64]bool{1: true}}
+	s := journal(t, backend)
+	first := &world.PlayerSnapshot{Username: "refused", UsernameHash: 1}
+	second := &world.PlayerSnapshot{Username: "refused", UsernameHash: 1, Previous: first}
+	s.PlayerSaveSnapshot(context.Background(), first)
+	s.PlayerSaveSnapshot(context.Background(), second)
+	s.PlayerSaveSnapshot(context.Background(), &world.PlayerSnapshot{Username: "bystander", UsernameHash: 2})
+	// the refused player doesn't hold anyone else up
+	eventually(t, func() bool {
+		return len(backend.saved(2)) == 1
+	})
+	if s.Pending() != 2 {
+		t.Fatal("Refused saves were given up on too soon")
+	}
+
+	// only the first was given up on, so the second can't be diffed against it
+	eventually(t, func() bool {
+		return s.Pending() == 1
+	})
+	backend.Lock()
+	backend.refuse = nil
+	backend.Unlock()
+	eventually(t, func() bool {
+		return s.Pending() == 0
+	})
+	if saved := backend.saved(1); len(saved) != 1 || saved[0].Previous != nil {
+		t.Fatal("Save after a refused one was not a full save:", saved)
+	}
+
+	// the next save is diffed against the one that was given up on, so it has to be full too
+	s.PlayerSaveSnapshot(context.Background(), &world.PlayerSnapshot{Username: "refused", UsernameHash: 1, Previous: first})
+	eventually(t, func() bool {
+		return s.Pending() == 0
+	})
+	if saved := backend.saved(1); len(saved) != 2 || saved[1].Previous != nil {
+		t.Fatal("Save diffed against a refused one was not a full save:", saved)
+	}
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+	"github.com/spkaeros/rscgo/pkg/log"
+)
+
+//NewLoginHistoryServiceSql Returns a new login history service, which keeps every login attempt in the login_history
+// table of the default players database.  The table is created by the player database migrations.
+// Attempts are timestamped in milliseconds, since one account can see several of them within a second.
+func NewLoginHistoryServiceSql() world.LoginHistoryService {
+	s := newSqlService(config.PlayerDriver())
+	s.sqlOpen(config.PlayerDB())
+	return s
+}
+
+//NewLoginHistoryService Returns a new login history service, kept alongside the players in whatever dbio.conf
+// selects for them.
+func NewLoginHistoryService() world.LoginHistoryService {
+	if config.PlayerDriver() == FileDriver {
+		return NewLoginHistoryServiceFile()
+	}
+	return NewLoginHistoryServiceSql()
+}
+
+//LoginAdd Saves a new login attempt.
+// Returns true if successful, otherwise returns false.
+func (s *sqlService) LoginAdd(ctx context.Context, attempt *world.LoginAttempt) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
+	if _, err := database.ExecContext(ctx, "INSERT INTO login_history(userhash, time, ip, version, websocket, reason) VALUES($1, $2, $3, $4, $5, $6)",
+		int64(attempt.UserHash), attempt.Time.UnixNano()/int64(time.Millisecond), attempt.IP, attempt.Version, attempt.Websocket, attempt.Reason); err != nil {
+		log.Warning.Println("LoginAdd(): Could not insert login attempt:", err)
+		return false
+	}
+	return true
+}
+
+//LoginList Returns up to limit of the most recent login attempts on the provided account, newest first.
+func (s *sqlService) LoginList(ctx context.Context, userHash uint64, limit int) []*world.LoginAttempt {
+	return s.loginQuery(ctx, "SELECT userhash, time, ip, version, websocket, reason FROM login_history WHERE userhash=$1 ORDER BY time DESC LIMIT $2", int64(userHash), limit)
+}
+
+//LoginLast Returns the most recent successful login to the provided account, or nil if there never was one.
+func (s *sqlService) LoginLast(ctx context.Context, userHash uint64) *world.LoginAttempt {
+	attempts := s.loginQuery(ctx, "SELECT userhash, time, ip, version, websocket, reason FROM login_history WHERE userhash=$1 AND reason='' ORDER BY time DESC LIMIT 1", int64(userHash))
+	if len(attempts) == 0 {
+		return nil
+	}
+	return attempts[0]
+}
+
+func (s *sqlService) loginQuery(ctx context.Context, query string, args ...interface{}) (attempts []*world.LoginAttempt) {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
+	rows, err := database.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Warning.Println("Could not query login history:", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash, when int64
+		attempt := &world.LoginAttempt{}
+		if err := rows.Scan(&hash, &when, &attempt.IP, &attempt.Version, &attempt.Websocket, &attempt.Reason); err != nil {
+			log.Warning.Println("Could not read login attempt:", err)
+			continue
+		}
+		attempt.UserHash = uint64(hash)
+		attempt.Time = time.Unix(0, when*int64(time.Millisecond))
+		attempts = append(attempts, attempt)
+	}
+	return
+}
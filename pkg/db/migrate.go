@@ -0,0 +1,246 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/log"
+)
+
+//Migration A numbered change to a database schema, read from a file in a migrations directory.
+// Files are named NNNN_name.driver.sql, where driver is the database/sql driver they are written for, or
+// NNNN_name.sql for changes that any supported driver understands.  A driver-specific file takes precedence
+// over a shared one with the same number.
+type Migration struct {
+	Version int
+	Name    string
+	File    string
+	Source  string
+	//Unless A query given by a first line of "-- unless: <query>".  If it runs without an error, the database already
+	// has this change, and the migration is only recorded.  SQLite has no ADD COLUMN IF NOT EXISTS, for one.
+	Unless string
+}
+
+var migrationName = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+?)(?:\.([A-Za-z0-9]+))?\.sql$`)
+
+var migrationUnless = regexp.MustCompile(`^-- unless: (.+)`)
+
+//PlayerMigrations Returns the directory that migrations for the player database are kept in.
+func PlayerMigrations() string {
+	return filepath.Join(config.DataDir(), "migrations", "players")
+}
+
+//LoadMigrations Reads every migration in dir that applies to driver, ordered by version.
+// Returns the migrations and true on success, otherwise logs why and returns false.
+func LoadMigrations(dir, driver string) ([]Migration, bool) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Warning.Println("Could not read migrations directory (" + dir + "):", err)
+		return nil, false
+	}
+	versions := make(map[int]Migration)
+	for _, f := range files {
+		match := migrationName.FindStringSubmatch(f.Name())
+		if f.IsDir() || match == nil || (match[3] != "" && match[3] != driver) {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version <= 0 {
+			log.Warning.Println("Migration has a bad version number:", f.Name())
+			return nil, false
+		}
+		if existing, ok := versions[version]; ok {
+			if existing.Name != match[2] {
+				log.Warning.Println("Migrations " + existing.File + " and " + f.Name() + " share a version number")
+				return nil, false
+			}
+			if match[3] == "" {
+				// the driver-specific file wins
+				continue
+			}
+		}
+		source, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			log.Warning.Println("Could not read migration "+f.Name()+":", err)
+			return nil, false
+		}
+		m := Migration{Version: version, Name: match[2], File: f.Name(), Source: string(source)}
+		if unless := migrationUnless.FindStringSubmatch(m.Source); unless != nil {
+			m.Unless = strings.TrimSpace(unless[1])
+		}
+		versions[version] = m
+	}
+	migrations := make([]Migration, 0, len(versions))
+	for _, m := range versions {
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, true
+}
+
+//MigratePlayerDatabase Brings the player database up to the newest schema in PlayerMigrations.  With dryRun set, the
+// pending migrations are only logged, along with the SQL they would run.
+// Returns true if the database is (or, for a dry run, could be) up to date, otherwise false.
+func MigratePlayerDatabase(dryRun bool) bool {
+	if config.PlayerDriver() == FileDriver {
+		log.Debug("The " + FileDriver + " player driver has no schema to migrate")
+		return true
+	}
+	s := newSqlService(config.PlayerDriver())
+	if s.sqlOpen(config.PlayerDB()) == nil {
+		return false
+	}
+	defer func() {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.database.Close()
+	}()
+	return s.migrate(PlayerMigrations(), dryRun)
+}
+
+//migrate Applies every migration in dir newer than the databases schema_version, each in its own transaction.
+// Returns true if the database is up to date afterwards, otherwise false.
+func (s *sqlService) migrate(dir string, dryRun bool) bool {
+	migrations, ok := LoadMigrations(dir, s.Driver)
+	if !ok {
+		return false
+	}
+	database := s.connect(context.Background())
+	if database == nil {
+		return false
+	}
+	current := 0
+	if dryRun {
+		// a dry run leaves the database exactly as it was, so a database without schema_version is at version 0
+		if s.hasTable(database, "schema_version") {
+			if current, ok = s.schemaVersion(database); !ok {
+				return false
+			}
+		}
+	} else {
+		if _, err := database.ExecContext(context.Background(), "CREATE TABLE IF NOT EXISTS schema_version(version integer PRIMARY KEY, name text, applied bigint)"); err != nil {
+			log.Warning.Println("Could not create the schema_version table:", err)
+			return false
+		}
+		if current, ok = s.schemaVersion(database); !ok {
+			return false
+		}
+	}
+	if current == 0 && len(migrations) > 0 && s.hasTable(database, "player") {
+		// this database was made before schema_version existed, and the first migration describes what it looks like
+		if dryRun {
+			log.Debug("Would adopt the existing player database at " + migrations[0].File)
+		} else if !s.adopt(database, migrations[0]) {
+			return false
+		}
+		current = migrations[0].Version
+	}
+	pending := 0
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		pending++
+		done := len(m.Unless) > 0 && s.succeeds(database, m.Unless)
+		if dryRun {
+			if done {
+				log.Debug("Would record migration " + m.File + ", which the database already has")
+			} else {
+				log.Debug("Would apply migration " + m.File + ":\n" + m.Source)
+			}
+			continue
+		}
+		if !s.apply(database, m, !done) {
+			return false
+		}
+		if done {
+			log.Debug("Recorded migration " + m.File + ", which the database already had")
+		} else {
+			log.Debug("Applied migration " + m.File)
+		}
+	}
+	if dryRun {
+		log.Debug(strconv.Itoa(pending) + " pending migrations for the " + s.Driver + " database at schema version " + strconv.Itoa(current))
+	}
+	return true
+}
+
+//schemaVersion Returns the newest migration recorded in schema_version, or 0 if there are none.
+func (s *sqlService) schemaVersion(database *sql.Conn) (int, bool) {
+	var version sql.NullInt64
+	if err := database.QueryRowContext(context.Background(), "SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		log.Warning.Println("Could not read the schema version:", err)
+		return 0, false
+	}
+	return int(version.Int64), true
+}
+
+//hasTable Returns true if the named table exists in the database.
+func (s *sqlService) hasTable(database *sql.Conn, table string) bool {
+	return s.succeeds(database, "SELECT * FROM "+table+" LIMIT 1")
+}
+
+//succeeds Returns true if query runs without an error.
+func (s *sqlService) succeeds(database *sql.Conn, query string) bool {
+	rows, err := database.QueryContext(context.Background(), query)
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+//adopt Records m as applied to a database that predates schema_version, after running it for any of the tables
+// that older servers created lazily.
+func (s *sqlService) adopt(database *sql.Conn, m Migration) bool {
+	if _, err := database.ExecContext(context.Background(), m.Source); err != nil {
+		log.Warning.Println("Migration "+m.File+" failed:", err)
+		return false
+	}
+	if !s.apply(database, m, false) {
+		return false
+	}
+	log.Debug("Adopted the existing player database at migration " + m.File)
+	return true
+}
+
+//apply Runs a migration and records it in schema_version, in one transaction.  Without run, only records it.
+func (s *sqlService) apply(database *sql.Conn, m Migration, run bool) bool {
+	tx, err := database.BeginTx(context.Background(), nil)
+	if err != nil {
+		log.Warning.Println("Could not begin transaction for migration "+m.File+":", err)
+		return false
+	}
+	if run {
+		if _, err := tx.Exec(m.Source); err != nil {
+			log.Warning.Println("Migration "+m.File+" failed:", err)
+			if err := tx.Rollback(); err != nil {
+				log.Warning.Println("Migration "+m.File+" rollback failed:", err)
+			}
+			return false
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO schema_version(version, name, applied) VALUES($1, $2, $3)", m.Version, m.Name, time.Now().Unix()); err != nil {
+		log.Warning.Println("Could not record migration "+m.File+":", err)
+		if err := tx.Rollback(); err != nil {
+			log.Warning.Println("Migration "+m.File+" rollback failed:", err)
+		}
+		return false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Warning.Println("Could not commit migration "+m.File+":", err)
+		return false
+	}
+	return true
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"strconv"
 	"strings"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/spkaeros/rscgo/pkg/game/entity"
 
 	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/crypto"
 	"github.com/spkaeros/rscgo/pkg/errors"
 	"github.com/spkaeros/rscgo/pkg/game/world"
 	"github.com/spkaeros/rscgo/pkg/log"
@@ -17,24 +19,45 @@ import (
 )
 
 //PlayerService An interface for manipulating player save data.
+// Every method takes a context that cancels whatever it is waiting on the database for, and on top of that gives up
+// once the timeout configured for its kind of operation has passed.
 type PlayerService interface {
-	PlayerCreate(string, string, string) bool
-	PlayerNameExists(username string) bool
-	PlayerHasRecoverys(uint64) bool
-	PlayerValidLogin(uint64, string) bool
-	PlayerChangePassword(uint64, string) bool
-	PlayerLoadRecoverys(uint64) []string
-	PlayerLoad(*world.Player) bool
-	PlayerSave(*world.Player)
-	OnlineCount() int
+	PlayerCreate(context.Context, string, string, string) bool
+	PlayerNameExists(ctx context.Context, username string) bool
+	PlayerHasRecoverys(context.Context, uint64) bool
+	PlayerValidLogin(context.Context, uint64, string) bool
+	PlayerChangePassword(context.Context, uint64, string) bool
+	PlayerLoadRecoverys(context.Context, uint64) []string
+	PlayerRecoverysChanged(context.Context, uint64) (time.Time, bool)
+	PlayerValidRecovery(context.Context, uint64, []uint64) bool
+	SaveRecoveryQuestions(context.Context, uint64, []string, []uint64) bool
+	PlayerLoad(context.Context, *world.Player) bool
+	PlayerSave(context.Context, *world.Player) bool
+	PlayerSaveSnapshot(context.Context, *world.PlayerSnapshot) bool
+	OnlineCount(context.Context) int
 }
 
 //NewPlayerServiceSql Returns a new SqlPlayerService to manage the specified *sql.DB instance, configured against
-// the default players database.
+// the default players database.  Any pending migrations are applied to it first.
 func NewPlayerServiceSql() PlayerService {
 	s := newSqlService(config.PlayerDriver())
 	s.sqlOpen(config.PlayerDB())
-	return dbConn
+	if s.database == nil {
+		return s
+	}
+	if !s.migrate(PlayerMigrations(), false) {
+		log.Warning.Println("Could not bring the player database up to date; see the migrations in " + PlayerMigrations())
+	}
+	return s
+}
+
+//NewPlayerService Returns a new player service of the kind selected by player_driver in dbio.conf; either a file
+// service, for FileDriver, or an sqlService for anything else.
+func NewPlayerService() PlayerService {
+	if config.PlayerDriver() == FileDriver {
+		return NewPlayerServiceFile()
+	}
+	return NewPlayerServiceSql()
 }
 
 //DefaultPlayerService the default player save managing service in use by the game server
@@ -43,10 +66,12 @@ var DefaultPlayerService PlayerService
 
 //PlayerCreate Creates a new entry in the player SQLite3 database with the specified credentials.
 // Returns true if successful, otherwise returns false.
-func (s *sqlService) PlayerCreate(username, password, ip string) bool {
-	db := s.connect(context.Background())
+func (s *sqlService) PlayerCreate(ctx context.Context, username, password, ip string) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	db := s.connect(ctx)
 	// defer db.Close()
-	tx, err := db.BeginTx(context.Background(), nil)
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Info.Println("SQLiteService Could not begin transaction:", err)
 		return false
@@ -54,8 +79,9 @@ func (s *sqlService) PlayerCreate(username, password, ip string) bool {
 
 	var playerID int
 	if config.PlayerDriver() != "postgres" {
-		stmt, err := tx.Exec("INSERT INTO player(username, userhash, password, x, y, group_id) VALUES($1, $2, $3, 220, 445, 0)", username, strutil.Base37.Encode(username), password)
+		stmt, err := tx.ExecContext(ctx, "INSERT INTO player(username, userhash, password, x, y, group_id) VALUES($1, $2, $3, 220, 445, 0)", username, strutil.Base37.Encode(username), password)
 		if err != nil {
+			tx.Rollback()
 			log.Info.Println("SQLiteService Could not insert new player profile information:", err)
 			return false
 		}
@@ -67,7 +93,7 @@ func (s *sqlService) PlayerCreate(username, password, ip string) bool {
 		}
 		playerID = int(pID)
 	} else {
-		stmt := tx.QueryRow("INSERT INTO player(username, userhash, password, x, y, group_id) VALUES($1, $2, $3, 220, 445, 0) RETURNING id", username, strutil.Base37.Encode(username), password)
+		stmt := tx.QueryRowContext(ctx, "INSERT INTO player(username, userhash, password, x, y, group_id) VALUES($1, $2, $3, 220, 445, 0) RETURNING id", username, strutil.Base37.Encode(username), password)
 		err = stmt.Scan(&playerID)
 		if err != nil || playerID < 0 {
 			tx.Rollback()
@@ -76,34 +102,40 @@ func (s *sqlService) PlayerCreate(username, password, ip string) bool {
 		}
 	}
 
-	_, err = tx.Exec("INSERT INTO appearance VALUES($1, 2, 8, 14, 0, 1, 2)", playerID)
+	_, err = tx.ExecContext(ctx, "INSERT INTO appearance VALUES($1, 2, 8, 14, 0, 1, 2)", playerID)
 	if err != nil {
+		tx.Rollback()
 		log.Info.Println("PlayerCreate(): Could not insert new player profile information:", err)
 		return false
 	}
-	_, err = tx.Exec("INSERT INTO player_attr VALUES($1, 'lastIP', $2)", playerID, "s"+ip)
+	_, err = tx.ExecContext(ctx, "INSERT INTO player_attr VALUES($1, 'lastIP', $2)", playerID, "s"+ip)
 	if err != nil {
+		tx.Rollback()
 		log.Info.Println("PlayerCreate(): Could not insert new player profile information:", err)
 		return false
 	}
-	_, err = tx.Exec("INSERT INTO stats (playerid, num, cur, exp) VALUES ($1, 0, 1, 0), ($1, 1, 1, 0), "+
+	_, err = tx.ExecContext(ctx, "INSERT INTO stats (playerid, num, cur, exp) VALUES ($1, 0, 1, 0), ($1, 1, 1, 0), "+
 		"($1, 2, 1, 0), ($1, 3, 10, 1156), ($1, 4, 1, 0), ($1, 5, 1, 0), ($1, 6, 1, 0), ($1, 7, 1, 0), ($1, 8, 1, 0), "+
 		"($1, 9, 1, 0), ($1, 10, 1, 0), ($1, 11, 1, 0), ($1, 12, 1, 0), ($1, 13, 1, 0), ($1, 14, 1, 0), ($1, 15, 1, 0), "+
 		"($1, 16, 1, 0), ($1, 17, 1, 0)", playerID)
 	if err != nil {
+		tx.Rollback()
 		log.Info.Println("PlayerCreate(): Could not insert new player profile information:", err)
 		return false
 	}
-	_, err = tx.Exec("INSERT INTO bank (playerid, itemid, amount) VALUES ($1, 546, 96000), ($1, 373, 96000)", playerID)
+	_, err = tx.ExecContext(ctx, "INSERT INTO bank (playerid, position, itemid, amount) VALUES ($1, 0, 546, 96000), ($1, 1, 373, 96000)", playerID)
 	if err != nil {
+		tx.Rollback()
 		log.Info.Println("PlayerCreate(): Could not insert new player profile information:", err)
 		return false
 	}
 	// 12 inv slots remaining
-	_, err = tx.Exec("INSERT INTO inventory (playerid, itemid, amount) VALUES ($1, 1263, 1), ($1, 77, 1), ($1, 71, 1), "+
-		"($1, 6, 1), ($1, 7, 1), ($1, 8, 1), ($1, 9, 1), ($1, 316, 1), ($1, 198, 1), ($1, 185, 1), ($1, 184, 1), "+
-		"($1, 187, 1), ($1, 35, 100), ($1, 33, 100), ($1, 36, 100), ($1, 188, 1), ($1, 189, 1), ($1, 11, 100)", playerID)
+	_, err = tx.ExecContext(ctx, "INSERT INTO inventory (playerid, position, itemid, amount) VALUES ($1, 0, 1263, 1), ($1, 1, 77, 1), "+
+		"($1, 2, 71, 1), ($1, 3, 6, 1), ($1, 4, 7, 1), ($1, 5, 8, 1), ($1, 6, 9, 1), ($1, 7, 316, 1), ($1, 8, 198, 1), "+
+		"($1, 9, 185, 1), ($1, 10, 184, 1), ($1, 11, 187, 1), ($1, 12, 35, 100), ($1, 13, 33, 100), ($1, 14, 36, 100), "+
+		"($1, 15, 188, 1), ($1, 16, 189, 1), ($1, 17, 11, 100)", playerID)
 	if err != nil {
+		tx.Rollback()
 		log.Info.Println("PlayerCreate(): Could not insert new player profile information:", err)
 		return false
 	}
@@ -116,10 +148,12 @@ func (s *sqlService) PlayerCreate(username, password, ip string) bool {
 }
 
 //PlayerNameExists Returns true if there is a player with the name 'username' in the player database, otherwise returns false.
-func (s *sqlService) PlayerNameExists(username string) bool {
-	database := s.connect(context.Background())
+func (s *sqlService) PlayerNameExists(ctx context.Context, username string) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
 	// defer database.Close()
-	stmt, err := database.QueryContext(context.Background(), "SELECT id FROM player WHERE userhash=$1", strutil.Base37.Encode(username))
+	stmt, err := database.QueryContext(ctx, "SELECT id FROM player WHERE userhash=$1", strutil.Base37.Encode(username))
 	if err != nil {
 		log.Info.Println("UsernameTaken: Could not query player profile information:", err)
 		// return true just to be safe since we could not check
@@ -130,10 +164,12 @@ func (s *sqlService) PlayerNameExists(username string) bool {
 }
 
 //OnlineCount Returns number of online players total.
-func (s *sqlService) OnlineCount() int {
-	database := s.connect(context.Background())
+func (s *sqlService) OnlineCount(ctx context.Context) int {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
 	// defer database.Close()
-	stmt, err := database.QueryContext(context.Background(), "SELECT COUNT(id) FROM player WHERE loggedIn=TRUE")
+	stmt, err := database.QueryContext(ctx, "SELECT COUNT(id) FROM player WHERE loggedIn=TRUE")
 	if err != nil {
 		log.Info.Println("UsernameTaken: Could not query player profile information:", err)
 		// return true just to be safe since we could not check
@@ -150,9 +186,11 @@ func (s *sqlService) OnlineCount() int {
 	return 0
 }
 
-func (s *sqlService) PlayerUpdateStatus(id int, in bool) {
-	database := s.connect(context.Background())
-	result, err := database.ExecContext(context.Background(), "UPDATE player SET loggedIn=$1 WHERE id=$2", in, id)
+func (s *sqlService) PlayerUpdateStatus(ctx context.Context, id int, in bool) {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
+	result, err := database.ExecContext(ctx, "UPDATE player SET loggedIn=$1 WHERE id=$2", in, id)
 	if err != nil {
 		log.Info.Println("Load error: Could not prepare statement:", err)
 		return
@@ -165,24 +203,41 @@ func (s *sqlService) PlayerUpdateStatus(id int, in bool) {
 	return
 }
 
-//PlayerValidLogin Returns true if it finds a user with this username hash and password in the database, otherwise returns false
-func (s *sqlService) PlayerValidLogin(userHash uint64, password string) bool {
-	database := s.connect(context.Background())
+//PlayerValidLogin Returns true if it finds a user with this username hash in the database, and password is the plaintext
+// of its password hash, otherwise returns false.  Old or outdated hashes get replaced with a new one from crypto.Hash.
+func (s *sqlService) PlayerValidLogin(ctx context.Context, userHash uint64, password string) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
 	// defer database.Close()
-	rows, err := database.QueryContext(context.Background(), "SELECT id FROM player WHERE userhash=$1 AND password=$2", userHash, password)
-	if err != nil {
-		log.Info.Println("Validate: Could not validate user credentials:", err)
+	var hash string
+	if err := database.QueryRowContext(ctx, "SELECT password FROM player WHERE userhash=$1", userHash).Scan(&hash); err != nil {
+		if err != sql.ErrNoRows {
+			log.Info.Println("Validate: Could not validate user credentials:", err)
+		}
 		return false
 	}
-	defer rows.Close()
-	return rows.Next()
+	if !crypto.Verify(password, hash) {
+		return false
+	}
+	if crypto.NeedsRehash(hash) {
+		// legacy hash, or the hash settings have changed since; this is the only time we know the plaintext to fix it
+		if rehashed, err := crypto.Hash(password); err != nil {
+			log.Warning.Println("Validate: Could not rehash password:", err)
+		} else {
+			s.PlayerChangePassword(ctx, userHash, rehashed)
+		}
+	}
+	return true
 }
 
 //PlayerChangePassword Updates the players password to password in the database.
-func (s *sqlService) PlayerChangePassword(userHash uint64, password string) bool {
-	database := s.connect(context.Background())
+func (s *sqlService) PlayerChangePassword(ctx context.Context, userHash uint64, password string) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
 	// defer database.Close()
-	stmt, err := database.ExecContext(context.Background(), "UPDATE player SET password=$1 WHERE userhash=$2", password, userHash)
+	stmt, err := database.ExecContext(ctx, "UPDATE player SET password=$1 WHERE userhash=$2", password, userHash)
 	if err != nil {
 		log.Info.Println("PlayerChangePassword: Could not update player password:", err)
 		return false
@@ -196,10 +251,12 @@ func (s *sqlService) PlayerChangePassword(userHash uint64, password string) bool
 }
 
 //PlayerHasRecoverys Returns true if this username has recovery questions assigned to it, otherwise returns false.
-func (s *sqlService) PlayerHasRecoverys(userHash uint64) bool {
-	database := s.connect(context.Background())
+func (s *sqlService) PlayerHasRecoverys(ctx context.Context, userHash uint64) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
 	// defer database.Close()
-	rows, err := database.QueryContext(context.Background(), "SELECT question1 FROM recovery_questions WHERE userhash=$1", userHash)
+	rows, err := database.QueryContext(ctx, "SELECT question1 FROM recovery_questions WHERE userhash=$1", userHash)
 	if err != nil {
 		log.Info.Println("PlayerHasRecoverys: Could not search for recovery questions:", err)
 		return false
@@ -209,10 +266,12 @@ func (s *sqlService) PlayerHasRecoverys(userHash uint64) bool {
 }
 
 //PlayerLoadRecoverys Retrieves the recovery questions assigned to this username if any, otherwise returns nil
-func (s *sqlService) PlayerLoadRecoverys(userHash uint64) []string {
-	database := s.connect(context.Background())
+func (s *sqlService) PlayerLoadRecoverys(ctx context.Context, userHash uint64) []string {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
 	// defer database.Close()
-	rows, err := database.QueryContext(context.Background(), "SELECT question1, question2, question3, question4, question5 FROM recovery_questions WHERE userhash=$1", userHash)
+	rows, err := database.QueryContext(ctx, "SELECT question1, question2, question3, question4, question5 FROM recovery_questions WHERE userhash=$1", userHash)
 	if err != nil {
 		log.Info.Println("PlayerLoadRecoverys: Could not find recovery questions:", err)
 		return nil
@@ -232,18 +291,104 @@ func (s *sqlService) PlayerLoadRecoverys(userHash uint64) []string {
 	return nil
 }
 
-//SaveRecoveryQuestions Saves new recovery questions to the database.
-func (s *sqlService) SaveRecoveryQuestions(userHash uint64, questions []string, answers []uint64) {
+//PlayerRecoverysChanged Returns when the recovery questions assigned to this username were last set, and true, or
+// false if there aren't any.  Questions set before this was kept track of are returned as being set at the zero time.
+func (s *sqlService) PlayerRecoverysChanged(ctx context.Context, userHash uint64) (time.Time, bool) {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	database := s.connect(ctx)
+	var changed sql.NullInt64
+	if err := database.QueryRowContext(ctx, "SELECT changed FROM recovery_questions WHERE userhash=$1", userHash).Scan(&changed); err != nil {
+		if err != sql.ErrNoRows {
+			log.Info.Println("PlayerRecoverysChanged: Could not search for recovery questions:", err)
+		}
+		return time.Time{}, false
+	}
+	if !changed.Valid {
+		return time.Time{}, true
+	}
+	return time.Unix(changed.Int64, 0), true
+}
+
+//PlayerValidRecovery Returns true if answers are the answers to every one of the recovery questions assigned to
+// this username, in order, otherwise returns false.
+func (s *sqlService) PlayerValidRecovery(ctx context.Context, userHash uint64, answers []uint64) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	if len(answers) != 5 {
+		return false
+	}
+	database := s.connect(ctx)
+	hashes := make([]string, 5)
+	if err := database.QueryRowContext(ctx, "SELECT answer1, answer2, answer3, answer4, answer5 FROM recovery_questions WHERE userhash=$1", userHash).Scan(&hashes[0], &hashes[1], &hashes[2], &hashes[3], &hashes[4]); err != nil {
+		if err != sql.ErrNoRows {
+			log.Info.Println("PlayerValidRecovery: Could not find recovery answers:", err)
+		}
+		return false
+	}
+	valid := true
+	for i, hash := range hashes {
+		// check every answer either way, so that how long this takes doesn't give away which one was wrong
+		if !crypto.Verify(strconv.FormatUint(answers[i], 10), hash) {
+			valid = false
+		}
+	}
+	return valid
+}
 
+//SaveRecoveryQuestions Saves new recovery questions to the database, replacing any old ones.  The answers are
+// hashed the same way that passwords are before being saved.
+// Returns true if successful, otherwise returns false.
+func (s *sqlService) SaveRecoveryQuestions(ctx context.Context, userHash uint64, questions []string, answers []uint64) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	if len(questions) != 5 || len(answers) != 5 {
+		return false
+	}
+	var hashes []interface{}
+	for _, answer := range answers {
+		hash, err := crypto.Hash(strconv.FormatUint(answer, 10))
+		if err != nil {
+			log.Warning.Println("SaveRecoveryQuestions(): Could not hash answer:", err)
+			return false
+		}
+		hashes = append(hashes, hash)
+	}
+	database := s.connect(ctx)
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		log.Warning.Println("SaveRecoveryQuestions(): Could not begin transaction:", err)
+		return false
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_questions WHERE userhash=$1", userHash); err != nil {
+		tx.Rollback()
+		log.Warning.Println("SaveRecoveryQuestions(): Could not remove old recovery questions:", err)
+		return false
+	}
+	args := []interface{}{userHash, questions[0], questions[1], questions[2], questions[3], questions[4]}
+	args = append(append(args, hashes...), time.Now().Unix())
+	if _, err := tx.ExecContext(ctx, "INSERT INTO recovery_questions(userhash, question1, question2, question3, question4, question5, "+
+		"answer1, answer2, answer3, answer4, answer5, changed) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)", args...); err != nil {
+		tx.Rollback()
+		log.Warning.Println("SaveRecoveryQuestions(): Could not insert recovery questions:", err)
+		return false
+	}
+	if err := tx.Commit(); err != nil {
+		log.Warning.Println("SaveRecoveryQuestions(): Error committing transaction:", err)
+		return false
+	}
+	return true
 }
 
 //PlayerLoad Loads a player from the SQLite3 database, returns a login response code.
 // Returns: true on success, false on failure
-func (s *sqlService) PlayerLoad(player *world.Player) bool {
+func (s *sqlService) PlayerLoad(ctx context.Context, player *world.Player) bool {
+	ctx, cancel := withTimeout(ctx, config.LoadTimeout())
+	defer cancel()
 	loadProfile := func() error {
-		database := s.connect(context.Background())
+		database := s.connect(ctx)
 		// defer database.Close()
-		rows, err := database.QueryContext(context.Background(), "SELECT player.id, player.x, player.y, player.group_id, appearance.haircolour, appearance.topcolour, appearance.trousercolour, appearance.skincolour, appearance.head, appearance.body FROM player INNER JOIN appearance ON appearance.playerid=player.id AND player.userhash=$1", player.UsernameHash())
+		rows, err := database.QueryContext(ctx, "SELECT player.id, player.x, player.y, player.group_id, appearance.haircolour, appearance.topcolour, appearance.trousercolour, appearance.skincolour, appearance.head, appearance.body FROM player INNER JOIN appearance ON appearance.playerid=player.id AND player.userhash=$1", player.UsernameHash())
 		if err != nil {
 			log.Info.Println("Load error: Could not prepare statement:", err)
 			return errors.NewDatabaseError(err.Error())
@@ -262,10 +407,10 @@ func (s *sqlService) PlayerLoad(player *world.Player) bool {
 		return nil
 	}
 	loadAttributes := func() error {
-		database := s.connect(context.Background())
+		database := s.connect(ctx)
 		// defer database.Close()
 
-		rows, err := database.QueryContext(context.Background(), "SELECT name, value FROM player_attr WHERE player_id=$1", player.DatabaseIndex)
+		rows, err := database.QueryContext(ctx, "SELECT name, value FROM player_attr WHERE player_id=$1", player.DatabaseIndex)
 		if err != nil {
 			log.Info.Println("Load error: Could not prepare statement:", err)
 			return errors.NewDatabaseError(err.Error())
@@ -274,55 +419,17 @@ func (s *sqlService) PlayerLoad(player *world.Player) bool {
 		for rows.Next() {
 			var name, value string
 			rows.Scan(&name, &value)
-			switch value[0] {
-			case 'i':
-				val, err := strconv.ParseInt(value[1:], 10, 64)
-				if err != nil {
-					log.Info.Printf("Error loading int attribute[%v]: value=%v\n", name, value[1:])
-					log.Info.Println(err)
-				}
-				player.Attributes.SetVar(name, int(val))
-				break
-			case 'l':
-				val, err := strconv.ParseUint(value[1:], 10, 64)
-				if err != nil {
-					log.Info.Printf("Error loading long int attribute[%v]: value=%v\n", name, value[1:])
-					log.Info.Println(err)
-				}
-				player.Attributes.SetVar(name, uint(val))
-				break
-			case 'b':
-				val, err := strconv.ParseBool(value[1:])
-				if err != nil {
-					log.Info.Printf("Error loading boolean attribute[%v]: value=%v\n", name, value[1:])
-					log.Info.Println(err)
-				}
+			if val, ok := decodeAttribute(name, value); ok {
 				player.Attributes.SetVar(name, val)
-				break
-			case 's':
-				player.Attributes.SetVar(name, value[1:])
-				break
-			case 'd':
-				t, err := time.ParseDuration(value[1:])
-				if err != nil {
-					continue
-				}
-				player.Attributes.SetVar(name, time.Now().Add(t))
-			case 't':
-				t, err := time.ParseInLocation(time.RFC822, value[1:], time.Local)
-				if err != nil {
-					continue
-				}
-				player.Attributes.SetVar(name, t)
 			}
 		}
 		return nil
 	}
 	loadContactList := func(list string) error {
-		database := s.connect(context.Background())
+		database := s.connect(ctx)
 		// defer database.Close()
 
-		rows, err := database.QueryContext(context.Background(), "SELECT playerhash FROM contacts WHERE playerid=$1 AND type=$2", player.DatabaseIndex, list)
+		rows, err := database.QueryContext(ctx, "SELECT playerhash FROM contacts WHERE playerid=$1 AND type=$2", player.DatabaseIndex, list)
 		if err != nil {
 			log.Info.Println("Load error: Could not prepare statement:", err)
 			return errors.NewDatabaseError(err.Error())
@@ -345,9 +452,9 @@ func (s *sqlService) PlayerLoad(player *world.Player) bool {
 		return nil
 	}
 	loadInventory := func() error {
-		database := s.connect(context.Background())
+		database := s.connect(ctx)
 		// defer database.Close()
-		rows, err := database.QueryContext(context.Background(), "SELECT itemid, amount, wielded FROM inventory WHERE playerid=$1", player.DatabaseIndex)
+		rows, err := database.QueryContext(ctx, "SELECT itemid, amount, wielded FROM inventory WHERE playerid=$1 ORDER BY position", player.DatabaseIndex)
 		if err != nil {
 			log.Info.Println("Load error: Could not prepare statement:", err)
 			return errors.NewDatabaseError(err.Error())
@@ -357,24 +464,14 @@ func (s *sqlService) PlayerLoad(player *world.Player) bool {
 			var id, amt int
 			wielded := false
 			rows.Scan(&id, &amt, &wielded)
-			index := player.Inventory.Add(id, amt)
-			if e := definitions.Equip(id); e != nil && wielded {
-				player.Inventory.Get(index).Worn = true
-				player.Equips()[e.Position] = e.Sprite
-				player.SetAimPoints(player.AimPoints() + e.Aim)
-				player.SetPowerPoints(player.PowerPoints() + e.Power)
-				player.SetArmourPoints(player.ArmourPoints() + e.Armour)
-				player.SetMagicPoints(player.MagicPoints() + e.Magic)
-				player.SetPrayerPoints(player.PrayerPoints() + e.Prayer)
-				player.SetRangedPoints(player.RangedPoints() + e.Ranged)
-			}
+			loadItem(player, id, amt, wielded)
 		}
 		return nil
 	}
 	loadBank := func() error {
-		database := s.connect(context.Background())
+		database := s.connect(ctx)
 		// defer database.Close()
-		rows, err := database.QueryContext(context.Background(), "SELECT itemid, amount FROM bank WHERE playerid=$1", player.DatabaseIndex)
+		rows, err := database.QueryContext(ctx, "SELECT itemid, amount FROM bank WHERE playerid=$1 ORDER BY position", player.DatabaseIndex)
 		if err != nil {
 			log.Info.Println("Load error: Could not prepare statement:", err)
 			return errors.NewDatabaseError(err.Error())
@@ -388,9 +485,9 @@ func (s *sqlService) PlayerLoad(player *world.Player) bool {
 		return nil
 	}
 	loadStats := func() error {
-		database := s.connect(context.Background())
+		database := s.connect(ctx)
 		// defer database.Close()
-		rows, err := database.QueryContext(context.Background(), "SELECT cur, exp FROM stats WHERE playerid=$1 ORDER BY num", player.DatabaseIndex)
+		rows, err := database.QueryContext(ctx, "SELECT cur, exp FROM stats WHERE playerid=$1 ORDER BY num", player.DatabaseIndex)
 		if err != nil {
 			log.Info.Println("Load error: Could not prepare statement:", err)
 			return errors.NewDatabaseError(err.Error())
@@ -430,236 +527,299 @@ func (s *sqlService) PlayerLoad(player *world.Player) bool {
 	if err := loadStats(); err != nil {
 		return false
 	}
-	s.PlayerUpdateStatus(player.DatabaseIndex, true)
+	if changed, ok := s.PlayerRecoverysChanged(ctx, player.UsernameHash()); ok {
+		player.SetVar("recoveryChanged", changed)
+	}
+	s.PlayerUpdateStatus(ctx, player.DatabaseIndex, true)
 	return true
 }
 
-//PlayerSave Saves a player to the SQLite3 database.
-func (s *sqlService) PlayerSave(player *world.Player) {
-	db := s.connect(context.Background())
+//PlayerSave Saves a player to the SQLite3 database.  Returns true if the save was committed, otherwise returns false.
+func (s *sqlService) PlayerSave(ctx context.Context, player *world.Player) bool {
+	return s.PlayerSaveSnapshot(ctx, player.Snapshot())
+}
+
+//PlayerSaveSnapshot Saves a snapshot of a player to the SQLite3 database.  Returns true if the save was committed,
+// otherwise returns false.
+// When the snapshot has a Previous one, only the rows that differ from it get written; otherwise, every row the player
+// has is deleted and written out again.
+func (s *sqlService) PlayerSaveSnapshot(ctx context.Context, player *world.PlayerSnapshot) bool {
+	ctx, cancel := withTimeout(ctx, config.SaveTimeout())
+	defer cancel()
+	db := s.connect(ctx)
 	// defer db.Close()
-	tx, err := db.BeginTx(context.Background(), nil)
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		log.Info.Println("Save(): Could not begin transcaction for player update.")
-		return
+		return false
 	}
-	updateLocation := func() {
-		rs, err := tx.Exec("UPDATE player SET x=$1, y=$2 WHERE id=$3", player.X(), player.Y(), player.DatabaseIndex)
+	// set by any statement that fails; the transaction is rolled back at that point, so it can not be committed
+	failed := false
+	exec := func(what, query string, args ...interface{}) {
+		if failed {
+			return
+		}
+		rs, err := tx.ExecContext(ctx, query, args...)
 		if err != nil {
-			log.Warning.Println("Save(): UPDATE failed for player location:", err)
+			log.Warning.Println("Save(): Could not save player "+what+":", err)
+			failed = true
 			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction location rollback failed:", err)
+				log.Warning.Println("Save(): Transaction "+what+" rollback failed:", err)
 			}
 			return
 		}
-		count, _ := rs.RowsAffected()
-
-		if count <= 0 {
-			log.Info.Println("Save(): Affected nothing for location update!")
+		if strings.HasPrefix(query, "DELETE") {
+			return
+		}
+		if count, _ := rs.RowsAffected(); count <= 0 {
+			log.Info.Println("Save(): Affected nothing for player " + what + "!")
 		}
 	}
+	updateLocation := func() {
+		exec("location", "UPDATE player SET x=$1, y=$2 WHERE id=$3", player.X, player.Y, player.DatabaseIndex)
+	}
 	updateAppearance := func() {
 		// TODO: Should this just be attributes too??  Is that abusing the attributes table?
 		appearance := player.Appearance
-		rs, _ := tx.Exec("UPDATE appearance SET haircolour=$1, topcolour=$2, trousercolour=$3, skincolour=$4, head=$5, body=$6 WHERE playerid=$7", appearance.HeadColor, appearance.BodyColor, appearance.LegsColor, appearance.SkinColor, appearance.Head, appearance.Body, player.DatabaseIndex)
-		count, err := rs.RowsAffected()
-		if err != nil {
-			log.Warning.Println("Save(): UPDATE failed for player appearance:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction appearance rollback failed:", err)
+		exec("appearance", "UPDATE appearance SET haircolour=$1, topcolour=$2, trousercolour=$3, skincolour=$4, head=$5, body=$6 WHERE playerid=$7", appearance.HeadColor, appearance.BodyColor, appearance.LegsColor, appearance.SkinColor, appearance.Head, appearance.Body, player.DatabaseIndex)
+	}
+	insertAttribute := func(name string, value interface{}) {
+		exec("attribute", "INSERT INTO player_attr(player_id, name, value) VALUES($1, $2, $3)", player.DatabaseIndex, name, encodeAttribute(name, value))
+	}
+	updateAttribute := func(name string, value interface{}) {
+		exec("attribute", "UPDATE player_attr SET value=$1 WHERE player_id=$2 AND name=$3", encodeAttribute(name, value), player.DatabaseIndex, name)
+	}
+	deleteAttribute := func(name string) {
+		exec("attribute", "DELETE FROM player_attr WHERE player_id=$1 AND name=$2", player.DatabaseIndex, name)
+	}
+	insertContact := func(contactType string, hash uint64) {
+		exec(contactType, "INSERT INTO contacts(playerid, playerhash, type) VALUES($1, $2, $3)", player.DatabaseIndex, hash, contactType)
+	}
+	deleteContact := func(contactType string, hash uint64) {
+		exec(contactType, "DELETE FROM contacts WHERE playerid=$1 AND playerhash=$2 AND type=$3", player.DatabaseIndex, hash, contactType)
+	}
+	insertItem := func(slot int, item world.SavedItem) {
+		exec("items", "INSERT INTO inventory(playerid, position, itemid, amount, wielded) VALUES($1, $2, $3, $4, $5)", player.DatabaseIndex, slot, item.ID, item.Amount, item.Worn)
+	}
+	updateItem := func(slot int, item world.SavedItem) {
+		exec("items", "UPDATE inventory SET itemid=$1, amount=$2, wielded=$3 WHERE playerid=$4 AND position=$5", item.ID, item.Amount, item.Worn, player.DatabaseIndex, slot)
+	}
+	insertBank := func(slot int, item world.SavedItem) {
+		exec("bank items", "INSERT INTO bank(playerid, position, itemid, amount) VALUES($1, $2, $3, $4)", player.DatabaseIndex, slot, item.ID, item.Amount)
+	}
+	updateBank := func(slot int, item world.SavedItem) {
+		exec("bank items", "UPDATE bank SET itemid=$1, amount=$2 WHERE playerid=$3 AND position=$4", item.ID, item.Amount, player.DatabaseIndex, slot)
+	}
+	insertStat := func(idx, cur, exp int) {
+		exec("stats", "INSERT INTO stats(playerid, num, cur, exp) VALUES($1, $2, $3, $4)", player.DatabaseIndex, idx, cur, exp)
+	}
+	updateStat := func(idx, cur, exp int) {
+		exec("stats", "UPDATE stats SET cur=$1, exp=$2 WHERE playerid=$3 AND num=$4", cur, exp, player.DatabaseIndex, idx)
+	}
+	// writes the slots that differ between items and old, then drops any slots past the end of items
+	saveItems := func(table string, items, old []world.SavedItem, insert, update func(int, world.SavedItem)) {
+		for slot, item := range items {
+			if slot >= len(old) {
+				insert(slot, item)
+			} else if item != old[slot] {
+				update(slot, item)
 			}
-			return
 		}
-
-		if count <= 0 {
-			log.Info.Println("Save(): Affected nothing for appearance update!")
+		if len(items) < len(old) {
+			exec(table, "DELETE FROM "+table+" WHERE playerid=$1 AND position>=$2", player.DatabaseIndex, len(items))
 		}
 	}
-	clearAttributes := func() {
-		if _, err := tx.Exec("DELETE FROM player_attr WHERE player_id=$1", player.DatabaseIndex); err != nil {
-			log.Warning.Println("Save(): DELETE failed for player attribute:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction delete attributes rollback failed:", err)
-			}
-			return
+	// writes the differences between the contacts in hashes and old
+	saveContacts := func(contactType string, hashes, old []uint64) {
+		saved := make(map[uint64]bool)
+		for _, hash := range old {
+			saved[hash] = true
 		}
-	}
-	insertAttribute := func(name string, value interface{}) bool {
-		var val string
-		switch value.(type) {
-		case int64:
-			val = "i" + strconv.FormatInt(value.(int64), 10)
-		case int:
-			val = "i" + strconv.FormatInt(int64(value.(int)), 10)
-		case uint:
-			val = "l" + strconv.FormatUint(uint64(value.(uint)), 10)
-		case bool:
-			if v, ok := value.(bool); v && ok {
-				val = "b1"
-			} else {
-				val = "b0"
-			}
-		case string:
-			if v, ok := value.(string); ok {
-				val = "s" + v
-			}
-		case time.Time:
-			if strings.HasSuffix(name, "Timer") {
-				// Save timers as duration
-				if v, ok := value.(time.Time); ok {
-					val = "d" + time.Until(v).String()
-				}
-			} else {
-				if v, ok := value.(time.Time); ok {
-					val = "t" + v.Format(time.RFC822)
-				}
+		for _, hash := range hashes {
+			if !saved[hash] {
+				insertContact(contactType, hash)
 			}
+			saved[hash] = false
 		}
-		rs, _ := tx.Exec("INSERT INTO player_attr(player_id, name, value) VALUES($1, $2, $3)", player.DatabaseIndex, name, val)
-		count, err := rs.RowsAffected()
-		if err != nil {
-			log.Warning.Println("Save(): INSERT failed for player attribute:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction insert attribute rollback failed:", err)
+		for hash, removed := range saved {
+			if removed {
+				deleteContact(contactType, hash)
 			}
-			return false
 		}
+	}
 
-		if count <= 0 {
-			log.Info.Println("Save(): Affected nothing for attribute insertion!")
+	if prev := player.Previous; prev == nil {
+		exec("attributes", "DELETE FROM player_attr WHERE player_id=$1", player.DatabaseIndex)
+		exec("friends", "DELETE FROM contacts WHERE playerid=$1", player.DatabaseIndex)
+		exec("items", "DELETE FROM inventory WHERE playerid=$1", player.DatabaseIndex)
+		exec("bank items", "DELETE FROM bank WHERE playerid=$1", player.DatabaseIndex)
+		exec("stats", "DELETE FROM stats WHERE playerid=$1", player.DatabaseIndex)
+
+		updateLocation()
+		updateAppearance()
+		for name, value := range player.Attributes {
+			insertAttribute(name, value)
 		}
-		return false
-	}
-	clearContactList := func(contactType string) {
-		if _, err := tx.Exec("DELETE FROM contacts WHERE playerid=$1 AND type=$2", player.DatabaseIndex, contactType); err != nil {
-			log.Warning.Println("Save(): DELETE failed for player friends:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction delete friends rollback failed:", err)
-			}
-			return
+		for _, hash := range player.Friends {
+			insertContact("friend", hash)
 		}
-	}
-	insertContact := func(contactType string, hash uint64) {
-		rs, _ := tx.Exec("INSERT INTO contacts(playerid, playerhash, type) VALUES($1, $2, $3)", player.DatabaseIndex, hash, contactType)
-		count, err := rs.RowsAffected()
-		if err != nil {
-			log.Warning.Println("Save(): INSERT failed for player friends:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction insert friend rollback failed:", err)
-			}
-			return
+		for _, hash := range player.Ignores {
+			insertContact("ignore", hash)
 		}
-
-		if count <= 0 {
-			log.Info.Println("Save(): Affected nothing for friend insertion!")
+		for stat, levels := range player.Stats {
+			insertStat(stat, levels[0], levels[1])
 		}
-	}
-	clearItems := func() {
-		if _, err := tx.Exec("DELETE FROM inventory WHERE playerid=$1", player.DatabaseIndex); err != nil {
-			log.Warning.Println("Save(): DELETE failed for player inventory:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction delete inventory rollback failed:", err)
-			}
-			return
+		for slot, item := range player.Inventory {
+			insertItem(slot, item)
 		}
-	}
-	clearBank := func() {
-		if _, err := tx.Exec("DELETE FROM bank WHERE playerid=$1", player.DatabaseIndex); err != nil {
-			log.Warning.Println("Save(): DELETE failed for player bank:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction delete bank rollback failed:", err)
-			}
-			return
+		for slot, item := range player.Bank {
+			insertBank(slot, item)
 		}
-	}
-	insertItem := func(id, amt int, worn bool) {
-		rs, _ := tx.Exec("INSERT INTO inventory(playerid, itemid, amount, wielded) VALUES($1, $2, $3, $4)", player.DatabaseIndex, id, amt, worn)
-		count, err := rs.RowsAffected()
-		if err != nil {
-			log.Warning.Println("Save(): INSERT failed for player items:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction insert item rollback failed:", err)
+	} else {
+		if player.X != prev.X || player.Y != prev.Y {
+			updateLocation()
+		}
+		if player.Appearance != prev.Appearance {
+			updateAppearance()
+		}
+		if player.AttributeChanges != prev.AttributeChanges {
+			for name, value := range player.Attributes {
+				if old, ok := prev.Attributes[name]; !ok {
+					insertAttribute(name, value)
+				} else if encodeAttribute(name, value) != encodeAttribute(name, old) {
+					updateAttribute(name, value)
+				}
+			}
+			for name := range prev.Attributes {
+				if _, ok := player.Attributes[name]; !ok {
+					deleteAttribute(name)
+				}
+			}
+		} else {
+			// timers get saved as the time left on them, which changes even when nothing sets them
+			for name, value := range player.Attributes {
+				if _, ok := value.(time.Time); ok && strings.HasSuffix(name, "Timer") {
+					updateAttribute(name, value)
+				}
 			}
-			return
 		}
-
-		if count <= 0 {
-			log.Info.Println("Save(): Affected nothing for item insertion!")
+		if player.FriendChanges != prev.FriendChanges {
+			saveContacts("friend", player.Friends, prev.Friends)
 		}
-	}
-	insertBank := func(id, amt int) {
-		rs, _ := tx.Exec("INSERT INTO bank(playerid, itemid, amount) VALUES($1, $2, $3)", player.DatabaseIndex, id, amt)
-		count, err := rs.RowsAffected()
-		if err != nil {
-			log.Warning.Println("Save(): INSERT failed for player bank items:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction insert bank item rollback failed:", err)
+		saveContacts("ignore", player.Ignores, prev.Ignores)
+		for stat, levels := range player.Stats {
+			if levels != prev.Stats[stat] {
+				updateStat(stat, levels[0], levels[1])
 			}
-			return
 		}
-
-		if count <= 0 {
-			log.Info.Println("Save(): Affected nothing for bank item insertion!")
+		if player.InventoryChanges != prev.InventoryChanges {
+			saveItems("inventory", player.Inventory, prev.Inventory, insertItem, updateItem)
 		}
-	}
-	clearStats := func() {
-		if _, err := tx.Exec("DELETE FROM stats WHERE playerid=$1", player.DatabaseIndex); err != nil {
-			log.Warning.Println("Save(): DELETE failed for player stats:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction delete stats rollback failed:", err)
-			}
-			return
+		if player.BankChanges != prev.BankChanges {
+			saveItems("bank", player.Bank, prev.Bank, insertBank, updateBank)
 		}
 	}
-	insertStat := func(idx, cur, exp int) {
-		rs, _ := tx.Exec("INSERT INTO stats(playerid, num, cur, exp) VALUES($1, $2, $3, $4)", player.DatabaseIndex, idx, cur, exp)
-		count, err := rs.RowsAffected()
-		if err != nil {
-			log.Warning.Println("Save(): INSERT failed for player stats:", err)
-			if err := tx.Rollback(); err != nil {
-				log.Warning.Println("Save(): Transaction insert stat rollback failed:", err)
-			}
-			return
-		}
-
-		if count <= 0 {
-			log.Info.Println("Save(): Affected nothing for stat insertion!")
+	if !failed {
+		if _, err := tx.ExecContext(ctx, "UPDATE player SET loggedIn=$1 WHERE id=$2", player.Online, player.DatabaseIndex); err != nil {
+			log.Info.Println("Load error: Could not prepare statement:", err)
 		}
 	}
-	clearAttributes()
-	clearContactList("friend")
-	clearContactList("ignore")
-	clearItems()
-	clearBank()
-	clearStats()
+	if failed {
+		log.Warning.Println("Save(): Could not save player:", player.Username)
+		return false
+	}
 
-	updateLocation()
-	updateAppearance()
-	player.Attributes.Range(insertAttribute)
-	player.FriendList.ForEach(func(s string, b bool) bool {
-		insertContact("friend", strutil.Base37.Encode(s))
+	if err := tx.Commit(); err != nil {
+		log.Warning.Println("Save(): Error committing transaction for player update:", err)
 		return false
-	})
-	for _, hash := range player.IgnoreList {
-		insertContact("ignore", hash)
 	}
-	for stat := 0; stat < 18; stat++ {
-		insertStat(stat, player.Skills().Current(stat), player.Skills().Experience(stat))
+	return true
+}
+
+//encodeAttribute Returns a player attribute as it gets persisted: a letter for its type, followed by its value as
+// text.  Times in attributes named like fooTimer are persisted as how long is left until them, rather than as a date.
+func encodeAttribute(name string, value interface{}) string {
+	switch v := value.(type) {
+	case int64:
+		return "i" + strconv.FormatInt(v, 10)
+	case int:
+		return "i" + strconv.FormatInt(int64(v), 10)
+	case uint:
+		return "l" + strconv.FormatUint(uint64(v), 10)
+	case bool:
+		if v {
+			return "b1"
+		}
+		return "b0"
+	case string:
+		return "s" + v
+	case time.Time:
+		if strings.HasSuffix(name, "Timer") {
+			// Save timers as duration
+			return "d" + time.Until(v).String()
+		}
+		return "t" + v.Format(time.RFC822)
+	}
+	return ""
+}
+
+//decodeAttribute Returns the value of a player attribute persisted by encodeAttribute, and true, or false if it could
+// not be read.
+func decodeAttribute(name, value string) (interface{}, bool) {
+	if len(value) == 0 {
+		return nil, false
 	}
-	player.Inventory.Range(func(item *world.Item) bool {
-		insertItem(item.ID, item.Amount, item.Worn)
-		return true
-	})
-	player.Bank().Range(func(item *world.Item) bool {
-		insertBank(item.ID, item.Amount)
-		return true
-	})
-	_, err = tx.Exec("UPDATE player SET loggedIn=$1 WHERE id=$2", false, player.DatabaseIndex)
-	if err != nil {
-		log.Info.Println("Load error: Could not prepare statement:", err)
+	switch value[0] {
+	case 'i':
+		val, err := strconv.ParseInt(value[1:], 10, 64)
+		if err != nil {
+			log.Info.Printf("Error loading int attribute[%v]: value=%v\n", name, value[1:])
+			log.Info.Println(err)
+		}
+		return int(val), true
+	case 'l':
+		val, err := strconv.ParseUint(value[1:], 10, 64)
+		if err != nil {
+			log.Info.Printf("Error loading long int attribute[%v]: value=%v\n", name, value[1:])
+			log.Info.Println(err)
+		}
+		return uint(val), true
+	case 'b':
+		val, err := strconv.ParseBool(value[1:])
+		if err != nil {
+			log.Info.Printf("Error loading boolean attribute[%v]: value=%v\n", name, value[1:])
+			log.Info.Println(err)
+		}
+		return val, true
+	case 's':
+		return value[1:], true
+	case 'd':
+		t, err := time.ParseDuration(value[1:])
+		if err != nil {
+			return nil, false
+		}
+		return time.Now().Add(t), true
+	case 't':
+		t, err := time.ParseInLocation(time.RFC822, value[1:], time.Local)
+		if err != nil {
+			return nil, false
+		}
+		return t, true
 	}
+	return nil, false
+}
 
-	if err := tx.Commit(); err != nil {
-		log.Warning.Println("Save(): Error committing transaction for player update:", err)
+//loadItem Adds a persisted inventory item to the players inventory, and wields it if it was worn.
+func loadItem(player *world.Player, id, amount int, worn bool) {
+	index := player.Inventory.Add(id, amount)
+	if e := definitions.Equip(id); e != nil && worn {
+		player.Inventory.Get(index).Worn = true
+		player.Equips()[e.Position] = e.Sprite
+		player.SetAimPoints(player.AimPoints() + e.Aim)
+		player.SetPowerPoints(player.PowerPoints() + e.Power)
+		player.SetArmourPoints(player.ArmourPoints() + e.Armour)
+		player.SetMagicPoints(player.MagicPoints() + e.Magic)
+		player.SetPrayerPoints(player.PrayerPoints() + e.Prayer)
+		player.SetRangedPoints(player.RangedPoints() + e.Ranged)
 	}
 }
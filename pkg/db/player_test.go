@@ -0,0 +1,67 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spkaeros/rscgo/pkg/game/headless"
+	"github.com/spkaeros/rscgo/pkg/game/net/packets"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+)
+
+//BenchmarkPlayerSave Boots a headless server against a scratch copy of the player database, fills one players bank up
+// to its 192 slot capacity, and then compares how long a save takes when every row is rewritten, against one that only
+// writes what changed since the last save.  Between each save, one bank item and an attribute change, like they would
+// between autosaves.  headless.PlayerDriver picks the player driver that gets benchmarked.
+func BenchmarkPlayerSave(b *testing.B) {
+	s, err := headless.Boot()
+	if err != nil {
+		b.Fatal("Could not boot headless server:", err)
+	}
+	defer s.Close()
+	c, err := s.Login("benchmark", "benchmark")
+	if err != nil {
+		b.Fatal("Could not log in:", err)
+	}
+	if err := c.Send((&packets.Appearance{Male: true, Head: 0, Body: 1, Legs: 2, HairColour: 2}).Encode()); err != nil {
+		b.Fatal(err)
+	}
+	s.Advance(2)
+	player := c.Player
+	for id := player.Bank().Size(); player.Bank().Size() < 48*4; id++ {
+		if player.Bank().GetByID(id) == nil {
+			player.Bank().Add(id, 1)
+		}
+	}
+
+	// changes a little of everything, as a player might between autosaves
+	change := func(i int) {
+		player.Bank().Add(player.Bank().Get(i%player.Bank().Size()).ID, 1)
+		player.Attributes.SetVar("benchmarkSaves", i)
+	}
+	b.Run("full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			change(i)
+			if !world.DefaultPlayerService.PlayerSaveSnapshot(context.Background(), player.Snapshot()) {
+				b.Fatal("Save failed")
+			}
+		}
+	})
+	b.Run("incremental", func(b *testing.B) {
+		previous := player.Snapshot()
+		if !world.DefaultPlayerService.PlayerSaveSnapshot(context.Background(), previous) {
+			b.Fatal("Save failed")
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			change(i)
+			snapshot := player.Snapshot()
+			snapshot.Previous = previous
+			if !world.DefaultPlayerService.PlayerSaveSnapshot(context.Background(), snapshot) {
+				b.Fatal("Save failed")
+			}
+			snapshot.Previous = nil
+			previous = snapshot
+		}
+	})
+}
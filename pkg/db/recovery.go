@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/crypto"
+	"github.com/spkaeros/rscgo/pkg/game/net/handshake"
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//RecoveryResult The outcome of an attempt to recover an account with its recovery questions.
+type RecoveryResult byte
+
+const (
+	//RecoveryFailed The answers were wrong, or the account has no recovery questions.
+	RecoveryFailed RecoveryResult = iota
+	//RecoverySuccess The answers were right, and the password was changed.
+	RecoverySuccess
+	//RecoveryThrottled There were too many wrong answers from this address, or for this account, recently, so this
+	// attempt was not checked.
+	RecoveryThrottled
+	//RecoveryBadPassword The new password was not 5-20 characters long.
+	RecoveryBadPassword
+)
+
+//RecoveryQuestions Returns the recovery questions of the account with the provided username hash, for a client at ip
+// to answer, and RecoverySuccess, or RecoveryFailed if there aren't any, or RecoveryThrottled if ip is locked out of
+// recovering accounts.  Asking about an account without questions counts against ip like a wrong answer does, so
+// that nobody can go through every account looking for some to guess at.  The database gets until the login
+// timeout to answer.  This is used by both the game client and the website.
+func RecoveryQuestions(ctx context.Context, userHash uint64, ip string) ([]string, RecoveryResult) {
+	if handshake.RecoveryThrottle.Locked(ip) > 0 {
+		log.Debug("[RECOVERY]", strutil.Base37.Decode(userHash)+"@"+ip, "throttled")
+		return nil, RecoveryThrottled
+	}
+	ctx, cancel := withTimeout(ctx, config.LoginTimeout())
+	defer cancel()
+	questions := DefaultPlayerService.PlayerLoadRecoverys(ctx, userHash)
+	if len(questions) == 0 {
+		if ctx.Err() == nil {
+			// a database that took too long is not the clients fault
+			handshake.RecoveryThrottle.Add(ip)
+		}
+		log.Debug("[RECOVERY]", strutil.Base37.Decode(userHash)+"@"+ip, "asked about an account without recovery questions")
+		return nil, RecoveryFailed
+	}
+	return questions, RecoverySuccess
+}
+
+//RecoverAccount Changes the password of the account with the provided username hash to password, if answers are the
+// answers to its recovery questions.  The database gets until the login timeout to answer.  This is used by both the
+// game client and the website.
+func RecoverAccount(ctx context.Context, userHash uint64, answers []uint64, password, ip string) RecoveryResult {
+	account := handshake.AccountKey(userHash)
+	if handshake.RecoveryThrottle.Locked(ip) > 0 || handshake.RecoveryAccountThrottle.Locked(account) > 0 {
+		log.Debug("[RECOVERY]", strutil.Base37.Decode(userHash)+"@"+ip, "throttled")
+		return RecoveryThrottled
+	}
+	if len(password) < 5 || len(password) > 20 {
+		return RecoveryBadPassword
+	}
+	ctx, cancel := withTimeout(ctx, config.LoginTimeout())
+	defer cancel()
+	if !DefaultPlayerService.PlayerValidRecovery(ctx, userHash, answers) {
+		if ctx.Err() != nil {
+			log.Debug("[RECOVERY]", strutil.Base37.Decode(userHash)+"@"+ip, "could not have their answers checked:", ctx.Err())
+			return RecoveryFailed
+		}
+		handshake.RecoveryThrottle.Add(ip)
+		handshake.RecoveryAccountThrottle.Add(account)
+		log.Debug("[RECOVERY]", strutil.Base37.Decode(userHash)+"@"+ip, "gave wrong answers")
+		return RecoveryFailed
+	}
+	hash, err := crypto.Hash(password)
+	if err != nil {
+		log.Warn("[RECOVERY]", strutil.Base37.Decode(userHash)+"@"+ip, "could not hash new password:", err)
+		return RecoveryFailed
+	}
+	if !DefaultPlayerService.PlayerChangePassword(ctx, userHash, hash) {
+		return RecoveryFailed
+	}
+	handshake.RecoveryAccountThrottle.Reset(account)
+	log.Info.Println("[RECOVERY]", strutil.Base37.Decode(userHash)+"@"+ip, "recovered their account")
+	return RecoverySuccess
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"sync"
+	"time"
 
 	"github.com/spkaeros/rscgo/pkg/config"
 	"github.com/spkaeros/rscgo/pkg/log"
@@ -34,13 +35,13 @@ func (s *sqlService) sqlOpen(addr string) *sql.DB {
 }
 
 //sqlService A database/sql based persistence service.
-// Implements PlayerService interface and sqlService.
+// Implements PlayerService, world.BanService and world.LoginHistoryService.
 type sqlService struct {
 	database *sql.DB
 	conn     *sql.Conn
 	Driver   string
-	context  context.Context
 	sync.RWMutex
+	connecting sync.Once
 }
 
 //newSqlService returns a new sqlService instance attached to the provided *sql.DB
@@ -51,20 +52,37 @@ func newSqlService(driver string) *sqlService {
 	}
 }
 
-var dbConn *sqlService
-
 //connect returns a connection to the services underlying *sql.DB instance upon successful
 // connection.  If an error occurs, returns nil.
 func (s *sqlService) connect(ctx context.Context) *sql.Conn {
-	if dbConn == nil {
-		dbConn = newSqlService(config.PlayerDriver())
-		db := dbConn.sqlOpen(config.PlayerDB())
-		dbConn.database = db
-		c, err := db.Conn(ctx)
+	s.connecting.Do(func() {
+		if s.database == nil {
+			log.Error.Println("Couldn't connect to database (driver: " + s.Driver + "): it was never opened")
+			return
+		}
+		c, err := s.database.Conn(ctx)
 		if err != nil {
-			return nil
+			log.Error.Println("Couldn't connect to database (driver: "+s.Driver+"):", err)
+			return
 		}
-		dbConn.conn = c
+		s.conn = c
+	})
+	return s.conn
+}
+
+//ping Returns true if the connection the service works through still answers.
+func (s *sqlService) ping(ctx context.Context) bool {
+	ctx, cancel := withTimeout(ctx, config.QueryTimeout())
+	defer cancel()
+	conn := s.connect(ctx)
+	return conn != nil && conn.PingContext(ctx) == nil
+}
+
+//withTimeout Returns a context derived from ctx that is also cancelled once timeout has passed, and the function that
+// releases it.  A timeout of zero or less means the returned context only ends with ctx.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
-	return dbConn.conn
+	return context.WithTimeout(ctx, timeout)
 }
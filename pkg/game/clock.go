@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package game
+
+import (
+	"time"
+)
+
+//Clock A source of game engine ticks.  The server runs one tick every time Ticks produces a value, and calls Ticked
+// once that tick has been completely processed.
+type Clock interface {
+	Ticks() <-chan time.Time
+	Ticked()
+	Stop()
+}
+
+//Ticker A Clock that ticks on its own, in real time, at a fixed interval.
+type Ticker struct {
+	*time.Ticker
+}
+
+//NewTicker Returns a new Clock that ticks once every interval.
+func NewTicker(interval time.Duration) *Ticker {
+	return &Ticker{time.NewTicker(interval)}
+}
+
+func (t *Ticker) Ticks() <-chan time.Time {
+	return t.C
+}
+
+func (t *Ticker) Ticked() {}
+
+//ManualClock A Clock that only ticks when it is told to, so that the game engine can be stepped through one tick at
+// a time, e.g from tests.
+type ManualClock struct {
+	ticks  chan time.Time
+	ticked chan struct{}
+}
+
+//NewManualClock Returns a new Clock that ticks only when Advance is called.
+func NewManualClock() *ManualClock {
+	return &ManualClock{ticks: make(chan time.Time), ticked: make(chan struct{})}
+}
+
+//Advance Runs n ticks on the server using this clock, waiting for each tick to finish before starting the next one.
+func (c *ManualClock) Advance(n int) {
+	for i := 0; i < n; i++ {
+		c.ticks <- time.Now()
+		<-c.ticked
+	}
+}
+
+func (c *ManualClock) Ticks() <-chan time.Time {
+	return c.ticks
+}
+
+func (c *ManualClock) Ticked() {
+	c.ticked <- struct{}{}
+}
+
+func (c *ManualClock) Stop() {}
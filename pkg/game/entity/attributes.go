@@ -29,8 +29,9 @@ func (e entry) String() string {
 
 //AttributeList A concurrency-safe coection data type for storing misc. variabes by a descriptive name.
 type AttributeList struct {
-	set  map[string]interface{}
-	lock sync.RWMutex
+	set     map[string]interface{}
+	lock    sync.RWMutex
+	changes int
 }
 
 func NewAttributeList() *AttributeList {
@@ -145,9 +146,18 @@ func (a *AttributeList) Contains(name string) (ok bool) {
 func (a *AttributeList) SetVar(name string, value interface{}) {
 	a.lock.Lock()
 	a.set[name] = value
+	a.changes++
 	a.lock.Unlock()
 }
 
+//Changes Returns how many times an attribute has been set or removed in this collection.  A save can compare it to
+// the count it last persisted, to tell whether there is anything new to write.
+func (a *AttributeList) Changes() int {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	return a.changes
+}
+
 //Var Returns the attribute associated with name as a blank interface.  Needs to be cast to be useful, typically.
 func (a *AttributeList) Var(name string) (interface{}, bool) {
 	a.lock.RLock()
@@ -163,6 +173,7 @@ func (a *AttributeList) UnsetVar(name string) {
 	if _, ok := a.set[name]; ok {
 		a.set[name] = nil
 		delete(a.set, name)
+		a.changes++
 	}
 }
 
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package headless_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/game/world"
+)
+
+func TestBanAccount(t *testing.T) {
+	s := boot(t)
+	c := login(t, s, "banned")
+	player := c.Player
+	if !world.BanAccount(s.Context, "tester", "banned", time.Hour, "testing") {
+		t.Fatal("Could not save the ban")
+	}
+	defer world.RemoveBan(s.Context, "tester", "banned")
+	// banning logs out whoever it applies to
+	eventually(t, s, func() bool {
+		return !world.Players.Contains(player)
+	})
+	if _, err := s.Login("banned", "password"); err == nil {
+		t.Fatal("Banned account logged in")
+	}
+	if _, err := s.Login("notbanned", "password"); err != nil {
+		t.Fatal("Account ban kept out another account:", err)
+	}
+
+	if !world.RemoveBan(s.Context, "tester", "banned") {
+		t.Fatal("Ban was not lifted")
+	}
+	if _, err := s.Login("banned", "password"); err != nil {
+		t.Fatal("Could not log in after the ban was lifted:", err)
+	}
+}
+
+func TestBanAddress(t *testing.T) {
+	s := boot(t)
+	c := login(t, s, "bannedaddress")
+	ip := c.Player.CurrentIP()
+	if !world.BanAddress(s.Context, "tester", ip, 0, "testing") {
+		t.Fatal("Could not save the ban on", ip)
+	}
+	defer world.RemoveBan(s.Context, "tester", ip)
+	if world.FindBan(s.Context, 0, ip) == nil {
+		t.Fatal("Ban on", ip, "was not found right after it was placed")
+	}
+	if _, err := s.Login("fromaddress", "password"); err == nil {
+		t.Fatal("Logged in from a banned address")
+	}
+	if !world.RemoveBan(s.Context, "tester", ip) {
+		t.Fatal("Ban was not lifted")
+	}
+	if world.FindBan(s.Context, 0, ip) != nil {
+		t.Fatal("Ban on", ip, "was still found after it was lifted")
+	}
+	if _, err := s.Login("fromaddress", "password"); err != nil {
+		t.Fatal("Could not log in after the ban was lifted:", err)
+	}
+}
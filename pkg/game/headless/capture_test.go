@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package headless_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/capture"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+)
+
+//frames Reads every frame out of the capture file called name.
+func frames(t *testing.T, name string) []capture.Frame {
+	t.Helper()
+	r, err := capture.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var list []capture.Frame
+	for {
+		f, err := r.Read()
+		if err == io.EOF {
+			return list
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, f)
+	}
+}
+
+func TestCaptureNames(t *testing.T) {
+	s := boot(t)
+	c := login(t, s, "recaptured")
+	first, err := c.Player.StartCapture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Player.StopCapture()
+	second, err := c.Player.StartCapture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Player.StopCapture()
+	if first == second {
+		t.Fatal("Two captures were both written to", first)
+	}
+	// both can still be read back
+	frames(t, first)
+	frames(t, second)
+}
+
+func TestCaptureDropped(t *testing.T) {
+	s := boot(t)
+	c := loginVersion(t, s, 204, "capturedold")
+	name, err := c.Player.StartCapture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 204 has no fight mode packet, so it never gets sent
+	c.Player.WriteNow(*net.NewEmptyPacket(protocol.Outbound["fightMode"]).AddUint8(1))
+	c.Player.WriteNow(*net.NewEmptyPacket(protocol.Outbound["serverMessage"]).AddUint8(0).AddUint8(0).AddFramedString("Hello"))
+	c.Player.StopCapture()
+
+	sent := 0
+	for _, f := range frames(t, name) {
+		if f.Inbound {
+			continue
+		}
+		if f.Opcode == protocol.Outbound["fightMode"] {
+			t.Fatal("Capture has a packet that was never sent")
+		}
+		sent++
+	}
+	if sent != 1 {
+		t.Fatalf("Capture has %d outbound packets; wanted 1", sent)
+	}
+}
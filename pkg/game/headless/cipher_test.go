@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package headless_test
+
+import (
+	"testing"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/game/headless"
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/packets"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+)
+
+//cipher Makes clients of the server's own revision encrypt opcodes or not, until the test is over.
+func cipher(t *testing.T, enabled bool) {
+	revision := protocol.Find(config.Version())
+	if revision == nil {
+		t.Fatal("Server's own revision is not registered")
+	}
+	was := revision.Cipher
+	revision.Cipher = enabled
+	t.Cleanup(func() {
+		revision.Cipher = was
+	})
+}
+
+//exchange Sends a few hundred packets each way between c and the server, and fails the test if either side ever reads
+// an opcode that the other did not send.
+func exchange(t *testing.T, s *headless.Server, c *headless.Client) {
+	t.Helper()
+	known := make(map[byte]bool)
+	for _, opcode := range protocol.Outbound {
+		known[opcode] = true
+	}
+	const ticks = 60
+	sent, received, replies := 0, 0, 0
+	for tick := 0; tick < ticks; tick++ {
+		// as much as fits in the budgets of each packet, every tick
+		for _, p := range []*net.Packet{
+			(&packets.Command{Command: "nosuchcommand"}).Encode(),
+			net.NewEmptyPacket(protocol.Inbound["ping"]),
+			(&packets.Command{Command: "nosuchcommand"}).Encode(),
+			net.NewEmptyPacket(protocol.Inbound["ping"]),
+			(&packets.Command{Command: "nosuchcommand"}).Encode(),
+		} {
+			if err := c.Send(p); err != nil {
+				t.Fatal("Tick", tick, ":", err)
+			}
+			sent++
+		}
+		if err := c.Say("Hello world"); err != nil {
+			t.Fatal("Tick", tick, ":", err)
+		}
+		sent++
+		s.Advance(1)
+		for _, p := range c.Received() {
+			if !known[p.Opcode] {
+				t.Fatalf("Tick %d: received unknown opcode %d after %d packets", tick, p.Opcode, received)
+			}
+			if p.Opcode == protocol.Outbound["serverMessage"] {
+				replies++
+			}
+			received++
+		}
+	}
+	s.Advance(1)
+	for _, p := range c.Received() {
+		if !known[p.Opcode] {
+			t.Fatalf("Received unknown opcode %d after %d packets", p.Opcode, received)
+		}
+		if p.Opcode == protocol.Outbound["serverMessage"] {
+			replies++
+		}
+		received++
+	}
+	// every command has to come out right on the server, for it to be answered
+	if replies != ticks*3 {
+		t.Fatalf("Got %d replies to %d commands", replies, ticks*3)
+	}
+	if received < 200 {
+		t.Fatalf("Only received %d packets", received)
+	}
+	t.Log("Sent", sent, "packets and received", received)
+}
+
+func TestCipher(t *testing.T) {
+	s := boot(t)
+	cipher(t, true)
+	c := login(t, s, "ciphered")
+	if c.Player.OpCiphers[0] == nil || c.Player.OpCiphers[1] == nil {
+		t.Fatal("Player has no opcode ciphers")
+	}
+	exchange(t, s, c)
+}
+
+func TestNoCipher(t *testing.T) {
+	s := boot(t)
+	cipher(t, false)
+	c := login(t, s, "plain")
+	if c.Player.OpCiphers[0] != nil || c.Player.OpCiphers[1] != nil {
+		t.Fatal("Player has opcode ciphers")
+	}
+	exchange(t, s, c)
+}
+
+func TestCipherPerConnection(t *testing.T) {
+	s := boot(t)
+	cipher(t, true)
+	ciphered := login(t, s, "cipheredtoo")
+	cipher(t, false)
+	plain := login(t, s, "plaintoo")
+	if ciphered.Player.OpCiphers[0] == nil || plain.Player.OpCiphers[0] != nil {
+		t.Fatal("Opcode ciphers were not decided for each connection")
+	}
+	exchange(t, s, ciphered)
+	exchange(t, s, plain)
+}
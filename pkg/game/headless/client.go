@@ -0,0 +1,455 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package headless
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	stdnet "net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/crypto"
+	"github.com/spkaeros/rscgo/pkg/db"
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/handshake"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+	"github.com/spkaeros/rscgo/pkg/isaac"
+	"github.com/spkaeros/rscgo/pkg/rand"
+	"github.com/spkaeros/rscgo/pkg/rsa"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+	"github.com/spkaeros/rscgo/pkg/xtea"
+)
+
+//Client A fake game client, logged in to a headless Server.
+type Client struct {
+	//Player The servers side of this client.
+	Player   *world.Player
+	server   *Server
+	conn     stdnet.Conn
+	// encoder encrypts opcodes that we send, and decoder decrypts opcodes that we receive; both are nil unless
+	// opcodes are ciphered
+	encoder  *isaac.ISAAC
+	decoder  *isaac.ISAAC
+	response chan handshake.ResponseCode
+	lock     sync.Mutex
+	received []*net.Packet
+	err      error
+}
+
+//Login Connects a new client to the server and logs in with the provided credentials, creating the account first if
+// it does not exist yet.  When this returns without error, the player is in the world and has been initialized.
+func (s *Server) Login(username, password string) (*Client, error) {
+	return s.LoginVersion(config.Version(), username, password)
+}
+
+//LoginVersion Works like Login, but the client reports the provided revision, so that the server speaks that
+// revision's protocol to it.  Packets are sent and received with that revision's opcodes.
+func (s *Server) LoginVersion(version int, username, password string) (*Client, error) {
+	if !db.DefaultPlayerService.PlayerNameExists(s.Context, username) {
+		hash, err := crypto.Hash(password)
+		if err != nil {
+			return nil, err
+		}
+		if !db.DefaultPlayerService.PlayerCreate(s.Context, username, hash, "127.0.0.1") {
+			return nil, fmt.Errorf("headless: could not create account for %v", username)
+		}
+	}
+
+	keys := make([]int, 4)
+	for i := range keys {
+		keys[i] = int(uint32(rand.Int()))
+	}
+	conn, socket := s.pipe()
+	c := &Client{server: s, conn: conn, response: make(chan handshake.ResponseCode, 1)}
+	if revision := protocol.Find(version); revision != nil && revision.Cipher {
+		c.encoder, c.decoder = isaac.New(keys...), isaac.New(keys...)
+	}
+	go c.read()
+
+	s.Connect(socket)
+	written := make(chan error, 1)
+	go func() {
+		_, err := conn.Write(frame(0, loginBlock(version, keys, username, password)))
+		written <- err
+	}()
+	s.Advance(1)
+	if err := <-written; err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var response handshake.ResponseCode
+	// the login waits on the database away from the tick, then on the tick again to join the world
+	if !waitFor(func() bool {
+		select {
+		case response = <-c.response:
+			return true
+		default:
+			s.Advance(1)
+			return false
+		}
+	}) {
+		conn.Close()
+		return nil, fmt.Errorf("headless: no login response for %v", username)
+	}
+	if !response.IsValid() {
+		conn.Close()
+		return nil, fmt.Errorf("headless: login for %v was refused with response code %d", username, response)
+	}
+
+	hash := strutil.Base37.Encode(username)
+	if !waitFor(func() bool {
+		c.Player, _ = world.Players.FindHash(hash)
+		return c.Player != nil
+	}) {
+		conn.Close()
+		return nil, fmt.Errorf("headless: %v was accepted but never added to the world", username)
+	}
+	// the player gets initialized during the next tick
+	s.Advance(1)
+	return c, nil
+}
+
+//Register Connects a new client to the server and asks it to create an account with the provided credentials,
+// the same way that the real client does from its registration screen.  Returns the response code that was sent back.
+func (s *Server) Register(username, password string) (handshake.ResponseCode, error) {
+	reply := make([]byte, 1)
+	if err := s.request(2, registerBlock(username, password), reply); err != nil {
+		return 0, fmt.Errorf("headless: no registration response for %v: %v", username, err)
+	}
+	return handshake.ResponseCode(reply[0]), nil
+}
+
+//RecoveryQuestions Connects a new client to the server and asks it for the recovery questions of an account, the same
+// way that the real client does from its forgotten password screen.  Returns the questions that were sent back, which
+// there are none of if the account has no questions, or if the server would not say.
+func (s *Server) RecoveryQuestions(username string) ([]string, error) {
+	var questions []string
+	err := s.requestFunc(220, net.NewEmptyPacket(220).AddUint64(strutil.Base37.Encode(username)).FrameBuffer[1:], func(r io.Reader) error {
+		found := make([]byte, 1)
+		if _, err := io.ReadFull(r, found); err != nil || found[0] == 0 {
+			return err
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := io.ReadFull(r, found); err != nil {
+				return err
+			}
+			question := make([]byte, found[0])
+			if _, err := io.ReadFull(r, question); err != nil {
+				return err
+			}
+			questions = append(questions, string(question))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("headless: no recovery questions response for %v: %v", username, err)
+	}
+	return questions, nil
+}
+
+//Recover Connects a new client to the server and asks it to change the password of an account, using answers to
+// its recovery questions, the same way that the real client does from its forgotten password screen.
+// Returns the result that was sent back.
+func (s *Server) Recover(username, password string, answers []string) (db.RecoveryResult, error) {
+	reply := make([]byte, 1)
+	if err := s.request(221, recoverBlock(username, password, answers), reply); err != nil {
+		return 0, fmt.Errorf("headless: no recovery response for %v: %v", username, err)
+	}
+	return db.RecoveryResult(reply[0]), nil
+}
+
+//request Connects a new client to the server, and sends it a handshake packet that the server answers with as many
+// bytes as reply holds before disconnecting.  The answer is read into reply.
+func (s *Server) request(opcode byte, payload []byte, reply []byte) error {
+	return s.requestFunc(opcode, payload, func(r io.Reader) error {
+		_, err := io.ReadFull(r, reply)
+		return err
+	})
+}
+
+//requestFunc Connects a new client to the server, and sends it a handshake packet that the server answers before
+// disconnecting.  The answer is read with read.
+func (s *Server) requestFunc(opcode byte, payload []byte, read func(io.Reader) error) error {
+	conn, socket := s.pipe()
+	defer conn.Close()
+	s.Connect(socket)
+	written := make(chan error, 1)
+	go func() {
+		_, err := conn.Write(frame(opcode, payload))
+		written <- err
+	}()
+	response := make(chan error, 1)
+	go func() {
+		response <- read(conn)
+	}()
+	s.Advance(1)
+	if err := <-written; err != nil {
+		return err
+	}
+	var err error
+	if !waitFor(func() bool {
+		select {
+		case err = <-response:
+			return true
+		default:
+			return false
+		}
+	}) {
+		return errors.New("timed out")
+	}
+	return err
+}
+
+//pipe Returns both ends of a new fake connection; the servers end says that it comes from s.Address.
+func (s *Server) pipe() (stdnet.Conn, stdnet.Conn) {
+	conn, socket := stdnet.Pipe()
+	return conn, &remote{Conn: socket, addr: &stdnet.TCPAddr{IP: stdnet.ParseIP(s.Address), Port: 43594}}
+}
+
+//remote A connection from a made up address.
+type remote struct {
+	stdnet.Conn
+	addr stdnet.Addr
+}
+
+func (r *remote) RemoteAddr() stdnet.Addr {
+	return r.addr
+}
+
+//registerBlock Builds the payload of a registration packet, the same way that the real client does.
+func registerBlock(username, password string) []byte {
+	secure := []byte{10}
+	// both credentials get padded out to 19 characters, and then terminated
+	for _, s := range []string{username, password} {
+		if len(s) > 19 {
+			s = s[:19]
+		}
+		secure = append(secure, []byte(s+strings.Repeat(" ", 19-len(s)))...)
+		secure = append(secure, 10)
+	}
+	secure = rsa.RsaKeyPair.Encrypt(append(secure, rand.Bytes(8)...))
+
+	register := net.NewEmptyPacket(2)
+	register.AddUint32(uint32(config.Version()))
+	register.AddUint16(uint16(len(secure)))
+	register.AddBytes(secure)
+	return register.FrameBuffer[1:]
+}
+
+//recoverBlock Builds the payload of a recovery attempt packet, the same way that the real client does.
+func recoverBlock(username, password string, answers []string) []byte {
+	keys := make([]int, 4)
+	secure := []byte{10}
+	for i := range keys {
+		keys[i] = int(uint32(rand.Int()))
+		secure = append(secure, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(secure[len(secure)-4:], uint32(keys[i]))
+	}
+	if len(password) > 19 {
+		password = password[:19]
+	}
+	secure = append(secure, []byte(password+strings.Repeat(" ", 19-len(password)))...)
+	secure = append(secure, 10)
+	secure = rsa.RsaKeyPair.Encrypt(append(secure, rand.Bytes(8)...))
+
+	// the username and every answer, base37 hashed
+	block := make([]byte, 48)
+	binary.BigEndian.PutUint64(block, strutil.Base37.Encode(username))
+	for i := 0; i < 5 && i < len(answers); i++ {
+		binary.BigEndian.PutUint64(block[8+i*8:], strutil.Base37.Encode(answers[i]))
+	}
+	block = xtea.New(keys).Encrypt(block)
+
+	recover := net.NewEmptyPacket(221)
+	recover.AddUint32(uint32(config.Version()))
+	recover.AddUint16(uint16(len(secure)))
+	recover.AddBytes(secure)
+	recover.AddUint16(uint16(len(block)))
+	recover.AddBytes(block)
+	return recover.FrameBuffer[1:]
+}
+
+//loginBlock Builds the payload of a login packet, the same way that the real client does.
+func loginBlock(version int, keys []int, username, password string) []byte {
+	secure := []byte{10}
+	for _, key := range keys {
+		secure = append(secure, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(secure[len(secure)-4:], uint32(key))
+	}
+	// password gets padded out to 19 characters, and then terminated
+	if len(password) > 19 {
+		password = password[:19]
+	}
+	secure = append(secure, []byte(password+strings.Repeat(" ", 19-len(password)))...)
+	secure = append(secure, 10)
+	secure = rsa.RsaKeyPair.Encrypt(append(secure, rand.Bytes(8)...))
+
+	// limit30 flag, then 24 bytes of noise, then the null terminated username
+	block := append([]byte{0}, rand.Bytes(24)...)
+	block = xtea.New(keys).Encrypt(append(append(block, username...), 0))
+
+	login := net.NewEmptyPacket(0)
+	login.AddBoolean(false)
+	login.AddUint32(uint32(version))
+	login.AddUint16(uint16(len(secure)))
+	login.AddBytes(secure)
+	login.AddUint16(uint16(len(block)))
+	login.AddBytes(block)
+	return login.FrameBuffer[1:]
+}
+
+//frame Frames an opcode and its payload for the wire.
+func frame(opcode byte, payload []byte) []byte {
+	data := append([]byte{opcode}, payload...)
+	if len(data) >= 160 {
+		return append([]byte{byte(len(data)>>8 + 160), byte(len(data))}, data...)
+	}
+	return append([]byte{byte(len(data)), data[len(data)-1]}, data[:len(data)-1]...)
+}
+
+//read Reads everything the server sends to this client; first the login response, then every packet after that.
+func (c *Client) read() {
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(c.conn, response); err != nil {
+		c.fail(err)
+		return
+	}
+	c.response <- handshake.ResponseCode(response[0])
+	header := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			c.fail(err)
+			return
+		}
+		length := int(header[0])
+		if length >= 160 {
+			length = (length-160)<<8 | int(header[1])
+		} else {
+			length--
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(c.conn, data); err != nil {
+			c.fail(err)
+			return
+		}
+		if length < 160 {
+			data = append(data, header[1])
+		}
+		opcode := data[0]
+		if c.decoder != nil {
+			opcode = byte(uint32(opcode) - c.decoder.Uint32())
+		}
+		c.lock.Lock()
+		c.received = append(c.received, net.NewPacket(opcode, data[1:]))
+		c.lock.Unlock()
+	}
+}
+
+func (c *Client) fail(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+//Send Sends a packet to the server, and waits until the server has queued it up to be handled on the next tick.
+func (c *Client) Send(p *net.Packet) error {
+	queued := len(c.Player.InQueue)
+	opcode := p.Opcode
+	if c.encoder != nil {
+		opcode = byte(uint32(opcode) + c.encoder.Uint32())
+	}
+	if _, err := c.conn.Write(frame(opcode, p.FrameBuffer[1:])); err != nil {
+		return err
+	}
+	if !waitFor(func() bool {
+		return len(c.Player.InQueue) > queued
+	}) {
+		return fmt.Errorf("headless: server never queued packet %d", p.Opcode)
+	}
+	return nil
+}
+
+//SendRaw Writes data to the server exactly as it is, without framing or ciphering it, for sending what the real
+// client never would.
+func (c *Client) SendRaw(data []byte) error {
+	_, err := c.conn.Write(data)
+	return err
+}
+
+//Err Returns why the connection was lost, or nil if it is still up.
+func (c *Client) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+//Walk Asks to walk to the tile at x,y.
+func (c *Client) Walk(x, y int) error {
+	return c.Send(net.NewEmptyPacket(187).AddUint16(uint16(x)).AddUint16(uint16(y)))
+}
+
+//Say Says msg out loud in the public chat.
+func (c *Client) Say(msg string) error {
+	return c.Send(net.NewEmptyPacket(216).AddEncryptedString(msg))
+}
+
+//TalkTo Asks to talk to npc.
+func (c *Client) TalkTo(npc *world.NPC) error {
+	return c.Send(net.NewEmptyPacket(153).AddUint16(uint16(npc.ServerIndex())))
+}
+
+//Received Returns every packet that has been received so far, oldest first, and forgets about them.
+func (c *Client) Received() []*net.Packet {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	received := c.received
+	c.received = nil
+	return received
+}
+
+//ExpectPacket Looks for a packet with the provided opcode, running ticks until one shows up, or Timeout passes.
+// The packet that was found is returned, and it along with every packet received before it is forgotten about.
+func (c *Client) ExpectPacket(opcode byte) (*net.Packet, error) {
+	deadline := time.Now().Add(Timeout)
+	for {
+		c.lock.Lock()
+		for i, p := range c.received {
+			if p.Opcode == opcode {
+				c.received = c.received[i+1:]
+				c.lock.Unlock()
+				return p, nil
+			}
+		}
+		err := c.err
+		c.lock.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("headless: connection lost while waiting on packet %d: %v", opcode, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("headless: packet %d was not received within %v", opcode, Timeout)
+		}
+		c.server.Advance(1)
+		// scripts run on their own goroutines, which need a chance to catch up with the ticks
+		time.Sleep(time.Millisecond)
+	}
+}
+
+//Close Disconnects this client from the server, as if the connection dropped.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package headless_test
+
+import (
+	"testing"
+
+	"github.com/spkaeros/rscgo/pkg/game/net/packets"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+)
+
+func TestFloodBudget(t *testing.T) {
+	s := boot(t)
+	c := login(t, s, "flooder")
+	// commands have a budget of 3 per tick, and every unknown one gets a message back
+	for i := 0; i < 6; i++ {
+		if err := c.Send((&packets.Command{Command: "nosuchcommand"}).Encode()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Advance(2)
+	messages := 0
+	for _, p := range c.Received() {
+		if p.Opcode == 131 {
+			messages++
+		}
+	}
+	if messages != 3 {
+		t.Fatal("Got", messages, "replies to 6 commands sent in one tick, wanted 3")
+	}
+
+	// the budget starts over every tick
+	if err := c.Send((&packets.Command{Command: "nosuchcommand"}).Encode()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExpectPacket(131); err != nil {
+		t.Fatal(err)
+	}
+	if !world.Players.Contains(c.Player) {
+		t.Fatal("Player was disconnected for going over budget once")
+	}
+}
+
+func TestFloodDisconnect(t *testing.T) {
+	s := boot(t)
+	c := login(t, s, "spammer")
+	player := c.Player
+	// chat has a budget of 2 per tick, and going over on too many ticks in a row gets the client disconnected
+	for tick := 0; tick < 30 && world.Players.Contains(player); tick++ {
+		for i := 0; i < 3; i++ {
+			if err := c.Say("spam"); err != nil {
+				break
+			}
+		}
+		s.Advance(1)
+		if tick == 0 && !world.Players.Contains(player) {
+			t.Fatal("Player was disconnected for going over budget once")
+		}
+	}
+	eventually(t, s, func() bool {
+		return !world.Players.Contains(player)
+	})
+}
+
+func TestFrameLength(t *testing.T) {
+	s := boot(t)
+	c := login(t, s, "bigframe")
+	player := c.Player
+	// a frame header that claims to be 20000 bytes long
+	if err := c.SendRaw([]byte{160 + 20000>>8, 20000 & 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, s, func() bool {
+		return !world.Players.Contains(player) || player.Lingering()
+	})
+	eventually(t, s, func() bool {
+		return c.Err() != nil
+	})
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+//Package headless runs a complete game server inside of the current process, with no network listeners, for
+// exercising the server and its scripts from Go code, e.g from tests.
+//
+// The server is driven by a manual clock, so nothing happens in the world until Advance is called, and clients are
+// fake connections made with net.Pipe that speak the same protocol as the real client, login block and all.
+//
+//	server, err := headless.Boot()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer server.Close()
+//	client, err := server.Login("tester", "password")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	client.Say("Hello world")
+//	if _, err := client.ExpectPacket(234); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// The world is global state, so it is loaded once per process, and only one server should be booted at a time.
+package headless
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/db"
+	"github.com/spkaeros/rscgo/pkg/game"
+	"github.com/spkaeros/rscgo/pkg/game/net/handshake"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+	"github.com/spkaeros/rscgo/pkg/rsa"
+)
+
+//Server A game server running in this process, which only ticks when told to.
+type Server struct {
+	*game.Server
+	Clock *game.ManualClock
+	//Address The IP address that new clients appear to connect from.
+	Address string
+	// dir holds this servers players and throttles, and goes away once it is closed
+	dir string
+}
+
+var (
+	loading sync.Once
+	loadErr error
+)
+
+//Timeout How long to wait on the server for anything to happen, such as a client being added to the world after its
+// login has been accepted, or a packet showing up while ticks are run.
+var Timeout = time.Second * 5
+
+//PlayerDriver The player_driver that booted servers keep players with.  With sqlite3 they get a throwaway copy of the
+// player database, and with db.FileDriver a throwaway directory, which needs no SQL driver at all.
+var PlayerDriver = "sqlite3"
+
+//Boot Loads the world, if it has not been loaded yet, and returns a new running server.
+// The world is loaded from the data and scripts directories of the repository that the working directory is in.
+// Every server starts out the same: players are saved to a throwaway copy of the player database, or directory (see
+// PlayerDriver), nothing is throttled, and nobody is left in the world from a server that was booted before it.
+func Boot() (*Server, error) {
+	loading.Do(func() {
+		loadErr = load()
+	})
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	dir, err := reset()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{Clock: game.NewManualClock(), Address: "127.0.0.1", dir: dir}
+	s.Server = game.NewServer(s.Clock)
+	go s.Start()
+	return s, nil
+}
+
+func load() error {
+	root, err := findRoot()
+	if err != nil {
+		return err
+	}
+	// scripts and data files are all loaded relative to the working directory
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	rsa.LoadKeyPair()
+
+	config.TomlConfig.DataDir = "./data/"
+	config.TomlConfig.PacketHandlerFile = config.TomlConfig.DataDir + "packets.toml"
+	config.TomlConfig.RevisionFile = config.TomlConfig.DataDir + "revisions.toml"
+	if _, err := toml.DecodeFile("config.toml", &config.TomlConfig); err != nil {
+		return err
+	}
+	config.TomlConfig.Database.WorldDriver = "sqlite3"
+	config.TomlConfig.Database.WorldDB = "file:" + filepath.Join(config.TomlConfig.DataDir, "world.db")
+
+	db.ConnectEntityService()
+	game.LoadWorld()
+	return nil
+}
+
+//reset Gives the next server a new throwaway directory to keep its players and throttles in, and clears out whatever
+// the last server left behind.  Returns the directory.
+func reset() (string, error) {
+	dir, err := ioutil.TempDir("", "rscgo-headless")
+	if err != nil {
+		return "", err
+	}
+	config.TomlConfig.Database.PlayerDriver = PlayerDriver
+	if PlayerDriver == db.FileDriver {
+		config.TomlConfig.Database.PlayerDB = filepath.Join(dir, "players")
+	} else {
+		players, err := ioutil.ReadFile(filepath.Join(config.TomlConfig.DataDir, "players.db"))
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		playersDB := filepath.Join(dir, "players.db")
+		if err := ioutil.WriteFile(playersDB, players, 0644); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		config.TomlConfig.Database.PlayerDB = "file:" + playersDB
+	}
+	db.DefaultPlayerService = db.NewPlayerService()
+	world.DefaultPlayerService = db.DefaultPlayerService
+	world.DefaultBanService = db.NewBanService()
+	world.DefaultLoginHistoryService = db.NewLoginHistoryService()
+	config.TomlConfig.Throttle.Directory = dir
+	config.TomlConfig.CaptureDirectory = dir
+	handshake.ConfigureThrottles()
+
+	var left []*world.Player
+	world.Players.Range(func(p *world.Player) {
+		left = append(left, p)
+	})
+	for _, p := range left {
+		world.RemovePlayer(p)
+	}
+	world.UpdateTime = time.Time{}
+	return dir, nil
+}
+
+//findRoot Returns the closest directory to the working directory, walking up towards the file system root, that has
+// the game data and scripts in it.
+func findRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "data", "packets.toml")); err == nil {
+			if _, err := os.Stat(filepath.Join(dir, "scripts")); err == nil {
+				return dir, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("headless: could not find the game data and scripts directories")
+		}
+		dir = parent
+	}
+}
+
+//Advance Runs n game engine ticks.
+func (s *Server) Advance(n int) {
+	s.Clock.Advance(n)
+}
+
+//Close Logs out and saves every player, then stops the server and throws its players away.
+// Returns the servers exit status, which is non-zero if any player could not be saved.
+func (s *Server) Close() int {
+	status := s.Shutdown()
+	os.RemoveAll(s.dir)
+	return status
+}
+
+//waitFor Polls until cond returns true, or Timeout passes.  Returns the last result of cond.
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(Timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package headless_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/db"
+	"github.com/spkaeros/rscgo/pkg/game/headless"
+	"github.com/spkaeros/rscgo/pkg/game/net/handshake"
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/packets"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+)
+
+//boot Boots a headless server that gets closed again once the test is over.
+func boot(t *testing.T) *headless.Server {
+	t.Helper()
+	s, err := headless.Boot()
+	if err != nil {
+		t.Fatal("Could not boot headless server:", err)
+	}
+	t.Cleanup(func() {
+		if status := s.Close(); status != 0 {
+			t.Error("Server could not save every player; exit status", status)
+		}
+	})
+	return s
+}
+
+//login Logs a new client in as username, and picks a look for it, as new accounts can do nothing else until they have.
+func login(t *testing.T, s *headless.Server, username string) *headless.Client {
+	t.Helper()
+	return loginVersion(t, s, config.Version(), username)
+}
+
+//loginVersion Works like login, but the client reports the provided revision.
+func loginVersion(t *testing.T, s *headless.Server, version int, username string) *headless.Client {
+	t.Helper()
+	c, err := s.LoginVersion(version, username, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send((&packets.Appearance{Male: true, Head: 0, Body: 1, Legs: 2, HairColour: 4}).Encode()); err != nil {
+		t.Fatal(err)
+	}
+	s.Advance(1)
+	c.Received()
+	return c
+}
+
+func TestLogin(t *testing.T) {
+	s := boot(t)
+	c, err := s.Login("login", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := world.Players.FindHash(c.Player.UsernameHash()); !ok || p != c.Player {
+		t.Fatal("Player was not added to the world")
+	}
+	// the welcome box gets sent when the player is initialized
+	if _, err := c.ExpectPacket(182); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Login("login", "wrong password"); err == nil {
+		t.Fatal("Logged in with the wrong password")
+	}
+}
+
+func TestSay(t *testing.T) {
+	s := boot(t)
+	speaker := login(t, s, "speaker")
+	listener := login(t, s, "listener")
+	s.Advance(2)
+	listener.Received()
+	s.Advance(2)
+	for _, p := range listener.Received() {
+		if p.Opcode == 234 {
+			t.Fatal("Got player appearances without anything changing")
+		}
+	}
+	if err := speaker.Say("Hello world"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listener.ExpectPacket(234); err != nil {
+		t.Fatal("Chat was not sent to nearby players:", err)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	s := boot(t)
+	c := login(t, s, "walker")
+	x, y := c.Player.X(), c.Player.Y()-1
+	if err := c.Walk(x, y); err != nil {
+		t.Fatal(err)
+	}
+	s.Advance(4)
+	if c.Player.X() != x || c.Player.Y() != y {
+		t.Fatalf("Walked to %d,%d; wanted %d,%d", c.Player.X(), c.Player.Y(), x, y)
+	}
+	// everyone is told where the player went
+	if _, err := c.ExpectPacket(191); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTalkTo(t *testing.T) {
+	s := boot(t)
+	c := login(t, s, "talker")
+	// a banker who stands still two tiles south, so that nothing can get in the way
+	x, y := c.Player.X(), c.Player.Y()+2
+	banker := world.NewNpc(95, x, y, x, x, y, y)
+	world.AddNpc(banker)
+	defer world.RemoveNpc(banker)
+
+	if err := c.TalkTo(banker); err != nil {
+		t.Fatal(err)
+	}
+	// the banker greets the player, and then asks what they want
+	if _, err := c.ExpectPacket(104); err != nil {
+		t.Fatal("Banker never said anything:", err)
+	}
+	if _, err := c.ExpectPacket(245); err != nil {
+		t.Fatal("Banker never asked anything:", err)
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	s := boot(t)
+	c := login(t, s, "unterminated")
+	// the client leaves the terminator off of a string that ends its packet
+	if err := c.Send(net.NewEmptyPacket(38).AddBytes([]byte("nosuchcommand"))); err != nil {
+		t.Fatal(err)
+	}
+	p, err := c.ExpectPacket(131)
+	if err != nil {
+		t.Fatal("Command was never answered:", err)
+	}
+	if !strings.Contains(string(p.FrameBuffer), "Command not found") {
+		t.Fatalf("Command was answered with %q", p.FrameBuffer)
+	}
+}
+
+//hungService A player database that never gets back to anyone checking a password.
+type hungService struct {
+	db.PlayerService
+}
+
+func (s hungService) PlayerValidLogin(ctx context.Context, _ uint64, _ string) bool {
+	<-ctx.Done()
+	return false
+}
+
+func TestHungDatabase(t *testing.T) {
+	s := boot(t)
+	login(t, s, "hung").Close()
+	timeout := config.TomlConfig.Timeouts.Login
+	service := db.DefaultPlayerService
+	config.TomlConfig.Timeouts.Login = 1
+	db.DefaultPlayerService = hungService{service}
+	defer func() {
+		config.TomlConfig.Timeouts.Login = timeout
+		db.DefaultPlayerService = service
+	}()
+
+	start := world.CurrentTick()
+	_, err := s.Login("hung", "password")
+	if err == nil {
+		t.Fatal("Logged in without the password being checked")
+	}
+	if !strings.HasSuffix(err.Error(), "response code "+strconv.Itoa(int(handshake.ResponseServerRejection))) {
+		t.Fatal("Login was not rejected by the server:", err)
+	}
+	// a tick that waited on the database would have been the only one to run while it did
+	if ticks := world.CurrentTick() - start; ticks < 10 {
+		t.Fatal("Only", ticks, "ticks ran while the database was hung")
+	}
+}
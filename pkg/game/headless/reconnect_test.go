@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package headless_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/game/headless"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+)
+
+//eventually Runs ticks until cond returns true, failing the test if it still hasn't after headless.Timeout.
+func eventually(t *testing.T, s *headless.Server, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(headless.Timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Gave up waiting after", headless.Timeout)
+		}
+		s.Advance(1)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	s := boot(t)
+	c := login(t, s, "reconnect")
+	player := c.Player
+	player.Attributes.SetVar("reconnectTest", 1)
+	c.Close()
+	eventually(t, s, player.Lingering)
+	if !world.Players.Contains(player) {
+		t.Fatal("Player left the world as soon as the connection dropped")
+	}
+
+	c, err := s.Login("reconnect", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Player != player {
+		t.Fatal("Reconnecting loaded a new player instead of taking over the one in the world")
+	}
+	if player.Lingering() || player.Attributes.VarInt("reconnectTest", 0) != 1 {
+		t.Fatal("Player was not handed back as it was left")
+	}
+	// everything in view gets sent again over the new connection
+	if _, err := c.ExpectPacket(191); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconnectWindow(t *testing.T) {
+	// booted first, as the first boot loads the config
+	s := boot(t)
+	window := config.TomlConfig.ReconnectWindow
+	config.TomlConfig.ReconnectWindow = 1
+	defer func() {
+		config.TomlConfig.ReconnectWindow = window
+	}()
+	c := login(t, s, "noreconnect")
+	player := c.Player
+	c.Close()
+	eventually(t, s, player.Lingering)
+	eventually(t, s, func() bool {
+		return !world.Players.Contains(player)
+	})
+	if _, ok := world.Players.FindHash(player.UsernameHash()); ok {
+		t.Fatal("Player is still in the world after the reconnect window passed")
+	}
+	// too late to take it over, so it gets loaded again like any other login
+	if player.Rebind(player) {
+		t.Fatal("Reconnected to a player whose reconnect window had passed")
+	}
+	c, err := s.Login("noreconnect", "password")
+	if err != nil {
+		t.Fatal("Could not log back in after the reconnect window passed:", err)
+	}
+	if c.Player == player {
+		t.Fatal("Logging back in took over the player that was logged out")
+	}
+}
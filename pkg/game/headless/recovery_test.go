@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package headless_test
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/crypto"
+	"github.com/spkaeros/rscgo/pkg/db"
+	"github.com/spkaeros/rscgo/pkg/game/headless"
+	"github.com/spkaeros/rscgo/pkg/game/net/handshake"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+	"github.com/spkaeros/rscgo/pkg/throttle"
+)
+
+var (
+	questions = []string{"first", "second", "third", "fourth", "fifth"}
+	answers   = []string{"one", "two", "three", "four", "five"}
+	wrong     = []string{"one", "two", "three", "four", "six"}
+)
+
+//recoverable Creates an account with recovery questions, which are answered with answers.
+func recoverable(t *testing.T, username string) {
+	t.Helper()
+	ctx := context.Background()
+	hash, err := crypto.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !db.DefaultPlayerService.PlayerCreate(ctx, username, hash, "127.0.0.1") {
+		t.Fatal("Could not create account for", username)
+	}
+	hashes := make([]uint64, len(answers))
+	for i, answer := range answers {
+		hashes[i] = strutil.Base37.Encode(answer)
+	}
+	if !db.DefaultPlayerService.SaveRecoveryQuestions(ctx, strutil.Base37.Encode(username), questions, hashes) {
+		t.Fatal("Could not save recovery questions for", username)
+	}
+	t.Cleanup(func() {
+		world.RemoveThrottle("tester", username)
+	})
+}
+
+//tryRecover Tries to recover username from s.Address, failing the test unless the server answers with want.
+func tryRecover(t *testing.T, s *headless.Server, username string, answers []string, want db.RecoveryResult) {
+	t.Helper()
+	result, err := s.Recover(username, "newpassword", answers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != want {
+		t.Fatalf("Recovering %v from %v got result %d, wanted %d", username, s.Address, result, want)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	s := boot(t)
+	s.Address = "10.0.9.1"
+	defer world.RemoveThrottle("tester", s.Address)
+	recoverable(t, "recovered")
+	tryRecover(t, s, "recovered", wrong, db.RecoveryFailed)
+	if _, err := s.Login("recovered", "newpassword"); err == nil {
+		t.Fatal("Wrong answers changed the password")
+	}
+	tryRecover(t, s, "recovered", answers, db.RecoverySuccess)
+	if _, err := s.Login("recovered", "newpassword"); err != nil {
+		t.Fatal("Could not log in with the recovered password:", err)
+	}
+}
+
+func TestRecoveryThrottle(t *testing.T) {
+	s := boot(t)
+	s.Address = "10.0.9.2"
+	defer world.RemoveThrottle("tester", s.Address)
+	recoverable(t, "guessedfrom")
+	recoverable(t, "neighbour")
+	for i := 0; i < 3; i++ {
+		tryRecover(t, s, "guessedfrom", wrong, db.RecoveryFailed)
+	}
+	// the address is locked out now, even with the right answers, and for other accounts too
+	tryRecover(t, s, "guessedfrom", answers, db.RecoveryThrottled)
+	tryRecover(t, s, "neighbour", answers, db.RecoveryThrottled)
+}
+
+func TestRecoveryAccountThrottle(t *testing.T) {
+	s := boot(t)
+	recoverable(t, "guessed")
+	recoverable(t, "untouched")
+	// guessing from a different address every time still gets the account locked
+	throttled := false
+	for i := 0; i < 10 && !throttled; i++ {
+		s.Address = "10.0.10." + strconv.Itoa(i+1)
+		defer world.RemoveThrottle("tester", s.Address)
+		result, err := s.Recover("guessed", "newpassword", wrong)
+		if err != nil {
+			t.Fatal(err)
+		}
+		throttled = result == db.RecoveryThrottled
+	}
+	if !throttled {
+		t.Fatal("Wrong answers from many addresses never locked the account")
+	}
+	s.Address = "10.0.10.100"
+	defer world.RemoveThrottle("tester", s.Address)
+	tryRecover(t, s, "guessed", answers, db.RecoveryThrottled)
+	tryRecover(t, s, "untouched", answers, db.RecoverySuccess)
+
+	world.RemoveThrottle("tester", "guessed")
+	tryRecover(t, s, "guessed", answers, db.RecoverySuccess)
+}
+
+func TestRecoveryThrottleShared(t *testing.T) {
+	s := boot(t)
+	s.Address = "10.0.11.1"
+	defer world.RemoveThrottle("tester", s.Address)
+	recoverable(t, "website")
+	// the website keeps its own recovery throttle, in the same file as the game servers
+	website := ipThrottle.New(ipThrottle.Policy{Attempts: 3, Window: time.Minute * 15, Lockout: time.Minute * 15})
+	if err := website.Persist(filepath.Join(config.ThrottleDirectory(), "recovery.json")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		website.Add(s.Address)
+	}
+	if err := website.Save(); err != nil {
+		t.Fatal(err)
+	}
+	tryRecover(t, s, "website", answers, db.RecoveryThrottled)
+
+	// and lifting it from the game server lifts it for the website too
+	world.RemoveThrottle("tester", s.Address)
+	handshake.SaveThrottles()
+	if wait := website.Locked(s.Address); wait > 0 {
+		t.Fatal("Website still has the address locked out for", wait)
+	}
+}
+
+func TestRecoveryQuestions(t *testing.T) {
+	s := boot(t)
+	s.Address = "10.0.12.1"
+	recoverable(t, "asked")
+	got, err := s.RecoveryQuestions("asked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != strings.Join(questions, ",") {
+		t.Fatalf("Got recovery questions %q, wanted %q", got, questions)
+	}
+	// asking about accounts that have no questions is how somebody would look for ones to guess at
+	for i := 0; i < 3; i++ {
+		if got, err := s.RecoveryQuestions("unasked" + strconv.Itoa(i)); err != nil || len(got) > 0 {
+			t.Fatal("Got recovery questions for an account without any:", got, err)
+		}
+	}
+	if got, err := s.RecoveryQuestions("asked"); err != nil || len(got) > 0 {
+		t.Fatal("Got recovery questions from a throttled address:", got, err)
+	}
+	tryRecover(t, s, "asked", answers, db.RecoveryThrottled)
+
+	s.Address = "10.0.12.2"
+	if got, err := s.RecoveryQuestions("asked"); err != nil || len(got) != len(questions) {
+		t.Fatal("Throttling one address kept the questions from another:", got, err)
+	}
+}
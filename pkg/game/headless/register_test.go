@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package headless_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/db"
+	"github.com/spkaeros/rscgo/pkg/game/headless"
+	"github.com/spkaeros/rscgo/pkg/game/net/handshake"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+)
+
+//register Registers username from s.Address, failing the test unless the server answers with want.
+func register(t *testing.T, s *headless.Server, username string, want handshake.ResponseCode) {
+	t.Helper()
+	code, err := s.Register(username, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != want {
+		t.Fatalf("Registering %v got response %d, wanted %d", username, code, want)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	s := boot(t)
+	s.Address = "10.0.8.1"
+	register(t, s, "registered", handshake.ResponseRegisterSuccess)
+	if !db.DefaultPlayerService.PlayerNameExists(context.Background(), "registered") {
+		t.Fatal("Registration succeeded without creating the account")
+	}
+	register(t, s, "registered", handshake.ResponseUsernameTaken)
+	register(t, s, "x", handshake.ResponseBadInputLength)
+
+	c, err := s.Login("registered", "password")
+	if err != nil {
+		t.Fatal("Could not log in to a new account:", err)
+	}
+	if c.Player.CurrentIP() != s.Address {
+		t.Fatal("Player connected from", c.Player.CurrentIP(), "instead of", s.Address)
+	}
+}
+
+func TestRegisterThrottle(t *testing.T) {
+	s := boot(t)
+	s.Address = "10.0.8.2"
+	defer world.RemoveThrottle("tester", s.Address)
+	attempts, _ := config.ThrottleRegister()
+	if attempts <= 0 {
+		t.Skip("Registrations are not throttled in this config")
+	}
+	for i := 0; i < attempts; i++ {
+		register(t, s, "throttled"+string(rune('a'+i)), handshake.ResponseRegisterSuccess)
+	}
+	register(t, s, "throttledz", handshake.ResponseSpamTimeout)
+	if db.DefaultPlayerService.PlayerNameExists(context.Background(), "throttledz") {
+		t.Fatal("Account was created from a throttled address")
+	}
+
+	// registrations from elsewhere are not held up
+	s.Address = "10.0.8.3"
+	register(t, s, "throttledz", handshake.ResponseRegisterSuccess)
+}
+
+func TestRegisterTakenName(t *testing.T) {
+	s := boot(t)
+	s.Address = "10.0.8.4"
+	register(t, s, "popular", handshake.ResponseRegisterSuccess)
+	// picking a name that is taken is no reason to be locked out, however many times it happens
+	for i := 0; i < 10; i++ {
+		register(t, s, "popular", handshake.ResponseUsernameTaken)
+	}
+	if _, err := s.Login("popular", "password"); err != nil {
+		t.Fatal("Could not log in after picking taken names:", err)
+	}
+	register(t, s, "unpopular", handshake.ResponseRegisterSuccess)
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package headless_test
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/spkaeros/rscgo/pkg/game/headless"
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/packets"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//appearanceChat Returns every public chat message in a player appearances packet, laid out for revision 204 if old
+// is true.  Fails the test if the packet does not parse all the way to its end.
+func appearanceChat(t *testing.T, p *net.Packet, old bool) []string {
+	t.Helper()
+	data := p.FrameBuffer
+	off := 0
+	take := func(n int) []byte {
+		if off+n > len(data) {
+			t.Fatalf("Player appearances ended early at %d of %d bytes: %v", off, len(data), data)
+		}
+		off += n
+		return data[off-n : off]
+	}
+	message := func() string {
+		if old {
+			return strutil.ChatFilter.Unpack(take(int(take(1)[0])))
+		}
+		length := int(take(1)[0])
+		if length >= 128 {
+			length = (length-128)<<8 | int(take(1)[0])
+		}
+		msg, n := strutil.DecipherLength(data[off:], length)
+		take(n)
+		return msg
+	}
+	framed := func() {
+		take(1)
+		for take(1)[0] != 0 {
+		}
+	}
+
+	var chat []string
+	count := int(binary.BigEndian.Uint16(take(2)))
+	for i := 0; i < count; i++ {
+		switch kind := take(3)[2]; kind {
+		case 0:
+			take(2)
+		case 1:
+			if !old {
+				// rank
+				take(1)
+			}
+			chat = append(chat, message())
+		case 2:
+			take(3)
+		case 3, 4:
+			take(4)
+		case 5:
+			take(2)
+			if old {
+				take(8)
+			} else {
+				framed()
+				framed()
+			}
+			take(int(take(1)[0]))
+			take(6)
+		case 6:
+			message()
+		default:
+			t.Fatalf("Unknown player appearance update %d: %v", kind, data)
+		}
+	}
+	if off != len(data) {
+		t.Fatalf("Player appearances has %d bytes left over: %v", len(data)-off, data)
+	}
+	return chat
+}
+
+//hear Waits for c to be sent a public chat message, and returns it.
+func hear(t *testing.T, c *headless.Client, old bool) string {
+	t.Helper()
+	for {
+		p, err := c.ExpectPacket(protocol.Outbound["playerAppearances"])
+		if err != nil {
+			t.Fatal("Chat was never heard:", err)
+		}
+		if chat := appearanceChat(t, p, old); len(chat) > 0 {
+			return chat[0]
+		}
+	}
+}
+
+func TestMixedRevisions(t *testing.T) {
+	s := boot(t)
+	modern := login(t, s, "modern")
+	old := loginVersion(t, s, 204, "classic")
+	s.Advance(2)
+	modern.Received()
+	old.Received()
+
+	if err := modern.Say("Hello there"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := hear(t, old, true); msg != "hello there" {
+		t.Fatalf("204 client heard %q", msg)
+	}
+	s.Advance(1)
+	modern.Received()
+	old.Received()
+
+	if err := old.Send(net.NewEmptyPacket(protocol.Inbound["chat"]).AddBytes(strutil.ChatFilter.Pack("general kenobi"))); err != nil {
+		t.Fatal(err)
+	}
+	if msg := hear(t, modern, false); !strings.EqualFold(msg, "general kenobi") {
+		t.Fatalf("235 client heard %q", msg)
+	}
+	s.Advance(1)
+	modern.Received()
+	old.Received()
+
+	command := (&packets.Command{Command: "nosuchcommand"}).Encode()
+	if err := modern.Send(command); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Send(command); err != nil {
+		t.Fatal(err)
+	}
+	framed, err := modern.ExpectPacket(protocol.Outbound["serverMessage"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	bare, err := old.ExpectPacket(protocol.Outbound["serverMessage"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(framed.FrameBuffer) < 4 || string(bare.FrameBuffer) != string(framed.FrameBuffer[3:len(framed.FrameBuffer)-1]) {
+		t.Fatalf("204 client got server message %q, 235 client got %q", bare.FrameBuffer, framed.FrameBuffer)
+	}
+	if err := modern.Err(); err != nil {
+		t.Fatal("235 client was disconnected:", err)
+	}
+	if err := old.Err(); err != nil {
+		t.Fatal("204 client was disconnected:", err)
+	}
+}
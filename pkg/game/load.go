@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package game
+
+import (
+	"sync"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/db"
+	// the Go packet handlers register themselves with world.Handlers when this is imported
+	_ "github.com/spkaeros/rscgo/pkg/game/net/handlers"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+)
+
+//run Helper function for concurrently running a bunch of functions and waiting for them to complete
+func run(fns ...func()) {
+	w := &sync.WaitGroup{}
+	for _, fn := range fns {
+		w.Add(1)
+		go func(fn func()) {
+			defer w.Done()
+			fn()
+		}(fn)
+	}
+	w.Wait()
+}
+
+//loadRevisions Registers every client revision that may log in.
+func loadRevisions() {
+	protocol.Load(config.Version(), config.OpcodeCipher(), config.Revisions())
+}
+
+//LoadWorld Loads every entity definition, the map, the packet table, the client revisions and the scripts, and then spawns every entity.
+// The entity service must be connected before calling this.
+func LoadWorld() {
+	// Three init phases after data backend is connected--Entity definitions, then tile collision bitmask loading, followed by entity spawn locations
+	// So, the order here of these three phases is important.  If you attempt to load object spawn locations during the same phase as the collision
+	// data, it will result in a world filled with objects that are not solid.  Many similar bugs possible.  Best just to leave this be.
+	run(db.LoadTileDefinitions, db.LoadObjectDefinitions, db.LoadBoundaryDefinitions, db.LoadItemDefinitions, db.LoadNpcDefinitions)
+	run(world.LoadCollisionData, world.UnmarshalPackets, loadRevisions, world.RunScripts)
+	run(db.LoadObjectLocations, db.LoadNpcLocations, db.LoadItemLocations)
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package game
+
+import (
+	"github.com/spkaeros/rscgo/pkg/metrics"
+)
+
+//tickBuckets Upper bounds for tick timings, in seconds.  A tick that takes longer than world.TickMillis (0.64s) is
+// running behind.
+var tickBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, .64, 1, 2.5}
+
+var (
+	tickTime = metrics.NewHistogram("rscgo_tick_seconds", "Time taken to run a whole game engine tick.", tickBuckets)
+	// phaseTime is the time taken by each step of the tick, in the order they run:
+	//	login: accepting queued logins
+	//	packets_in: handling incoming packets, along with each players tick action and movement
+	//	npc_ai: NPC aggression and wandering
+	//	tasks: every task on the tasks.TickList, which includes scripts and autosaving
+	//	packet_building: building each players region updates
+	//	packets_out: flushing every players outgoing packets
+	//	logout: removing queued logouts from the world
+	phaseTime          = metrics.NewHistogramVec("rscgo_tick_phase_seconds", "Time taken by each phase of a game engine tick.", "phase", tickBuckets)
+	loginTime          = phaseTime.With("login")
+	packetsInTime      = phaseTime.With("packets_in")
+	npcTime            = phaseTime.With("npc_ai")
+	tasksTime          = phaseTime.With("tasks")
+	packetBuildingTime = phaseTime.With("packet_building")
+	packetsOutTime     = phaseTime.With("packets_out")
+	logoutTime         = phaseTime.With("logout")
+)
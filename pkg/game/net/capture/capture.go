@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+//Package capture Reads and writes packet captures; recordings of every packet that one player sent and received, kept
+// for working out what went wrong in a client session after the fact.
+//
+// A capture file is a JSON header, followed by one JSON frame per packet, one per line.  Opcodes are always the ones
+// the server uses, whatever revision the client was, so that captures can be decoded with the packets file and
+// replayed with any client.
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+//Header Describes the session that a capture was recorded from.
+type Header struct {
+	Username string    `json:"username"`
+	Version  int       `json:"version"`
+	Started  time.Time `json:"started"`
+}
+
+//Frame One packet in a capture.  Inbound frames were sent by the client, and outbound frames by the server.
+type Frame struct {
+	Time    time.Time `json:"time"`
+	Inbound bool      `json:"inbound"`
+	Opcode  byte      `json:"opcode"`
+	Payload []byte    `json:"payload"`
+}
+
+//Writer Records frames to a capture file.  It is safe to use from several goroutines at once.
+type Writer struct {
+	sync.Mutex
+	Name   string
+	file   *os.File
+	buffer *bufio.Writer
+	enc    *json.Encoder
+}
+
+//Create Creates a new capture file, and writes header to it.  A file that already exists is never overwritten;
+// that is an error instead.
+func Create(name string, header Header) (*Writer, error) {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{Name: name, file: file, buffer: bufio.NewWriter(file)}
+	w.enc = json.NewEncoder(w.buffer)
+	if err := w.enc.Encode(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+//Write Records one packet, with the server's opcode, timestamped now.
+func (w *Writer) Write(inbound bool, opcode byte, payload []byte) error {
+	w.Lock()
+	defer w.Unlock()
+	if err := w.enc.Encode(Frame{time.Now(), inbound, opcode, payload}); err != nil {
+		return err
+	}
+	// captures are read while a bug is being looked into, often before the player has logged out
+	return w.buffer.Flush()
+}
+
+//Close Finishes the capture file.
+func (w *Writer) Close() error {
+	w.Lock()
+	defer w.Unlock()
+	if err := w.buffer.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+//Reader Reads frames back from a capture file.
+type Reader struct {
+	Header Header
+	file   *os.File
+	dec    *json.Decoder
+}
+
+//Open Opens a capture file, and reads its header.
+func Open(name string) (*Reader, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{file: file, dec: json.NewDecoder(bufio.NewReader(file))}
+	if err := r.dec.Decode(&r.Header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+//Read Returns the next frame in the capture.  Returns io.EOF once there are no more.
+func (r *Reader) Read() (f Frame, err error) {
+	err = r.dec.Decode(&f)
+	return
+}
+
+//Close Closes the capture file.
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
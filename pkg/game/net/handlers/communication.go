@@ -10,16 +10,16 @@
 package handlers
 
 import (
-	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/packets"
 	"github.com/spkaeros/rscgo/pkg/game/world"
 	"github.com/spkaeros/rscgo/pkg/strutil"
 )
 
 func init() {
-	game.AddHandler("addfriend", func(player *world.Player, p *net.Packet) {
-		hash := p.ReadUint64()
+	world.AddHandler("addfriend", func(player *world.Player, message interface{}) {
+		hash := message.(*packets.AddFriend).User
 		defer func() {
-			player.SendPacket(world.FriendList(player))
+			player.WritePacket(world.FriendList(player))
 		}()
 		if player.FriendsWith(hash) {
 			player.Message("@que@You are already friends with that person!")
@@ -29,41 +29,39 @@ func init() {
 			player.Message("@que@Please remove '" + strutil.Base37.Decode(hash) + "' from your ignore list before friending them.")
 			return
 		}
+		player.FriendList.Add(strutil.Base37.Decode(hash))
+		// whoever we just added may have had us on their list already, and not been allowed to see us until now
 		if p1, ok := world.Players.FindHash(hash); ok && p1 != nil &&
-			(!p1.FriendBlocked() || p1.FriendList.ContainsHash(hash)) {
-			player.FriendList.Add(strutil.Base37.Decode(hash))
-			p1.SendPacket(world.FriendUpdate(player.UsernameHash(), true))
+			player.FriendBlocked() && p1.FriendsWith(player.UsernameHash()) {
+			p1.WritePacket(world.FriendUpdate(player.UsernameHash(), true))
 		}
 	})
-	game.AddHandler("removefriend", func(player *world.Player, p *net.Packet) {
-		hash := p.ReadUint64()
+	world.AddHandler("removefriend", func(player *world.Player, message interface{}) {
+		hash := message.(*packets.RemoveFriend).User
 		defer func() {
-			player.SendPacket(world.FriendList(player))
+			player.WritePacket(world.FriendList(player))
 		}()
 		if !player.FriendsWith(hash) {
 			player.Message("@que@You are not friends with that person!")
 			return
 		}
 		player.FriendList.Remove(strutil.Base37.Decode(hash))
-		if player.FriendBlocked() {
-			if p1, ok := world.Players.FindHash(hash); ok && p1 != nil &&
-				p1.FriendList.ContainsHash(hash) {
-				p1.FriendList.ToggleStatus(player.Username())
-			}
+		if p1, ok := world.Players.FindHash(hash); ok && p1 != nil &&
+			player.FriendBlocked() && p1.FriendsWith(player.UsernameHash()) {
+			p1.WritePacket(world.FriendUpdate(player.UsernameHash(), false))
 		}
 	})
-	game.AddHandler("privmsg", func(player *world.Player, p *net.Packet) {
-		hash := p.ReadUint64()
-		if p1, ok := world.Players.FindHash(hash); ok && p1 != nil &&
-			(!p1.FriendBlocked() || p1.FriendList.ContainsHash(hash)) {
-			// c1.SendPacket(world.PrivateMessage(player.UsernameHash(), strutil.ChatFilter.Format(strutil.ChatFilter.Unpack(p.FrameBuffer[8:]))))
-			p1.SendPacket(world.PrivateMessage(player.UsernameHash(), strutil.ChatFilter.Format(string(p.FrameBuffer[8:]))))
+	world.AddHandler("privmsg", func(player *world.Player, message interface{}) {
+		msg := message.(*packets.PrivateMessage)
+		if p1, ok := world.Players.FindHash(msg.User); ok && p1 != nil && !p1.Ignoring(player.UsernameHash()) &&
+			(!p1.FriendBlocked() || p1.FriendsWith(player.UsernameHash())) {
+			p1.WritePacket(world.PrivateMessage(player.UsernameHash(), strutil.Decipher(msg.Message, msg.Length)))
 		}
 	})
-	game.AddHandler("addignore", func(player *world.Player, p *net.Packet) {
-		hash := p.ReadUint64()
+	world.AddHandler("addignore", func(player *world.Player, message interface{}) {
+		hash := message.(*packets.AddIgnore).User
 		defer func() {
-			player.SendPacket(world.IgnoreList(player))
+			player.WritePacket(world.IgnoreList(player))
 		}()
 		if player.FriendsWith(hash) {
 			player.Message("@que@Please remove '" + strutil.Base37.Decode(hash) + "' from your friend list before ignoring them.")
@@ -75,10 +73,10 @@ func init() {
 		}
 		player.IgnoreList = append(player.IgnoreList, hash)
 	})
-	game.AddHandler("removeignore", func(player *world.Player, p *net.Packet) {
-		hash := p.ReadUint64()
+	world.AddHandler("removeignore", func(player *world.Player, message interface{}) {
+		hash := message.(*packets.RemoveIgnore).User
 		defer func() {
-			player.SendPacket(world.IgnoreList(player))
+			player.WritePacket(world.IgnoreList(player))
 		}()
 		if !player.Ignoring(hash) {
 			player.Message("@que@You are not ignoring that person!")
@@ -11,21 +11,18 @@ package handlers
 
 import (
 	"github.com/spkaeros/rscgo/pkg/definitions"
-	"github.com/spkaeros/rscgo/pkg/game"
-	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/packets"
 	"github.com/spkaeros/rscgo/pkg/game/world"
 	"github.com/spkaeros/rscgo/pkg/log"
 )
 
 func init() {
-	game.AddHandler("invonboundary", func(player *world.Player, p *net.Packet) {
+	world.AddHandler("invonboundary", func(player *world.Player, message interface{}) {
 		if player.Busy() || player.IsFighting() {
 			return
 		}
-		targetX := p.ReadUint16()
-		targetY := p.ReadUint16()
-		p.ReadUint8() // dir, useful?
-		invIndex := p.ReadUint16()
+		msg := message.(*packets.InvOnBoundary)
+		targetX, targetY, invIndex := msg.X, msg.Y, msg.Index
 
 		object := world.GetObject(targetX, targetY)
 		if object == nil || !object.Boundary {
@@ -55,7 +52,7 @@ func init() {
 							return
 						}
 					}
-					player.SendPacket(world.DefaultActionMessage)
+					player.WritePacket(world.DefaultActionMessage)
 				}()
 				return true
 			}
@@ -63,12 +60,12 @@ func init() {
 			return false
 		})
 	})
-	game.AddHandler("invonplayer", func(player *world.Player, p *net.Packet) {
+	world.AddHandler("invonplayer", func(player *world.Player, message interface{}) {
 		if player.Busy() || player.IsFighting() {
 			return
 		}
-		targetIndex := p.ReadUint16()
-		invIndex := p.ReadUint16()
+		msg := message.(*packets.InvOnPlayer)
+		targetIndex, invIndex := msg.Player, msg.Index
 
 		if targetIndex == player.ServerIndex() {
 			log.Suspicious.Printf("%s attempted to use an inventory item on themself\n", player.String())
@@ -104,7 +101,7 @@ func init() {
 							return
 						}
 					}
-					player.SendPacket(world.DefaultActionMessage)
+					player.WritePacket(world.DefaultActionMessage)
 				}()
 				return true
 			}
@@ -112,13 +109,12 @@ func init() {
 			return false
 		})
 	})
-	game.AddHandler("invonobject", func(player *world.Player, p *net.Packet) {
+	world.AddHandler("invonobject", func(player *world.Player, message interface{}) {
 		if player.Busy() || player.IsFighting() {
 			return
 		}
-		targetX := p.ReadUint16()
-		targetY := p.ReadUint16()
-		invIndex := p.ReadUint16()
+		msg := message.(*packets.InvOnScene)
+		targetX, targetY, invIndex := msg.X, msg.Y, msg.Index
 
 		object := world.GetObject(targetX, targetY)
 		if object == nil || object.Boundary {
@@ -137,7 +133,7 @@ func init() {
 				// If somehow we became busy, the object changed before arriving, we do nothing.
 				return true
 			}
-			if definitions.Scenary(object.ID).CollisionType == 2 || definitions.Scenary(object.ID).CollisionType == 3 {
+			if definitions.Scenary(object.ID).SolidityType == 2 || definitions.Scenary(object.ID).SolidityType == 3 {
 				if (player.NextTo(bounds[1]) || player.NextTo(bounds[0])) && player.X() >= bounds[0].X() && player.Y() >= bounds[0].Y() && player.X() <= bounds[1].X() && player.Y() <= bounds[1].Y() {
 					player.ResetPath()
 					player.AddState(world.MSBatching)
@@ -150,7 +146,7 @@ func init() {
 								return
 							}
 						}
-						player.SendPacket(world.DefaultActionMessage)
+						player.WritePacket(world.DefaultActionMessage)
 					}()
 					return true
 				}
@@ -169,7 +165,7 @@ func init() {
 							return
 						}
 					}
-					player.SendPacket(world.DefaultActionMessage)
+					player.WritePacket(world.DefaultActionMessage)
 				}()
 				return true
 			}
@@ -10,11 +10,81 @@
 package handshake
 
 import (
+	"path/filepath"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/strutil"
 	"github.com/spkaeros/rscgo/pkg/throttle"
 )
 
-var LoginThrottle = ipThrottle.NewThrottle()
-var RegisterThrottle = ipThrottle.NewThrottle()
+//LoginThrottle Keeps track of failed logins, for each address they came from.
+var LoginThrottle ipThrottle.NetworkThrottle = ipThrottle.New(ipThrottle.Policy{Attempts: 5, Window: time.Second * 10, Lockout: time.Minute, MaxLockout: time.Hour})
+
+//AccountThrottle Keeps track of failed logins to each account, from anywhere.  Keys are made with AccountKey.
+var AccountThrottle ipThrottle.NetworkThrottle = ipThrottle.New(ipThrottle.Policy{Attempts: 10, Window: time.Minute * 5, Lockout: time.Minute, MaxLockout: time.Hour})
+
+//RegisterThrottle Keeps track of the accounts created from each address.  Every registration that succeeds counts
+// against its address, so that one address can't fill the database with accounts, and so does every registration
+// that the real client could never have sent.
+var RegisterThrottle ipThrottle.NetworkThrottle = ipThrottle.New(ipThrottle.Policy{Attempts: 3, Window: time.Hour, Lockout: time.Hour, MaxLockout: time.Hour * 24})
+
+//RecoveryThrottle Keeps track of wrong answers to recovery questions, for each address they came from.
+// 3 wrong attempts within 15 minutes locks the address out of recovering any account for 15 minutes, doubling from there.
+var RecoveryThrottle ipThrottle.NetworkThrottle = ipThrottle.New(recoveryPolicy)
+
+//RecoveryAccountThrottle Keeps track of wrong answers to the recovery questions of each account, from anywhere.  Keys
+// are made with AccountKey.  Like AccountThrottle, this allows more than the address limit, so that guessing from many
+// addresses gets stopped without making it too easy to lock the owner out.
+var RecoveryAccountThrottle ipThrottle.NetworkThrottle = ipThrottle.New(recoveryAccountPolicy)
+
+// the recovery limits are fixed, rather than coming from the config
+var (
+	recoveryPolicy        = ipThrottle.Policy{Attempts: 3, Window: time.Minute * 15, Lockout: time.Minute * 15, MaxLockout: time.Hour * 24}
+	recoveryAccountPolicy = ipThrottle.Policy{Attempts: 6, Window: time.Hour, Lockout: time.Hour, MaxLockout: time.Hour * 24}
+)
+
+//AccountKey Returns the AccountThrottle and RecoveryAccountThrottle key of the account with the provided username hash.
+func AccountKey(userHash uint64) string {
+	return strutil.Base37.Decode(userHash)
+}
+
+//ConfigureThrottles Sets up LoginThrottle, AccountThrottle and RegisterThrottle with the limits from the config, and
+// starts every throttle over with nothing recorded.  If a throttle directory is configured, every throttle is loaded
+// from it and saved back to it from then on.
+func ConfigureThrottles() {
+	lockout, maxLockout := config.ThrottleLockout()
+	attempts, window := config.ThrottleLogin()
+	login := ipThrottle.New(ipThrottle.Policy{Attempts: attempts, Window: window, Lockout: lockout, MaxLockout: maxLockout})
+	attempts, window = config.ThrottleAccount()
+	account := ipThrottle.New(ipThrottle.Policy{Attempts: attempts, Window: window, Lockout: lockout, MaxLockout: maxLockout})
+	// a lockout shorter than the window would let an address straight back in to make as many accounts again
+	attempts, window = config.ThrottleRegister()
+	register := ipThrottle.New(ipThrottle.Policy{Attempts: attempts, Window: window, Lockout: window, MaxLockout: maxLockout})
+	LoginThrottle, AccountThrottle, RegisterThrottle = login, account, register
+	RecoveryThrottle, RecoveryAccountThrottle = ipThrottle.New(recoveryPolicy), ipThrottle.New(recoveryAccountPolicy)
+	dir := config.ThrottleDirectory()
+	if len(dir) == 0 {
+		return
+	}
+	for name, throttle := range map[string]ipThrottle.NetworkThrottle{"login": login, "account": account, "register": register, "recovery": RecoveryThrottle, "recovery_account": RecoveryAccountThrottle} {
+		if persistent, ok := throttle.(*ipThrottle.Throttle); ok {
+			if err := persistent.Persist(filepath.Join(dir, name+".json")); err != nil {
+				log.Warn("Could not load the", name, "throttle:", err)
+			}
+		}
+	}
+}
+
+//SaveThrottles Saves every throttle to the throttle directory, if one is configured.
+func SaveThrottles() {
+	for _, throttle := range []ipThrottle.NetworkThrottle{LoginThrottle, AccountThrottle, RegisterThrottle, RecoveryThrottle, RecoveryAccountThrottle} {
+		if err := throttle.Save(); err != nil {
+			log.Warn("Could not save throttle:", err)
+		}
+	}
+}
 
 type (
 	//ResponseType A networking handshake response identifier code.
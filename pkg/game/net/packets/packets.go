@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+//go:generate go run ../../../packetgen.go -in ../../../../data/packets.toml -out schemas.go
+
+//Package packets Holds typed messages for the packets that clients send, decoded by the field layouts in the schemas
+// section of data/packets.toml.  The message types themselves are generated into schemas.go by pkg/packetgen.go, and
+// should be regenerated with `go generate` whenever the schemas change.
+package packets
+
+import (
+	"strconv"
+
+	"github.com/spkaeros/rscgo/pkg/errors"
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+)
+
+//Message A packet decoded into the fields that its schema describes.
+type Message interface {
+	//Decode Reads every field out of the payload of p.  Returns an error if p is too short to hold them, in which
+	// case the fields are left half read and must not be used.
+	Decode(p *net.Packet) error
+	//Encode Builds the packet back out of the fields, with the server's opcode, as a client would send it.
+	Encode() *net.Packet
+}
+
+//schemas Makes an empty message, for every server opcode that has a schema.
+var schemas [256]func() Message
+
+func register(name string, fn func() Message) {
+	schemas[protocol.Inbound[name]] = fn
+}
+
+//Decode Decodes p, which the server knows as opcode, into the message that its schema describes.
+// Returns nil and no error if opcode has no schema, and an error if p does not fit its schema.
+func Decode(opcode byte, p *net.Packet) (Message, error) {
+	fn := schemas[opcode]
+	if fn == nil {
+		return nil, nil
+	}
+	m := fn()
+	if err := m.Decode(p); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//reader Reads fields out of a packet payload, remembering the first read that ran out of payload rather than
+// handing back zeroes the way that net.Packet does.  Every read after a failed one is a no-op.
+type reader struct {
+	*net.Packet
+	err error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.Available() < n {
+		r.err = errors.NewNetworkError("Packet too short; needed "+strconv.Itoa(n)+" more bytes at offset "+strconv.Itoa(r.ReadIndex)+" of "+strconv.Itoa(r.Length()), false)
+		r.ReadIndex = r.Length()
+		return nil
+	}
+	r.ReadIndex += n
+	return r.FrameBuffer[r.ReadIndex-n : r.ReadIndex]
+}
+
+//more Returns true if there is payload left to read, and nothing has failed yet.
+func (r *reader) more() bool {
+	return r.err == nil && r.Available() > 0
+}
+
+func (r *reader) uint8() int {
+	if b := r.take(1); b != nil {
+		return int(b[0])
+	}
+	return 0
+}
+
+func (r *reader) int8() int {
+	if b := r.take(1); b != nil {
+		return int(int8(b[0]))
+	}
+	return 0
+}
+
+func (r *reader) bool() bool {
+	return r.uint8() != 0
+}
+
+func (r *reader) uint16() int {
+	if b := r.take(2); b != nil {
+		return int(b[0])<<8 | int(b[1])
+	}
+	return 0
+}
+
+func (r *reader) uint32() int {
+	if b := r.take(4); b != nil {
+		return int(uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]))
+	}
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	hi, lo := uint64(r.uint32()), uint64(r.uint32())
+	return hi<<32 | lo
+}
+
+//smart Reads a number that takes one byte when it is below 128, and two bytes with the top bit set otherwise.
+func (r *reader) smart() int {
+	n := r.uint8()
+	if n < 128 {
+		return n
+	}
+	return (n-128)<<8 | r.uint8()
+}
+
+//string Reads a string terminated by a NULL, a newline, or the end of the packet, which is how the client sends a
+// string that is the last field of its packet.
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	for i, b := range r.FrameBuffer[r.ReadIndex:] {
+		if b == 0 || b == '\n' {
+			s := string(r.FrameBuffer[r.ReadIndex : r.ReadIndex+i])
+			r.ReadIndex += i + 1
+			return s
+		}
+	}
+	s := string(r.FrameBuffer[r.ReadIndex:])
+	r.ReadIndex = r.Length()
+	return s
+}
+
+func (r *reader) stringN(n int) string {
+	return string(r.take(n))
+}
+
+//bytes Reads the next n bytes, or everything that is left if n is negative.
+func (r *reader) bytes(n int) []byte {
+	if n < 0 {
+		n = r.Available()
+	}
+	b := r.take(n)
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
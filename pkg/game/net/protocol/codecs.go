@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package protocol
+
+import (
+	"encoding/binary"
+
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//codecs Sets up the decoders and encoders of every revision that lays packets out differently to the server, keyed
+// by version.  The revisions file can only describe opcodes, so Load runs these on the revisions that it loads.
+var codecs = map[int]func(*Revision){
+	204: revision204,
+}
+
+//revision204 Clients from 204 pack chat 4 bits at a time with ChatFilter.Pack, where later clients use the huffman
+// cipher, they get server messages as bare text, and they know players by username hash rather than by name.
+func revision204(r *Revision) {
+	r.Decoders[Inbound["chat"]] = decodeChat204
+	r.Decoders[Inbound["privateMessage"]] = decodePrivateMessage204
+	r.Encoders[Outbound["serverMessage"]] = encodeServerMessage204
+	r.Encoders[Outbound["privateMessage"]] = encodePrivateMessage204
+	r.Encoders[Outbound["playerAppearances"]] = encodeAppearances204
+}
+
+//decodeChat204 The chat packet from 204 is nothing but the packed message.
+func decodeChat204(p *net.Packet) *net.Packet {
+	out := net.NewEmptyPacket(p.Opcode).AddEncryptedString(strutil.ChatFilter.Unpack(p.FrameBuffer))
+	return net.NewPacket(p.Opcode, out.FrameBuffer[1:])
+}
+
+//decodePrivateMessage204 The private message packet from 204 is the recipients username hash, then the packed
+// message.
+func decodePrivateMessage204(p *net.Packet) *net.Packet {
+	if len(p.FrameBuffer) < 8 {
+		return p
+	}
+	out := net.NewEmptyPacket(p.Opcode).AddBytes(p.FrameBuffer[:8])
+	out.AddEncryptedString(strutil.ChatFilter.Unpack(p.FrameBuffer[8:]))
+	return net.NewPacket(p.Opcode, out.FrameBuffer[1:])
+}
+
+//encodeServerMessage204 Server messages to 204 are just the text, with no message type, flags or framing.
+func encodeServerMessage204(p *net.Packet) *net.Packet {
+	// type, flags, then the framed text
+	data := p.FrameBuffer[1:]
+	if len(data) < 4 {
+		return p
+	}
+	return net.NewEmptyPacket(p.FrameBuffer[0]).AddBytes(data[3 : len(data)-1])
+}
+
+//encodePrivateMessage204 Private messages to 204 keep the senders username hash and the message ID, but the message
+// is packed.
+func encodePrivateMessage204(p *net.Packet) *net.Packet {
+	data := p.FrameBuffer[1:]
+	if len(data) < 12 {
+		return p
+	}
+	msg, _, ok := enciphered(data[12:])
+	if !ok {
+		return p
+	}
+	return net.NewEmptyPacket(p.FrameBuffer[0]).AddBytes(data[:12]).AddBytes(strutil.ChatFilter.Pack(msg))
+}
+
+//encodeAppearances204 Goes through every update in the player appearances packet, packing chat messages and
+// swapping player names for their username hashes.  Every other update is the same in 204.
+func encodeAppearances204(p *net.Packet) *net.Packet {
+	data := p.FrameBuffer[1:]
+	out := net.NewEmptyPacket(p.FrameBuffer[0])
+	off := 0
+	short := false
+	take := func(n int) []byte {
+		if short || n < 0 || off+n > len(data) {
+			short = true
+			return make([]byte, n)
+		}
+		off += n
+		return data[off-n : off]
+	}
+	chat := func() {
+		msg, n, ok := enciphered(data[off:])
+		if !ok {
+			short = true
+			return
+		}
+		take(n)
+		packed := strutil.ChatFilter.Pack(msg)
+		out.AddUint8(uint8(len(packed))).AddBytes(packed)
+	}
+	framed := func() string {
+		take(1)
+		start := off
+		for off < len(data) && data[off] != 0 {
+			off++
+		}
+		name := string(data[start:off])
+		take(1)
+		return name
+	}
+
+	count := take(2)
+	out.AddBytes(count)
+	for i := 0; i < int(binary.BigEndian.Uint16(count)) && !short; i++ {
+		update := take(3)
+		out.AddBytes(update)
+		switch update[2] {
+		case 0:
+			out.AddBytes(take(2))
+		case 1:
+			// 204 has no sender rank
+			take(1)
+			chat()
+		case 2:
+			out.AddBytes(take(3))
+		case 3, 4:
+			out.AddBytes(take(4))
+		case 5:
+			out.AddBytes(take(2))
+			name := framed()
+			framed()
+			out.AddUint64(strutil.Base37.Encode(name))
+			sprites := take(1)
+			out.AddBytes(sprites)
+			out.AddBytes(take(int(sprites[0])))
+			// colours, combat level and skull
+			out.AddBytes(take(6))
+		case 6:
+			chat()
+		default:
+			short = true
+		}
+	}
+	if short {
+		log.Warn("Could not lay out player appearances for revision 204; sending it unchanged:", p.FrameBuffer)
+		return p
+	}
+	return out
+}
+
+//enciphered Reads a message written by Packet.AddEncryptedString off the front of data, returning it along with how
+// many bytes it took up.
+func enciphered(data []byte) (string, int, bool) {
+	if len(data) < 1 {
+		return "", 0, false
+	}
+	length, size := int(data[0]), 1
+	if length >= 128 {
+		if len(data) < 2 {
+			return "", 0, false
+		}
+		length, size = int(data[0]-128)<<8|int(data[1]), 2
+	}
+	msg, n := strutil.DecipherLength(data[size:], length)
+	return msg, size + n, true
+}
@@ -0,0 +1,321 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package protocol
+
+import (
+	"os"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/log"
+)
+
+//Inbound The logical names of the packets that clients send, and their opcodes in the revision that the server is
+// written against.  Every other revision is described by how its opcodes differ from these.
+var Inbound = map[string]byte{
+	"ping":              67,
+	"blink":             59,
+	"tradeRequest":      142,
+	"tradeDecline":      230,
+	"tradeAccept":       55,
+	"tradeAccept2":      104,
+	"tradeUpdate":       46,
+	"follow":            165,
+	"closeBank":         212,
+	"depositBank":       23,
+	"withdrawBank":      22,
+	"menuAnswer":        116,
+	"equip":             169,
+	"npcChat":           153,
+	"npcAction":         202,
+	"sceneAction":       136,
+	"sceneAction2":      79,
+	"boundaryAction":    127,
+	"boundaryAction2":   14,
+	"invOnScene":        115,
+	"invOnBoundary":     161,
+	"invOnPlayer":       113,
+	"unequip":           170,
+	"dropItem":          246,
+	"recoverAccount":    220,
+	"closeStream":       31,
+	"logout":            102,
+	"pickupItem":        247,
+	"itemAction":        90,
+	"spellOnNpc":        50,
+	"spellOnGroundItem": 249,
+	"spellOnInvItem":    4,
+	"spellOnPlayer":     229,
+	"spellOnSelf":       137,
+	"ticketRequests":    163,
+	"appearance":        235,
+	"report":            206,
+	"attackNpc":         190,
+	"attackPlayer":      171,
+	"fightMode":         29,
+	"duelRequest":       103,
+	"duelDecline":       197,
+	"duelAccept":        176,
+	"duelAccept2":       77,
+	"duelUpdate":        33,
+	"duelSettings":      8,
+	"settings":          111,
+	"privacySettings":   64,
+	"addFriend":         195,
+	"removeFriend":      167,
+	"addIgnore":         132,
+	"removeIgnore":      241,
+	"privateMessage":    218,
+	"shopSell":          221,
+	"shopBuy":           236,
+	"shopClose":         166,
+	"command":           38,
+	"chat":              216,
+	"cancelRecoverys":   196,
+	"changePassword":    25,
+	"changeRecoverys":   203,
+	"recoverys":         208,
+	"prayerOn":          60,
+	"prayerOff":         254,
+	"walkRequest":       187,
+	"walkAction":        16,
+}
+
+//Outbound The logical names of the packets that the server sends, and their opcodes in the revision that the server
+// is written against.  The packet builders in the world package all build these opcodes.
+var Outbound = map[string]byte{
+	"logout":                4,
+	"questStatus":           5,
+	"duelUpdate":            6,
+	"pong":                  9,
+	"tradeAccept":           15,
+	"tradeConfirmationOpen": 20,
+	"planeInfo":             25,
+	"duelOptions":           30,
+	"playerExperience":      33,
+	"teleBubble":            36,
+	"bankOpen":              42,
+	"objectLocations":       48,
+	"privacySettings":       51,
+	"systemUpdate":          52,
+	"inventoryItems":        53,
+	"openChangeAppearance":  59,
+	"friendList":            71,
+	"npcPositions":          79,
+	"death":                 83,
+	"sleepClose":            84,
+	"informationBox":        89,
+	"boundaryLocations":     91,
+	"tradeOpen":             92,
+	"tradeUpdate":           97,
+	"itemLocations":         99,
+	"shopOpen":              101,
+	"npcEvents":             104,
+	"ignoreList":            109,
+	"fatigue":               114,
+	"sleepWord":             117,
+	"privateMessage":        120,
+	"tradeClose":            128,
+	"serverMessage":         131,
+	"fightMode":             132,
+	"shopClose":             137,
+	"friendUpdate":          149,
+	"equipmentStats":        153,
+	"playerStats":           156,
+	"playerStat":            159,
+	"tradeTargetAccept":     162,
+	"duelConfirmationOpen":  172,
+	"duelOpen":              176,
+	"loginBox":              182,
+	"cannotLogout":          183,
+	"playerPositions":       191,
+	"sleepWrong":            194,
+	"bankClose":             203,
+	"sound":                 204,
+	"prayerStatus":          206,
+	"clearDistantChunks":    211,
+	"appearanceKeepalive":   213,
+	"bigInformationBox":     222,
+	"duelClose":             225,
+	"playerAppearances":     234,
+	"clientSettings":        240,
+	"sleepFatigue":          244,
+	"optionMenuOpen":        245,
+	"bankUpdateItem":        249,
+	"optionMenuClose":       252,
+	"duelTargetAccept":      253,
+}
+
+//Codec Rewrites the payload of a packet from the layout of one revision into the layout of another.
+type Codec func(*net.Packet) *net.Packet
+
+//Revision The differences between one revision of the client and the revision that the server is written against.
+// The server only ever deals in its own opcodes; a revision turns the opcodes its clients send into those, and the
+// opcodes the server sends into its own, leaving out whatever it does not have.
+type Revision struct {
+	Version int
+	//Cipher Whether clients of this revision encrypt packet opcodes with ISAAC, in both directions, once logged in.
+	Cipher bool
+	// inbound maps client opcodes to server opcodes, and outbound maps server opcodes to client opcodes.  -1 is a
+	// packet that this revision does not have.
+	inbound  [256]int16
+	outbound [256]int16
+	// Decoders rewrite packets from this revision after they have been turned into server opcodes, keyed by the
+	// server opcode.  Encoders rewrite packets before they go out to this revision, keyed by the server opcode.
+	Decoders map[byte]Codec
+	Encoders map[byte]Codec
+}
+
+//NewRevision Returns a revision that numbers and lays out every packet the same way that the server does.
+func NewRevision(version int) *Revision {
+	r := &Revision{Version: version, Decoders: make(map[byte]Codec), Encoders: make(map[byte]Codec)}
+	for i := range r.inbound {
+		r.inbound[i] = int16(i)
+		r.outbound[i] = int16(i)
+	}
+	return r
+}
+
+//LoginAcceptBit Returns true if clients of this revision expect ResponseLoginAcceptBit set on successful login
+// responses.  Revisions from 204 and older do not.
+func (r *Revision) LoginAcceptBit() bool {
+	return r.Version > 204
+}
+
+//MapInbound Makes clients of this revision send the packet with the provided logical name as opcode, or not send it
+// at all if opcode is negative.
+// Returns false if there is no inbound packet by that name.
+func (r *Revision) MapInbound(name string, opcode int) bool {
+	server, ok := Inbound[name]
+	if !ok {
+		return false
+	}
+	// whatever the client used to send as this opcode is gone, unless it gets mapped again
+	for i, mapped := range r.inbound {
+		if mapped == int16(server) {
+			r.inbound[i] = -1
+		}
+	}
+	if opcode >= 0 {
+		r.inbound[byte(opcode)] = int16(server)
+	}
+	return true
+}
+
+//MapOutbound Makes the server send the packet with the provided logical name to clients of this revision as opcode,
+// or not send it to them at all if opcode is negative.
+// Returns false if there is no outbound packet by that name.
+func (r *Revision) MapOutbound(name string, opcode int) bool {
+	server, ok := Outbound[name]
+	if !ok {
+		return false
+	}
+	r.outbound[server] = int16(opcode)
+	if opcode < 0 {
+		r.outbound[server] = -1
+	}
+	return true
+}
+
+//Decode Turns a packet sent by a client of this revision into the server's opcode and layout.
+// Returns nil if the server has no such packet.
+func (r *Revision) Decode(opcode byte, p *net.Packet) (byte, *net.Packet) {
+	mapped := r.inbound[opcode]
+	if mapped < 0 {
+		return 0, nil
+	}
+	if decode := r.Decoders[byte(mapped)]; decode != nil {
+		p = decode(p)
+	}
+	return byte(mapped), p
+}
+
+//Encode Turns a packet built by the server into the opcode and layout of this revision.
+// Returns nil if this revision has no such packet, in which case it should not be sent at all.
+// The first byte of the frame buffer is the server's opcode, and any encoder must leave it there.
+func (r *Revision) Encode(p *net.Packet) (byte, *net.Packet) {
+	opcode := p.FrameBuffer[0]
+	mapped := r.outbound[opcode]
+	if mapped < 0 {
+		return 0, nil
+	}
+	if encode := r.Encoders[opcode]; encode != nil {
+		p = encode(p)
+	}
+	return byte(mapped), p
+}
+
+var (
+	revisions = make(map[int]*Revision)
+	lock      sync.RWMutex
+)
+
+//Register Adds a revision, replacing any revision with the same version.
+func Register(r *Revision) {
+	lock.Lock()
+	defer lock.Unlock()
+	revisions[r.Version] = r
+}
+
+//Find Returns the revision that clients reporting the provided version should be spoken to with, or nil if that
+// version is not supported.
+func Find(version int) *Revision {
+	lock.RLock()
+	defer lock.RUnlock()
+	return revisions[version]
+}
+
+//Load Registers the server's own revision, whose clients encrypt opcodes if cipher is true, then every revision
+// described in the provided TOML file, along with the packet layouts that codecs has for it.
+// A missing file just means no other revisions are supported.
+func Load(base int, cipher bool, file string) {
+	own := NewRevision(base)
+	own.Cipher = cipher
+	Register(own)
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return
+	}
+	var list struct {
+		Revisions []struct {
+			Version  int            `toml:"version"`
+			Cipher   *bool          `toml:"opcode_cipher"`
+			Inbound  map[string]int `toml:"inbound"`
+			Outbound map[string]int `toml:"outbound"`
+		} `toml:"revision"`
+	}
+	if _, err := toml.DecodeFile(file, &list); err != nil {
+		log.Warn("Could not load client revisions from", file+":", err)
+		return
+	}
+	for _, def := range list.Revisions {
+		r := NewRevision(def.Version)
+		// revisions that don't say whether they encrypt opcodes do whatever the server's own revision does
+		r.Cipher = cipher
+		if def.Cipher != nil {
+			r.Cipher = *def.Cipher
+		}
+		for name, opcode := range def.Inbound {
+			if !r.MapInbound(name, opcode) {
+				log.Warn("Client revision", def.Version, "maps unknown inbound packet:", name)
+			}
+		}
+		for name, opcode := range def.Outbound {
+			if !r.MapOutbound(name, opcode) {
+				log.Warn("Client revision", def.Version, "maps unknown outbound packet:", name)
+			}
+		}
+		if setup, ok := codecs[def.Version]; ok {
+			setup(r)
+		}
+		Register(r)
+	}
+	log.Info.Printf("Loaded %d extra client revisions\n", len(list.Revisions))
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package game
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/spkaeros/rscgo/pkg/db"
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/rsa"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+	"github.com/spkaeros/rscgo/pkg/xtea"
+)
+
+//RecoverRequestOpcode The opcode of the handshake packet that the client sends to ask for the recovery questions of an
+// account, from its forgotten password screen.
+const RecoverRequestOpcode = 220
+
+//RecoverAttemptOpcode The opcode of the handshake packet that the client sends with the answers to the recovery
+// questions of an account, and the new password to set if they are right.
+const RecoverAttemptOpcode = 221
+
+//handleRecoverRequest Sends the client the recovery questions of the account it asks about:
+//	uint64 username hash
+// The reply is a single 0 byte if there are no recovery questions, or the client is throttled, otherwise a 1 byte
+// followed by each of the five questions, as a length byte and then the question itself.  Either way, the client is
+// disconnected afterwards.
+func (s *Server) handleRecoverRequest(p *world.Player, request *net.Packet) {
+	userHash := request.ReadUint64()
+	// looking the questions up waits on the database, and the tick can't wait around on that
+	go func() {
+		defer p.Unregister()
+		ctx, cancel := s.loginContext()
+		defer cancel()
+		questions, _ := db.RecoveryQuestions(ctx, userHash, p.CurrentIP())
+		if reason := interrupted(ctx); reason != "" {
+			log.Debug("[RECOVERY]", strutil.Base37.Decode(userHash)+"@"+p.CurrentIP(), "could not be sent their questions ("+reason+")")
+		}
+		if len(questions) == 0 {
+			p.Writer.Write([]byte{0})
+			p.Writer.Flush()
+			return
+		}
+		reply := []byte{1}
+		for _, question := range questions {
+			if len(question) > 255 {
+				question = question[:255]
+			}
+			reply = append(append(reply, byte(len(question))), question...)
+		}
+		p.Writer.Write(reply)
+		p.Writer.Flush()
+	}()
+}
+
+//handleRecoverAttempt Changes the password of an account, if the client knows the answers to its recovery questions.
+// The packet is laid out like a login, with the new password in the RSA block, and the account with the answers to
+// its questions in the XTEA block:
+//	uint32 client version
+//	uint16 RSA block length, then the RSA block:
+//		byte 10 checksum
+//		4 uint32 XTEA keys
+//		new password, padded out to 19 chars with spaces then terminated
+//		8 random bytes
+//	uint16 XTEA block length, then the XTEA block:
+//		uint64 username hash
+//		5 uint64 answers, each one the base37 hash of the answer text
+// The reply is a single db.RecoveryResult byte, and then the client is disconnected.
+func (s *Server) handleRecoverAttempt(p *world.Player, attempt *net.Packet) {
+	reply := func(result db.RecoveryResult) {
+		p.Writer.Write([]byte{byte(result)})
+		p.Writer.Flush()
+		p.Unregister()
+	}
+	if ver := attempt.ReadUint32(); protocol.Find(ver) == nil {
+		log.Debug("[RECOVERY] Invalid client version:", ver)
+		reply(db.RecoveryFailed)
+		return
+	}
+	data := make([]byte, attempt.ReadUint16())
+	if attempt.Read(data) < len(data) {
+		reply(db.RecoveryFailed)
+		return
+	}
+	rsaData := rsa.RsaKeyPair.Decrypt(data)
+	if len(rsaData) < 37 || rsaData[0] != 10 {
+		log.Debug("[RECOVERY] Bad RSA block from", p.CurrentIP())
+		reply(db.RecoveryFailed)
+		return
+	}
+	keys := make([]int, 4)
+	for i := range keys {
+		keys[i] = int(binary.BigEndian.Uint32(rsaData[1+i*4:]))
+	}
+	password := strings.TrimSpace(string(rsaData[17:36]))
+
+	block := make([]byte, attempt.ReadUint16())
+	if attempt.Read(block) < len(block) || len(block) < 48 {
+		reply(db.RecoveryFailed)
+		return
+	}
+	block = xtea.New(keys).Decrypt(block)
+	userHash := binary.BigEndian.Uint64(block)
+	answers := make([]uint64, 5)
+	for i := range answers {
+		answers[i] = binary.BigEndian.Uint64(block[8+i*8:])
+	}
+	p.SetVar("username", userHash)
+	log.Debug("[RECOVERY]", strutil.Base37.Decode(userHash)+"@"+p.CurrentIP(), "is attempting to recover their account")
+	// checking the answers takes a while, and the tick can't wait around on that
+	go func() {
+		ctx, cancel := s.loginContext()
+		defer cancel()
+		result := db.RecoverAccount(ctx, userHash, answers, password, p.CurrentIP())
+		if reason := interrupted(ctx); reason != "" {
+			log.Debug("[RECOVERY]", strutil.Base37.Decode(userHash)+"@"+p.CurrentIP(), "could not recover their account ("+reason+")")
+		}
+		reply(result)
+	}()
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package game
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/crypto"
+	"github.com/spkaeros/rscgo/pkg/db"
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/handshake"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/rsa"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//RegisterOpcode The opcode of the handshake packet that the client sends to create a new account.
+const RegisterOpcode = 2
+
+//handleRegister Handles the registration handshake; the client asking us to create a new account for it.
+// The packet is laid out much like a login, but the RSA block holds the username in place of the ISAAC keys:
+//	uint32 client version
+//	uint16 RSA block length, then the RSA block:
+//		byte 10 checksum
+//		username, padded out to 19 chars with spaces then terminated
+//		password, padded out to 19 chars with spaces then terminated
+//		8 random bytes
+// Either way the client is sent a single response byte and disconnected; on success it will log in afterwards.
+func (s *Server) handleRegister(p *world.Player, register *net.Packet) {
+	sendReply := func(i handshake.ResponseCode, reason string) {
+		p.Writer.Write([]byte{byte(i)})
+		p.Writer.Flush()
+		if i != handshake.ResponseRegisterSuccess {
+			log.Debug("[REGISTER]", p.Username() + "@" + p.CurrentIP(), "failed to register (" + reason + ")")
+		} else {
+			log.Debug("[REGISTER]", p.Username() + "@" + p.CurrentIP(), "successfully registered")
+		}
+		p.Unregister()
+	}
+	// the real client never sends these, so whoever did is up to no good, and it counts against their address
+	malformed := func(reason string) {
+		handshake.RegisterThrottle.Add(p.CurrentIP())
+		sendReply(handshake.ResponseServerRejection, reason)
+	}
+
+	if !world.UpdateTime.IsZero() {
+		sendReply(handshake.ResponseServerRejection, "System update in progress")
+		return
+	}
+	if wait := handshake.LoginThrottle.Locked(p.CurrentIP()); wait > 0 {
+		sendReply(handshake.ResponseSpamTimeout, "Too many recent invalid attempts from this address (locked out for " + wait.Round(time.Second).String() + ")")
+		return
+	}
+	if wait := handshake.RegisterThrottle.Locked(p.CurrentIP()); wait > 0 {
+		sendReply(handshake.ResponseSpamTimeout, "Too many accounts created from this address (locked out for " + wait.Round(time.Second).String() + ")")
+		return
+	}
+	if ver := register.ReadUint32(); protocol.Find(ver) == nil {
+		sendReply(handshake.ResponseUpdated, "Invalid client version (" + strconv.Itoa(ver) + ")")
+		return
+	}
+
+	rsaSize := register.ReadUint16()
+	data := make([]byte, rsaSize)
+	if register.Read(data) < rsaSize {
+		malformed("Short RSA block")
+		return
+	}
+	rsaData := rsa.RsaKeyPair.Decrypt(data)
+	if len(rsaData) < 41 || rsaData[0] != 10 {
+		malformed("Bad RSA block")
+		return
+	}
+	username := strings.TrimSpace(string(rsaData[1:20]))
+	password := strings.TrimSpace(string(rsaData[21:40]))
+	p.SetVar("username", strutil.Base37.Encode(username))
+
+	if !validUsername(username) {
+		sendReply(handshake.ResponseBadInputLength, "Invalid username '" + username + "'")
+		return
+	}
+	if len(password) < 5 || len(password) > 20 {
+		sendReply(handshake.ResponseBadInputLength, "Password must be 5-20 characters long")
+		return
+	}
+	if reservedUsername(username) {
+		sendReply(handshake.ResponseUsernameTaken, "Username '" + username + "' is reserved")
+		return
+	}
+	// the rest waits on the database and on hashing the password, and the tick can't wait around on that
+	go func() {
+		ctx, cancel := s.loginContext()
+		defer cancel()
+		ban := world.FindBan(ctx, 0, p.CurrentIP())
+		if reason := interrupted(ctx); reason != "" {
+			sendReply(handshake.ResponseServerRejection, reason)
+			return
+		}
+		if ban != nil {
+			sendReply(banResponse(ban), "Address is banned " + ban.Length() + " (" + ban.Reason + ")")
+			return
+		}
+		// checked before hashing too, so that taken names don't cost a hash
+		exists := db.DefaultPlayerService.PlayerNameExists(ctx, username)
+		if reason := interrupted(ctx); reason != "" {
+			sendReply(handshake.ResponseServerRejection, reason)
+			return
+		}
+		if exists {
+			sendReply(handshake.ResponseUsernameTaken, "Username '" + username + "' is taken")
+			return
+		}
+		hash, err := crypto.Hash(password)
+		if err != nil {
+			log.Warn("Could not hash the password of new account", username+":", err)
+			sendReply(handshake.ResponseNoReply, "Could not hash password")
+			return
+		}
+		sendReply(s.createAccount(ctx, username, hash, p.CurrentIP()))
+	}()
+}
+
+//createAccount Creates the account username with the password hash, on behalf of ip.  Only one account is created at a
+// time, so that registrations from the same address can't all get past the register throttle together.  The database
+// gets until ctx is done.  Returns the response to send the client, and why it was sent.
+func (s *Server) createAccount(ctx context.Context, username, hash, ip string) (handshake.ResponseCode, string) {
+	s.registering.Lock()
+	defer s.registering.Unlock()
+	if wait := handshake.RegisterThrottle.Locked(ip); wait > 0 {
+		return handshake.ResponseSpamTimeout, "Too many accounts created from this address (locked out for " + wait.Round(time.Second).String() + ")"
+	}
+	exists := db.DefaultPlayerService.PlayerNameExists(ctx, username)
+	if reason := interrupted(ctx); reason != "" {
+		return handshake.ResponseServerRejection, reason
+	}
+	if exists {
+		return handshake.ResponseUsernameTaken, "Username '" + username + "' is taken"
+	}
+	if !db.DefaultPlayerService.PlayerCreate(ctx, username, hash, ip) {
+		if reason := interrupted(ctx); reason != "" {
+			return handshake.ResponseServerRejection, reason
+		}
+		return handshake.ResponseNoReply, "Could not create player profile; is the dataService setup properly?"
+	}
+	handshake.RegisterThrottle.Add(ip)
+	return handshake.ResponseRegisterSuccess, ""
+}
+
+//validUsername Returns true if name can be used as a username.  Usernames are 2-12 characters long, made up of
+// letters, numbers and single spaces between words, so that they survive being base37 encoded and decoded again.
+func validUsername(name string) bool {
+	if len(name) < 2 || len(name) > 12 || name != strings.TrimSpace(name) || strings.Contains(name, "  ") {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != ' ' {
+			return false
+		}
+	}
+	return strings.EqualFold(strutil.Base37.Decode(strutil.Base37.Encode(name)), name)
+}
+
+//reservedUsername Returns true if name, or any one word in it, is on the reserved name list from the config.
+func reservedUsername(name string) bool {
+	words := append(strings.Fields(strings.ToLower(name)), strings.ToLower(strings.Replace(name, " ", "", -1)))
+	for _, reserved := range config.ReservedNames() {
+		for _, word := range words {
+			if word == strings.ToLower(reserved) {
+				return true
+			}
+		}
+	}
+	return false
+}
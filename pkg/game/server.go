@@ -0,0 +1,875 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package game
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"math"
+	stdnet "net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/db"
+	rscerrors "github.com/spkaeros/rscgo/pkg/errors"
+	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/handshake"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+	"github.com/spkaeros/rscgo/pkg/game/world"
+	"github.com/spkaeros/rscgo/pkg/isaac"
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/proxy"
+	rscrand "github.com/spkaeros/rscgo/pkg/rand"
+	"github.com/spkaeros/rscgo/pkg/rsa"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+	"github.com/spkaeros/rscgo/pkg/tasks"
+	"github.com/spkaeros/rscgo/pkg/xtea"
+)
+
+//Server The game server.  It accepts clients, logs them in and out, and runs the game engine one tick at a time.
+type Server struct {
+	context.Context
+	cancel func()
+	// queries is what the database work done for clients on their way in runs under; it is cancelled as soon as
+	// Shutdown starts, so that the tick it has to wait on is never stuck on a database that stopped responding
+	queries context.Context
+	cancelQueries func()
+	loginQ chan *world.Player
+	logoutQ chan *world.Player
+	sync.RWMutex
+	clock Clock
+	debug bool
+	*tasks.Scripts
+	// one for raw TCP clients and one for websocket clients, unless either port is turned off
+	listeners []stdnet.Listener
+	// tls is nil unless TLS is enabled in the config
+	tls *tls.Config
+	// quit is closed to ask the tick loop to stop, and halted is closed by the tick loop once it has
+	quit, halted chan struct{}
+	// logouts that are still being saved in the background
+	logouts sync.WaitGroup
+	// admitting holds the logins that got through the database, waiting for the next tick to let them into the world
+	admitting []func(admitted bool)
+	// admitClosed is set once the tick loop has stopped, and there is nothing left to admit logins
+	admitClosed bool
+	admitLock   sync.Mutex
+	// registering is held while an account is checked for and created
+	registering sync.Mutex
+	// starter is claimed by whichever of Start or Shutdown gets to it first; Start only runs the tick loop if it does
+	starter sync.Once
+	stopper sync.Once
+	status int
+}
+
+var wsUpgrader = ws.Upgrader{
+	Protocol: func(protocol []byte) bool {
+		return string(protocol) == "binary"
+	},
+	ReadBufferSize:  5000,
+	WriteBufferSize: 5000,
+}
+
+//NewServer Returns a new game server which runs a tick every time clock ticks.
+func NewServer(clock Clock) *Server {
+	s := &Server{clock: clock, loginQ: make(chan *world.Player, 25), logoutQ: make(chan *world.Player, 25), Scripts: tasks.TickList, quit: make(chan struct{}), halted: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Context, s.cancel = context.WithValue(ctx, "server", s), cancel
+	s.queries, s.cancelQueries = context.WithCancel(s.Context)
+	// scripts can change passwords and place bans, which shutting down should not be held up by either
+	world.SetScriptContext(s.queries)
+	return s
+}
+
+func (s *Server) accept(l stdnet.Listener) stdnet.Conn {
+	socket, err := l.Accept()
+	if err != nil {
+		select {
+		case <-s.quit:
+			// listener was closed by Shutdown
+		default:
+			log.Warn("Problem accepting incoming connection:", err)
+		}
+		return nil
+	}
+	return socket
+}
+
+//serve Sets up socket as a client of the listener it came from, and submits it to the login queue.  If websocket
+// is true, the client gets upgraded to a websocket first.  Clients that can't finish setting up in time, or that
+// fail to upgrade, get disconnected without ever becoming players.  Clients that come through a trusted proxy are
+// seen with the address that the proxy says they have, from then on.
+func (s *Server) serve(socket stdnet.Conn, websocket bool) {
+	sniffed := newSniffedConn(socket)
+	drop := func(reason string, err error) {
+		log.Debug("Could not "+reason+" new connection from", sniffed.RemoteAddr(), ":", err)
+		socket.Close()
+	}
+	if err := socket.SetDeadline(time.Now().Add(identifyTimeout)); err != nil {
+		drop("set up", err)
+		return
+	}
+	if proxy.Trusted(socket.RemoteAddr().String()) {
+		addr, err := proxy.ReadHeader(sniffed.reader)
+		if err != nil {
+			drop("read proxy header of", err)
+			return
+		}
+		sniffed.remote = addr
+	}
+	conn, err := s.unwrapTLS(sniffed)
+	if err != nil {
+		drop("identify", err)
+		return
+	}
+	if websocket {
+		upgrader := wsUpgrader
+		var forwarded []string
+		if proxy.Trusted(sniffed.RemoteAddr().String()) {
+			upgrader.OnHeader = func(key, value []byte) error {
+				if strings.EqualFold(string(key), "X-Forwarded-For") {
+					forwarded = append(forwarded, string(value))
+				}
+				return nil
+			}
+		}
+		if _, err := upgrader.Upgrade(conn); err != nil {
+			drop("upgrade to websocket", err)
+			return
+		}
+		if ip := proxy.ForwardedFor(strings.Join(forwarded, ",")); ip != nil {
+			sniffed.remote = &stdnet.TCPAddr{IP: ip}
+		}
+	}
+	if err := socket.SetDeadline(time.Time{}); err != nil {
+		drop("set up", err)
+		return
+	}
+	s.SubmitLogin(s.newPlayer(conn, websocket))
+}
+
+//Connect Sets up a new client for a plain TCP socket that did not come from one of the servers own listeners, and
+// submits it to the login queue.
+func (s *Server) Connect(socket stdnet.Conn) {
+	s.SubmitLogin(s.newPlayer(socket, false))
+}
+
+//newPlayer Returns a new player for socket, with the reader and writer that suit its transport.  Websocket clients
+// must already be upgraded.
+func (s *Server) newPlayer(socket stdnet.Conn, websocket bool) *world.Player {
+	p := world.NewPlayerCtx(s, socket)
+	p.Websocket = websocket
+	if websocket {
+		p.Reader = bufio.NewReaderSize(wsutil.NewServerSideReader(socket), 5000)
+		p.Writer = wsutil.NewWriterSize(socket, ws.StateServerSide, ws.OpBinary, 5000)
+	} else {
+		p.Reader = bufio.NewReaderSize(socket, 5000)
+		p.Writer = bufio.NewWriterSize(socket, 5000)
+	}
+	return p
+}
+
+//listen Starts accepting clients on port in the background, until Shutdown is called.  Clients are served as
+// websockets if websocket is true, otherwise as raw TCP sockets.  A port of 0 is not listened on.
+func (s *Server) listen(port int, websocket bool) {
+	if port == 0 {
+		return
+	}
+	listener, err := stdnet.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		log.Fatal("Could not bind the game listener on port", port, ":", err)
+		os.Exit(4)
+		return
+	}
+	s.listeners = append(s.listeners, listener)
+	go func() {
+		for {
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+			if socket := s.accept(listener); socket != nil {
+				// setting up a client means waiting on it, which the next one shouldn't have to do
+				go s.serve(socket, websocket)
+			}
+		}
+	}()
+}
+
+//Bind Starts listening for raw TCP clients on tcpPort, and for websocket clients on wsPort.  Either port may be 0 to
+// not listen for that sort of client.  TLS is accepted on both, if it is enabled.
+func (s *Server) Bind(tcpPort, wsPort int) {
+	if config.TLSEnabled() {
+		tlsConfig, err := newTLSConfig(config.TLSCert(), config.TLSKey())
+		if err != nil {
+			log.Fatal("Could not load the TLS certificate:", err)
+			os.Exit(4)
+			return
+		}
+		s.tls = tlsConfig
+	}
+	s.listen(tcpPort, false)
+	s.listen(wsPort, true)
+}
+
+//Start Runs the game engine, one tick every time the clock ticks, until the server is shut down.
+// Does nothing if Shutdown was called first.
+func (s *Server) Start() {
+	started := false
+	s.starter.Do(func() {
+		started = true
+	})
+	if !started {
+		return
+	}
+	defer close(s.halted)
+	defer s.clock.Stop()
+	ctx := s.Context
+	world.DefaultServer = s
+	defer s.cancel()
+	// s.DebugTicks()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.quit:
+			return
+		case <-s.clock.Ticks():
+			start := time.Now()
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				for _, login := range s.admitted(false) {
+					login(true)
+				}
+				for i := 0; i < 25; i++ { 
+					select {
+					case p1, ok := <-s.loginQ:
+						if !ok || p1 == nil {
+							break
+						}
+						s.handleLogin(p1)
+					default:
+						break
+					}
+				}
+				phase := loginTime.Since(start)
+
+				world.Players.AsyncRange(func(p *world.Player) {
+					if p == nil {
+						return
+					}
+					p.ProcPacketsIn() // dequeue incoming packets.  These are read off the socket then queued by each players own goroutine
+					if !p.Connected() {
+						p.Initialize()
+					}
+					if fn := p.TickAction(); fn != nil && !fn() {
+						p.ResetTickAction()
+					}
+					p.TraversePath()
+				})
+				phase = packetsInTime.Since(phase)
+				world.Npcs.RangeNpcs(func(n *world.NPC) bool {
+					if n.Busy() || n.IsFighting() {
+						return false
+					}
+
+					if n.Aggressive() {
+						if _, ok := n.Var("targetPlayer"); ok {
+							return false
+						}
+						if closest := closestPlayer(n); closest != nil {
+							n.SetVar("targetPlayer", closest)
+						}
+						n.TraversePath()
+						return false
+					}
+					if world.Chance(25) && n.Steps <= 0 && n.Ticks <= 0 {
+						// move some amount between 2-15 tiles, moving 1 tile per tick
+						n.Steps = rscrand.Intn(13+1)+2
+						// wait some amount between 25-50 ticks before doing this again
+						n.Ticks = rscrand.Intn(10+1)+25
+					}
+					if n.Ticks > 0 {
+						n.Ticks -= 1
+					}
+					// wander aimlessly until we run out of scheduled steps
+					if n.Steps > 0 {
+						n.TraversePath()
+					}
+					return false
+				})
+				phase = npcTime.Since(phase)
+				s.Tick(ctx)
+				phase = tasksTime.Since(phase)
+				world.Players.AsyncRange(func(p *world.Player) {
+					sendPacket := func(p1 *net.Packet) {
+						if p != nil && p1 != nil {
+							p.WritePacket(p1)// <- p1
+						}
+					}
+					// if p.HasState(world.StateChangingLooks) {
+						// sendPacket(world.AppearanceKeepalive)
+						// return
+					// }
+					sendPacket(world.PlayerPositions(p))
+					sendPacket(world.NPCPositions(p))
+					sendPacket(world.PlayerAppearances(p))
+					sendPacket(world.NpcEvents(p))
+					sendPacket(world.ObjectLocations(p))
+					sendPacket(world.BoundaryLocations(p))
+					sendPacket(world.ItemLocations(p))
+					sendPacket(world.ClearDistantChunks(p))
+					if p.VarInt("lastPlane", -1) != p.Plane() {
+						sendPacket(world.PlaneInfo(p))
+						p.SetVar("lastPlane", p.Plane())
+					}
+				})
+				phase = packetBuildingTime.Since(phase)
+
+				world.Players.AsyncRange(func(p *world.Player) {
+					p.ResetRegionRemoved()
+					p.ResetRegionMoved()
+					p.ResetSpriteUpdated()
+					p.ResetAppearanceChanged()
+					p.ProcPacketsOut() // dequeue incoming packets.  These are read off the socket then queued by each players own goroutine
+				})
+				world.Npcs.RangeNpcs(func(n *world.NPC) bool {
+					n.ResetRegionRemoved()
+					n.ResetRegionMoved()
+					n.ResetSpriteUpdated()
+					n.ResetAppearanceChanged()
+					return false
+				})
+				phase = packetsOutTime.Since(phase)
+				for i := 0; i < 25; i++ { 
+					select {
+					case p1, ok := <-s.logoutQ:
+						if !ok || p1 == nil {
+							break
+						}
+						s.runLogout(p1)
+					default:
+						break
+					}
+				}
+				logoutTime.Since(phase)
+				tickTime.Since(start)
+				if s.debug {
+					// if world.CurrentTick() % 100 == 0 {
+						// each 64 seconds we log our tick processing time
+						log.Debug("time to process tick:", time.Since(start))
+					// }
+				}
+				s.clock.Ticked()
+			}
+		}
+	}
+}
+
+//Stop This will stop the game instance, if it is running, and then exit the process.
+// It returns immediately, as it is usually called from within the tick that Shutdown must wait on.
+func (s *Server) Stop() {
+	go func() {
+		os.Exit(s.Shutdown())
+	}()
+}
+
+//Shutdown Gracefully stops the game instance.  New connections are refused, the tick in progress is allowed to finish,
+// then every client is sent what remains of its outgoing queue followed by a logout, and every player is saved.
+// Once all that is done, the server context is cancelled.  Database queries for clients that are still logging in,
+// registering or recovering an account get cancelled straight away, and logins that were waiting on the tick to join
+// the world are turned away.
+// Returns the exit status for the process, which is non-zero if any player could not be saved.
+func (s *Server) Shutdown() int {
+	s.stopper.Do(func() {
+		log.Debug("Stopping...")
+		close(s.quit)
+		s.cancelQueries()
+		for _, listener := range s.listeners {
+			if err := listener.Close(); err != nil {
+				log.Warn("Problem closing game listener:", err)
+			}
+		}
+		s.starter.Do(func() {
+			// Start never ran, so there is no tick to wait on
+			s.clock.Stop()
+			close(s.halted)
+		})
+		<-s.halted
+		for _, login := range s.admitted(true) {
+			login(false)
+		}
+
+		var players []*world.Player
+		for drained := false; !drained; {
+			select {
+			case p := <-s.logoutQ:
+				if p != nil {
+					players = append(players, p)
+				}
+			default:
+				drained = true
+			}
+		}
+		world.Players.Range(func(p *world.Player) {
+			players = append(players, p)
+		})
+
+		var failed int32
+		wait := sync.WaitGroup{}
+		for _, p := range players {
+			wait.Add(1)
+			go func(p *world.Player) {
+				defer wait.Done()
+				p.ProcPacketsOut()
+				if !p.Destroy() {
+					atomic.AddInt32(&failed, 1)
+				}
+			}(p)
+		}
+		wait.Wait()
+		s.logouts.Wait()
+		if journal, ok := db.DefaultPlayerService.(*db.JournalService); ok {
+			if pending := journal.Close(10 * time.Second); pending > 0 {
+				log.Warn(pending, "player saves could not be applied to the database yet; they will be applied when the server next starts")
+			}
+		}
+		handshake.SaveThrottles()
+		s.cancel()
+		if failed > 0 {
+			log.Warn("Shutdown could not save", failed, "players!")
+			s.status = 1
+			return
+		}
+		log.Debug("Shutdown complete")
+	})
+	return s.status
+}
+
+		
+func closestPlayer(n *world.NPC) *world.Player {
+	var closest *world.Player
+	distance := math.Pow(8.0,2)
+	world.Region(n.X(), n.Y()).Players.RangePlayers(func(p1 *world.Player) bool {
+		if p1.EuclideanDistance(n) < distance {
+			closest = p1
+			distance = p1.EuclideanDistance(n)
+		}
+		return false
+	})
+	return closest
+}
+
+func (s *Server) handleLogin(p *world.Player) {
+	login, err := p.ReadPacket()
+	if err != nil || login == nil {
+		// a client that can't send a whole login packet isn't going to send anything else worth waiting on
+		p.Unregister()
+		return
+	}
+	version := 0
+	sendReply := func(i handshake.ResponseCode, reason string) {
+		p.Writer.Write([]byte{byte(i)})
+		p.Writer.Flush()
+		if !i.IsValid() {
+			if _, ok := p.Var("username"); ok {
+				// only attempts that got far enough to name an account go in its history; this is recorded even when
+				// the login ran out of time, so it gets its own query timeout rather than the login's deadline, and
+				// never on the tick.  Successful logins were recorded before they were handed to the tick.
+				go world.RecordLogin(s.queries, p, version, reason)
+			}
+			log.Debug("[LOGIN]", p.Username() + "@" + p.CurrentIP(), "failed to login (" + reason + ")")
+			p.Unregister()
+		} else {
+			log.Debug("[LOGIN]", p.Username() + "@" + p.CurrentIP(), "successfully logged in")
+			if !world.Players.Contains(p) {
+				world.AddPlayer(p)
+			}
+			go s.readPackets(p)
+		}
+	}
+
+	switch login.Opcode {
+	case RegisterOpcode:
+		s.handleRegister(p, login)
+		return
+	case RecoverRequestOpcode:
+		s.handleRecoverRequest(p, login)
+		return
+	case RecoverAttemptOpcode:
+		s.handleRecoverAttempt(p, login)
+		return
+	}
+	if login.Opcode != 0 {
+		log.Debug("Unhandled login packet from", p, ":", login.String())
+		return
+	}
+	if !world.UpdateTime.IsZero() {
+		sendReply(handshake.ResponseServerRejection, "System update in progress")
+		return
+	}
+	if world.Players.Size() >= config.MaxPlayers() {
+		sendReply(handshake.ResponseWorldFull, "Out of usable player slots")
+		return
+	}
+	if wait := handshake.LoginThrottle.Locked(p.CurrentIP()); wait > 0 {
+		sendReply(handshake.ResponseSpamTimeout, "Too many recent invalid login attempts from this address (locked out for " + wait.Round(time.Second).String() + ")")
+		return
+	}
+
+	p.SetReconnecting(login.ReadBoolean())
+	version = login.ReadUint32()
+	if p.Revision = protocol.Find(version); p.Revision == nil {
+		sendReply(handshake.ResponseUpdated, "Invalid client version (" + strconv.Itoa(version) + ")")
+		return
+	}
+
+	rsaSize := login.ReadUint16()
+	data := make([]byte, rsaSize)
+	rsaRead := login.Read(data)
+	if rsaRead < rsaSize {
+		log.Debug("short RSA block")
+		p.Writer.Write([]byte{byte(handshake.ResponseServerRejection)})
+		p.Writer.Flush()
+		return
+	} 
+
+	rsaData := rsa.RsaKeyPair.Decrypt(data)
+	offset := 0
+	checksum := rsaData[offset]
+	offset++
+	// It's been suggested to me that this first byte assures us that the RSA block could decode properly,
+	// it's only wrong for this purpose a statistically insignificant amount of time.  >99% accurate, as I understand it.
+	if checksum != 10 {
+		log.Debug("Bad checksum:", checksum)
+		p.Writer.Write([]byte{byte(handshake.ResponseServerRejection)})
+		p.Writer.Flush()
+		return
+	}
+	var keys = make([]int, 4)
+	for i := range keys {
+		keys[i] = int(binary.BigEndian.Uint32(rsaData[offset:]))
+		offset += 4
+	}
+	if p.Revision.Cipher {
+		// one stream for each direction, both seeded the same way as the client seeds its own
+		p.OpCiphers[0] = isaac.New(keys...)
+		p.OpCiphers[1] = isaac.New(keys...)
+	}
+	// protocol pads password out to constant 19 chars long (+1 terminator) for some reason with 0x20 bytes
+	password := strings.TrimSpace(string(rsaData[offset:offset+19]))
+	offset += 20
+	// The rscplus team viewed this data below as a nonce, but in my opinion, this is not the motivation for this data.
+	// I'd call these more of an initialization vector (IV), as wikipedia defines it, used to make RSA semantically secure.
+	offset += 8
+	blockSize := login.ReadUint16()
+	var block = make([]byte, blockSize)
+	if login.Available() != blockSize {
+		log.Debug("XTEA block size recv'd doesn't take up the rest of the packets available buffer size! (it should)")
+		log.Debugf("\t{ blockSize:%d, login.Available():%d }\n", blockSize, login.Available())
+	}
+	login.Read(block)
+	offset = 0
+	// limit30 := block[offset]
+	offset++
+	usernameData := xtea.New(keys).Decrypt(block)
+	offset += 24
+	// first byte of this block is limit30 parameter from the game client applet; boolean, use unknown
+	// I suppose the next 24 bytes are to ensure the stream gets sufficiently shuffled in each packet, preventing identifying markers appearing
+	// finally, the null-terminated UTF-8 encoded username comes at offset 25 and beyond.
+	username := string(usernameData[25:])
+	p.SetVar("username", strutil.Base37.Encode(username))
+	lingering, ok := world.Players.FindHash(p.UsernameHash())
+	if ok && !lingering.Lingering() {
+		sendReply(handshake.ResponseLoggedIn, "Player with same username is already logged in")
+		return
+	}
+	// the rest waits on the database, and the tick can't wait around on that; once it's done, the player is handed
+	// back to the tick to join the world
+	go func() {
+		ctx, cancel := s.loginContext()
+		defer cancel()
+		timedOut := func() bool {
+			if reason := interrupted(ctx); reason != "" {
+				sendReply(handshake.ResponseServerRejection, reason)
+				return true
+			}
+			return false
+		}
+		ban := world.FindBan(ctx, 0, p.CurrentIP())
+		if timedOut() {
+			return
+		}
+		if ban != nil {
+			sendReply(banResponse(ban), "Address is banned " + ban.Length() + " (" + ban.Reason + ")")
+			return
+		}
+		accountKey := handshake.AccountKey(p.UsernameHash())
+		if wait := handshake.AccountThrottle.Locked(accountKey); wait > 0 {
+			sendReply(handshake.ResponseSpamTimeout, "Too many recent invalid login attempts on this account (locked out for " + wait.Round(time.Second).String() + ")")
+			return
+		}
+		var dataService = db.DefaultPlayerService
+		exists := dataService.PlayerNameExists(ctx, p.Username())
+		if timedOut() {
+			return
+		}
+		if !exists {
+			handshake.LoginThrottle.Add(p.CurrentIP())
+			sendReply(handshake.ResponseBadPassword, "Invalid credentials")
+			return
+		}
+		valid := dataService.PlayerValidLogin(ctx, p.UsernameHash(), password)
+		if timedOut() {
+			return
+		}
+		if !valid {
+			handshake.LoginThrottle.Add(p.CurrentIP())
+			handshake.AccountThrottle.Add(accountKey)
+			sendReply(handshake.ResponseBadPassword, "Invalid credentials")
+			return
+		}
+		// the owner knows the password, so whatever was failing before doesn't need to hold them up next time
+		handshake.AccountThrottle.Reset(accountKey)
+		if ban = world.FindBan(ctx, p.UsernameHash(), ""); timedOut() {
+			return
+		}
+		if ban != nil {
+			sendReply(banResponse(ban), "Account is banned " + ban.Length() + " (" + ban.Reason + ")")
+			return
+		}
+		// the player never left the world if it was lingering, so there is nothing to load
+		if !ok && !dataService.PlayerLoad(ctx, p) {
+			if timedOut() {
+				return
+			}
+			sendReply(handshake.ResponseDecodeFailure, "Could not load player profile; is the dataService setup properly?")
+			return
+		}
+		// looks up when they were last here for the welcome box, too, so it has to come before they join the world
+		world.RecordLogin(s.queries, p, version, "")
+
+		s.admit(func(admitted bool) {
+			if !admitted {
+				sendReply(handshake.ResponseServerRejection, "Server is shutting down")
+				return
+			}
+			if ok {
+				// just hand the lingering player our connection
+				if !lingering.Rebind(p) {
+					// its reconnect window ran out while we checked the password, so it has to finish saving first
+					sendReply(handshake.ResponseLoggedIn, "Player with same username is still being logged out")
+					return
+				}
+				p = lingering
+			} else if _, found := world.Players.FindHash(p.UsernameHash()); found {
+				// someone else logged in to this account while we were loading it
+				sendReply(handshake.ResponseLoggedIn, "Player with same username is already logged in")
+				return
+			} else if world.Players.Size() >= config.MaxPlayers() {
+				sendReply(handshake.ResponseWorldFull, "Out of usable player slots")
+				return
+			}
+
+			if p.Reconnecting() {
+				sendReply(handshake.ResponseReconnected, "")
+				return
+			}
+			accepted := handshake.ResponseLoginSuccess
+			switch p.Rank() {
+			case 2:
+				accepted = handshake.ResponseAdministrator
+			case 1:
+				accepted = handshake.ResponseModerator
+			}
+			if p.Revision.LoginAcceptBit() {
+				accepted |= handshake.ResponseLoginAcceptBit
+			}
+			sendReply(accepted, "")
+		})
+	}()
+}
+
+//loginContext Returns the context that the database work for a client on its way in runs under, and the function
+// that releases it.  It ends once the login timeout has passed, so that a database that stopped responding turns the
+// client away rather than keeping it waiting forever, or as soon as the server starts shutting down.
+func (s *Server) loginContext() (context.Context, context.CancelFunc) {
+	if timeout := config.LoginTimeout(); timeout > 0 {
+		return context.WithTimeout(s.queries, timeout)
+	}
+	return context.WithCancel(s.queries)
+}
+
+//interrupted Returns why the database work done under a context from loginContext was cut short, or an empty string
+// if it wasn't.
+func interrupted(ctx context.Context) string {
+	switch ctx.Err() {
+	case nil:
+		return ""
+	case context.Canceled:
+		return "Server is shutting down"
+	default:
+		return "Database did not respond in time"
+	}
+}
+
+//admit Hands a login that got through the database back to the tick, which calls login with true when it gets to
+// it.  Once the tick loop has stopped, login is called with false straight away instead.
+func (s *Server) admit(login func(admitted bool)) {
+	s.admitLock.Lock()
+	if !s.admitClosed {
+		s.admitting = append(s.admitting, login)
+		s.admitLock.Unlock()
+		return
+	}
+	s.admitLock.Unlock()
+	login(false)
+}
+
+//admitted Takes every login that is waiting on the tick to join the world.  If closing is true, no more are taken in
+// after this.
+func (s *Server) admitted(closing bool) []func(bool) {
+	s.admitLock.Lock()
+	defer s.admitLock.Unlock()
+	logins := s.admitting
+	s.admitting = nil
+	s.admitClosed = s.admitClosed || closing
+	return logins
+}
+
+//banResponse Returns the login response that tells the client about ban.
+func banResponse(ban *world.Ban) handshake.ResponseCode {
+	if ban.Permanent() {
+		return handshake.ResponsePermBan
+	}
+	return handshake.ResponseTempBan
+}
+
+//readPackets Reads packets off of the players socket and queues them up to be handled by the next tick, until the
+// player logs out or the socket is lost.  A lost socket leaves the player lingering in the world, if
+// config.ReconnectWindow allows for it.
+func (s *Server) readPackets(p *world.Player) {
+	for {
+		select {
+		case <-p.Done():
+			return
+		default:
+		}
+		packet, err := p.ReadPacket()
+		if err != nil {
+			if err, ok := err.(rscerrors.NetError); ok && !err.Fatal {
+				continue
+			}
+			select {
+			case <-p.Done():
+				// logged out; the socket was closed out from under us
+				return
+			default:
+			}
+			if window := config.ReconnectWindow(); window > 0 && world.Players.Contains(p) {
+				p.Linger(window)
+				return
+			}
+			p.Unregister()
+			return
+		}
+		if packet != nil {
+			p.InQueue <- packet
+		}
+	}
+}
+
+func (s *Server) SubmitLogout(p *world.Player) {
+	// s.Lock()
+	// s.logoutQueue = append(s.logoutQueue, p)
+	select {
+	case s.logoutQ <- p:
+	case <-s.quit:
+		// Shutdown takes care of everybody left once the tick loop has stopped
+	}
+	// s.Unlock()
+}
+
+func (s *Server) SubmitLogin(p *world.Player) {
+	// s.Lock()
+	select {
+	case s.loginQ <- p:
+	case <-s.quit:
+		p.Socket.Close()
+	}
+	// s.loginQueue = append(s.loginQueue, p)
+	// s.Unlock()
+}
+
+func (s *Server) DebugTicks() {
+	s.debug = !s.debug
+}
+
+func (s *Server) runLogout(p *world.Player) {
+	s.logouts.Add(1)
+	go func() {
+		defer s.logouts.Done()
+		p.Destroy()
+	}()
+}
+
+func (s *Server) WrapWaitRoutines (ps []*world.Player, fns ...func(*world.Player)) ( func() ) {
+	if len(ps) == 0 {
+		return nil
+	}
+	return func() {
+		wait := sync.WaitGroup{}
+		for _, p := range ps {
+			select {
+			case <-p.Done():
+				return
+			default:
+				for _, fn := range fns {
+					if p == nil {
+						return
+					}
+				
+				// case <-p.Done():
+					// return
+					wait.Add(1)
+					go func(p *world.Player, fn func(*world.Player)) {
+						defer wait.Done()
+						fn(p)
+					}(p, fn)
+				}
+			}
+		}
+		// select {
+			// case <-ctx.Done():
+				// return
+			// default:
+				// for _, fn := range fns {
+					// go fn()
+				// }
+				// // world.Players.Range(func(p *world.Player) {
+					// // p.ProcPacketsIn() // dequeue incoming packets.  These are read off the socket then queued by each players own goroutine
+				// // })
+		// }
+		wait.Wait()
+	}
+	// })(context.WithCancel(ctx))
+}
@@ -25,6 +25,8 @@ type FriendsList struct {
 	sync.RWMutex
 	friendSet
 	Owner uint64
+	// changes counts every name added to or removed from the list; status flips don't count
+	changes int
 }
 
 // TODO: Should I remove the owner username hash from this?  Is it really beneficial to use like this
@@ -74,7 +76,11 @@ func (f *FriendsList) ContainsHash(hash uint64) bool {
 func (f *FriendsList) Add(name string) {
 	f.Lock()
 	defer f.Unlock()
-	f.friendSet[strutil.Base37.Encode(name)] = false
+	hash := strutil.Base37.Encode(name)
+	if _, ok := f.friendSet[hash]; !ok {
+		f.changes++
+	}
+	f.friendSet[hash] = false
 }
 
 //ToggleStatus will flip the boolean value mapped to name then return true, or if no such entry exists, does nothing and returns false.
@@ -95,13 +101,20 @@ func (f *FriendsList) ToggleStatus(name string) bool {
 func (f *FriendsList) Set(name string, val bool) {
 	f.Lock()
 	defer f.Unlock()
-	f.friendSet[strutil.Base37.Encode(name)] = val
+	hash := strutil.Base37.Encode(name)
+	if _, ok := f.friendSet[hash]; !ok {
+		f.changes++
+	}
+	f.friendSet[hash] = val
 }
 
 func (f *FriendsList) Remove(name string) {
 	f.Lock()
 	defer f.Unlock()
 	hash := strutil.Base37.Encode(name)
+	if _, ok := f.friendSet[hash]; ok {
+		f.changes++
+	}
 	delete(f.friendSet, hash)
 	//	if p, ok := Players.FromUserHash(hash); ok && p.FriendList.contains(f.Owner) {
 	//		p.SendPacket(FriendUpdate(f.Owner, false))
@@ -146,3 +159,11 @@ func (f *FriendsList) Size() int {
 	defer f.RUnlock()
 	return len(f.friendSet)
 }
+
+//Changes Returns how many times a name has been added to or removed from this list.  A save can compare it to the
+// count it last persisted, to tell whether there is anything new to write.
+func (f *FriendsList) Changes() int {
+	f.RLock()
+	defer f.RUnlock()
+	return f.changes
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package game
+
+import (
+	"bufio"
+	"crypto/tls"
+	stdnet "net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/log"
+)
+
+//identifyTimeout How long a new connection gets to send its proxy header, and finish its TLS handshake and websocket
+// upgrade, if it needs them.
+const identifyTimeout = time.Second * 10
+
+//certificate Keeps a TLS certificate loaded from disk, and loads it again whenever its files change, so that renewed
+// certificates get used without restarting the server.
+type certificate struct {
+	certFile, keyFile string
+	sync.RWMutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+//newTLSConfig Returns a TLS config that serves the certificate and key in the provided PEM files.
+// Returns an error if they can not be loaded.
+func newTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	c := &certificate{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{GetCertificate: c.get, MinVersion: tls.VersionTLS12}, nil
+}
+
+//lastModified Returns the most recent modification time of the certificate and key files.
+func (c *certificate) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (c *certificate) reload() error {
+	modified, err := c.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	c.cert, c.modified = &cert, modified
+	c.Unlock()
+	return nil
+}
+
+//get Returns the current certificate, loading it again first if its files have changed since it was last loaded.
+// If loading it fails, e.g because the files are only half written, the old certificate keeps getting used.
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.RLock()
+	cert, loaded := c.cert, c.modified
+	c.RUnlock()
+	if modified, err := c.lastModified(); err == nil && modified.After(loaded) {
+		if err := c.reload(); err != nil {
+			log.Warn("Could not reload TLS certificate:", err)
+		} else {
+			log.Info.Println("Reloaded TLS certificate from", c.certFile)
+			c.RLock()
+			cert = c.cert
+			c.RUnlock()
+		}
+	}
+	return cert, nil
+}
+
+//sniffedConn A connection that has had its first bytes peeked at, to work out whether it is using TLS or is coming
+// through a proxy.
+type sniffedConn struct {
+	stdnet.Conn
+	reader *bufio.Reader
+	// remote is where a trusted proxy says the client is, or nil if it is not behind one
+	remote stdnet.Addr
+}
+
+func newSniffedConn(socket stdnet.Conn) *sniffedConn {
+	return &sniffedConn{Conn: socket, reader: bufio.NewReader(socket)}
+}
+
+func (c *sniffedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+//RemoteAddr Returns the address of the client, which is where the proxy says it is if it came through a trusted one.
+func (c *sniffedConn) RemoteAddr() stdnet.Addr {
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+//unwrapTLS Works out whether the client on the other end of conn started a TLS handshake, from the first byte
+// that it sends.  If it did and TLS is enabled, the handshake gets done here.  Returns the connection to use from now
+// on, which is conn itself for plain clients.  The caller is responsible for any deadline on the socket.
+func (s *Server) unwrapTLS(conn *sniffedConn) (stdnet.Conn, error) {
+	first, err := conn.reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] == 0x16 && s.tls != nil {
+		// a TLS handshake record; none of the handshake packets are 22 bytes long, so this can't be a plain client
+		secure := tls.Server(conn, s.tls)
+		if err := secure.Handshake(); err != nil {
+			return nil, err
+		}
+		return secure, nil
+	}
+	return conn, nil
+}
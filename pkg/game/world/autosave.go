@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package world
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/tasks"
+)
+
+//SaveStats Counters for every player save that has been attempted, whether from an autosave or a logout.
+var SaveStats = struct {
+	Saves    *atomic.Uint64
+	Failures *atomic.Uint64
+	// Latency is the total time spent saving, so Latency / Saves gives the mean time for a save
+	Latency     *atomic.Duration
+	LastLatency *atomic.Duration
+	MaxLatency  *atomic.Duration
+}{atomic.NewUint64(0), atomic.NewUint64(0), atomic.NewDuration(0), atomic.NewDuration(0), atomic.NewDuration(0)}
+
+func recordSave(latency time.Duration, ok bool) {
+	SaveStats.Saves.Inc()
+	if !ok {
+		SaveStats.Failures.Inc()
+	}
+	SaveStats.Latency.Add(latency)
+	SaveStats.LastLatency.Store(latency)
+	for max := SaveStats.MaxLatency.Load(); latency > max; max = SaveStats.MaxLatency.Load() {
+		if SaveStats.MaxLatency.CAS(max, latency) {
+			break
+		}
+	}
+}
+
+//save Persists the provided snapshot of this player using the DefaultPlayerService.
+// Saves of the same player never overlap, and a snapshot older than the last one saved is thrown out, so that a slow
+// autosave can never overwrite what got saved when the player logged out.
+// Each snapshot is handed the one persisted before it, so that only what changed in between needs writing.
+func (p *Player) save(s *PlayerSnapshot) bool {
+	p.saveLock.Lock()
+	defer p.saveLock.Unlock()
+	if s.Taken.Before(p.lastSave) {
+		return true
+	}
+	s.Previous = p.persisted
+	start := time.Now()
+	ok := DefaultPlayerService.PlayerSaveSnapshot(context.Background(), s)
+	recordSave(time.Since(start), ok)
+	s.Previous = nil
+	if ok {
+		p.lastSave = s.Taken
+		p.persisted = s
+	}
+	return ok
+}
+
+//StartAutosave Schedules a task that saves every player in the world once per interval.  An interval of zero or less
+// disables autosaving.
+// Players are split up by server index across every tick in the interval, so that only a few of them are saved each
+// tick.  They get copied during the tick, and the copies are persisted in the background, so the tick never has to
+// wait on the database.
+func StartAutosave(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticks := int(interval / TickMillis)
+	if ticks < 1 {
+		ticks = 1
+	}
+	tasks.Schedule(1, func() bool {
+		slot := CurrentTick() % ticks
+		var batch []*PlayerSnapshot
+		var players []*Player
+		Players.Range(func(p *Player) {
+			if p.Connected() && p.ServerIndex()%ticks == slot {
+				batch = append(batch, p.Snapshot())
+				players = append(players, p)
+			}
+		})
+		if len(batch) > 0 {
+			go func() {
+				for i, p := range players {
+					if !p.save(batch[i]) {
+						log.Warn("Autosave failed for", batch[i].Username)
+					}
+				}
+			}()
+		}
+		return false
+	})
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package world
+
+import (
+	"context"
+	stdnet "net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//Ban A ban on logging in, placed on either an account, an IP address, or a CIDR range of IP addresses.
+type Ban struct {
+	//UserHash The base37 username hash of the banned account, or 0 if this bans an address.
+	UserHash uint64
+	//Address The banned IP address or CIDR range, or empty if this bans an account.
+	Address   string
+	Reason    string
+	Moderator string
+	Issued    time.Time
+	//Expires When this ban gets lifted.  The zero time means it never will.
+	Expires time.Time
+}
+
+//Kind Returns what sort of thing is banned; one of account, ip or range.
+func (b *Ban) Kind() string {
+	if len(b.Address) == 0 {
+		return "account"
+	}
+	if strings.Contains(b.Address, "/") {
+		return "range"
+	}
+	return "ip"
+}
+
+//Target Returns the banned username, IP address or CIDR range.
+func (b *Ban) Target() string {
+	if len(b.Address) == 0 {
+		return strutil.Base37.Decode(b.UserHash)
+	}
+	return b.Address
+}
+
+//Permanent Returns true if this ban never expires.
+func (b *Ban) Permanent() bool {
+	return b.Expires.IsZero()
+}
+
+//Length Returns a description of how long this ban lasts, for messages and logs.
+func (b *Ban) Length() string {
+	if b.Permanent() {
+		return "permanently"
+	}
+	return "until " + b.Expires.Format(time.RFC1123)
+}
+
+//Active Returns true if this ban has not expired yet.
+func (b *Ban) Active() bool {
+	return b.Permanent() || time.Now().Before(b.Expires)
+}
+
+//Matches Returns true if this ban applies to the provided account or IP address.  Either can be left out, by
+// passing 0 or an empty string.
+func (b *Ban) Matches(userHash uint64, ip string) bool {
+	if len(b.Address) == 0 {
+		return userHash != 0 && b.UserHash == userHash
+	}
+	addr := stdnet.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	if _, block, err := stdnet.ParseCIDR(b.Address); err == nil {
+		return block.Contains(addr)
+	}
+	return addr.Equal(stdnet.ParseIP(b.Address))
+}
+
+//BanService An interface for persisting bans.  Every method takes a context that cancels whatever it is waiting on the
+// database for, and on top of that gives up once the configured query timeout has passed.
+type BanService interface {
+	//BanAdd Saves a new ban, replacing any ban on the same target.
+	BanAdd(context.Context, *Ban) bool
+	//BanRemove Removes every ban on the provided account, or address if userHash is 0.
+	// Returns true if there was anything to remove.
+	BanRemove(ctx context.Context, userHash uint64, address string) bool
+	//BanList Returns every ban that has not expired yet, or false if they could not be read.
+	BanList(context.Context) ([]*Ban, bool)
+}
+
+//DefaultBanService The ban service in use by the game server, set up the same way as DefaultPlayerService.
+var DefaultBanService BanService
+
+//FindBan Returns the active ban that applies to the provided account or IP address, or nil if there isn't one.
+// Either can be left out, by passing 0 or an empty string.  ctx is only used when the bans need loading again.
+func FindBan(ctx context.Context, userHash uint64, ip string) *Ban {
+	if DefaultBanService == nil {
+		return nil
+	}
+	for _, ban := range cachedBans(ctx) {
+		if ban.Active() && ban.Matches(userHash, ip) {
+			return ban
+		}
+	}
+	return nil
+}
+
+//banCacheLife How long the cached ban list is trusted for, so that bans placed outside of this process get seen
+// eventually.
+const banCacheLife = time.Minute
+
+//banCache Holds the ban list in memory between logins, so looking bans up does not go through the database.
+// AddBan and RemoveBan throw it away whenever they change anything.
+var banCache struct {
+	sync.Mutex
+	service BanService
+	bans    []*Ban
+	loaded  time.Time
+}
+
+func cachedBans(ctx context.Context) []*Ban {
+	banCache.Lock()
+	defer banCache.Unlock()
+	if banCache.service != DefaultBanService || banCache.loaded.IsZero() || time.Since(banCache.loaded) >= banCacheLife {
+		bans, ok := DefaultBanService.BanList(ctx)
+		if !ok {
+			// the last bans that did load are better than none, and it gets tried again on the next lookup
+			if banCache.service != DefaultBanService {
+				return nil
+			}
+			return banCache.bans
+		}
+		banCache.service = DefaultBanService
+		banCache.bans = bans
+		banCache.loaded = time.Now()
+	}
+	return banCache.bans
+}
+
+func forgetBans() {
+	banCache.Lock()
+	defer banCache.Unlock()
+	banCache.loaded = time.Time{}
+}
+
+//ParseBanAddress Returns address in the form it gets saved in, if it is a valid IP address or CIDR range,
+// otherwise returns an empty string.
+func ParseBanAddress(address string) string {
+	if ip := stdnet.ParseIP(address); ip != nil {
+		return ip.String()
+	}
+	if _, block, err := stdnet.ParseCIDR(address); err == nil {
+		return block.String()
+	}
+	return ""
+}
+
+//AddBan Saves ban, and logs out every player that it applies to.  Returns false if the ban could not be saved.
+func AddBan(ctx context.Context, ban *Ban) bool {
+	if DefaultBanService == nil || !DefaultBanService.BanAdd(ctx, ban) {
+		return false
+	}
+	forgetBans()
+	log.Command(ban.Moderator, "banned", ban.Kind(), ban.Target(), ban.Length(), "for:", ban.Reason)
+	Players.Range(func(p *Player) {
+		if ban.Matches(p.UsernameHash(), p.CurrentIP()) {
+			// this usually runs from a command, during the tick that empties the logout queue
+			go p.Unregister()
+		}
+	})
+	return true
+}
+
+//BanAccount Bans the account with the provided username for length, or forever if length is 0.
+// Returns false if the ban could not be saved.
+func BanAccount(ctx context.Context, moderator, username string, length time.Duration, reason string) bool {
+	return AddBan(ctx, newBan(moderator, strutil.Base37.Encode(username), "", length, reason))
+}
+
+//BanAddress Bans the provided IP address or CIDR range for length, or forever if length is 0.
+// Returns false if address is not a valid IP address or CIDR range, or if the ban could not be saved.
+func BanAddress(ctx context.Context, moderator, address string, length time.Duration, reason string) bool {
+	if address = ParseBanAddress(address); len(address) == 0 {
+		return false
+	}
+	return AddBan(ctx, newBan(moderator, 0, address, length, reason))
+}
+
+func newBan(moderator string, userHash uint64, address string, length time.Duration, reason string) *Ban {
+	ban := &Ban{UserHash: userHash, Address: address, Reason: reason, Moderator: moderator, Issued: time.Now()}
+	if length > 0 {
+		ban.Expires = ban.Issued.Add(length)
+	}
+	return ban
+}
+
+//RemoveBan Lifts every ban on target, which is either an IP address, a CIDR range or a username.
+// Returns true if there was anything to lift.
+func RemoveBan(ctx context.Context, moderator, target string) bool {
+	if DefaultBanService == nil {
+		return false
+	}
+	removed := false
+	if address := ParseBanAddress(target); len(address) > 0 {
+		removed = DefaultBanService.BanRemove(ctx, 0, address)
+	} else {
+		removed = DefaultBanService.BanRemove(ctx, strutil.Base37.Encode(target), "")
+	}
+	if removed {
+		forgetBans()
+		log.Command(moderator, "lifted bans on", target)
+	}
+	return removed
+}
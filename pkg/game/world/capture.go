@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package world
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+	"github.com/spkaeros/rscgo/pkg/game/net/capture"
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//StartCapture Starts recording every packet that this player sends and receives, to a new file in the capture
+// directory.  Returns the name of the file, or an error if it could not be created.
+func (p *Player) StartCapture() (string, error) {
+	p.captureLock.Lock()
+	defer p.captureLock.Unlock()
+	if p.capture != nil {
+		return p.capture.Name, nil
+	}
+	if err := os.MkdirAll(config.CaptureDirectory(), 0755); err != nil {
+		return "", err
+	}
+	version := config.Version()
+	if p.Revision != nil {
+		version = p.Revision.Version
+	}
+	now := time.Now()
+	// captures of the same player started within the same second must not clobber each other
+	name := filepath.Join(config.CaptureDirectory(), p.Username()+"-"+strconv.FormatInt(now.UnixNano(), 10)+".capture")
+	w, err := capture.Create(name, capture.Header{Username: p.Username(), Version: version, Started: now})
+	if err != nil {
+		return "", err
+	}
+	p.capture = w
+	return name, nil
+}
+
+//StopCapture Stops recording this players packets.  Returns the name of the finished capture file, or an empty string
+// if there was no capture running.
+func (p *Player) StopCapture() string {
+	p.captureLock.Lock()
+	defer p.captureLock.Unlock()
+	if p.capture == nil {
+		return ""
+	}
+	name := p.capture.Name
+	if err := p.capture.Close(); err != nil {
+		log.Warn("Could not finish packet capture", name+":", err)
+	}
+	p.capture = nil
+	return name
+}
+
+//captured Records a packet to this players capture, if it has one running.
+func (p *Player) captured(inbound bool, opcode byte, payload []byte) {
+	p.captureLock.Lock()
+	defer p.captureLock.Unlock()
+	if p.capture == nil {
+		return
+	}
+	if err := p.capture.Write(inbound, opcode, payload); err != nil {
+		log.Warn("Could not write to packet capture", p.capture.Name+"; stopping it:", err)
+		p.capture.Close()
+		p.capture = nil
+	}
+}
+
+//ToggleCapture Starts capturing the packets of the online player named target, or stops it if one is already
+// running, on behalf of moderator.  Returns a message describing what happened.
+func ToggleCapture(moderator, target string) string {
+	player, ok := Players.FindHash(strutil.Base37.Encode(target))
+	if !ok || player == nil {
+		return "Could not find anyone online named '" + target + "'."
+	}
+	if name := player.StopCapture(); name != "" {
+		log.Command(moderator, "stopped capturing packets from", player.Username())
+		return "Stopped capturing " + player.Username() + "'s packets, to " + name
+	}
+	name, err := player.StartCapture()
+	if err != nil {
+		log.Warn("Could not start packet capture:", err)
+		return "Could not start capturing " + player.Username() + "'s packets."
+	}
+	log.Command(moderator, "started capturing packets from", player.Username())
+	return "Capturing " + player.Username() + "'s packets, to " + name
+}
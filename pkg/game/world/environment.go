@@ -10,6 +10,7 @@
 package world
 
 import (
+	"context"
 	"os"
 	"runtime/pprof"
 	"reflect"
@@ -24,8 +25,10 @@ import (
 	"github.com/mattn/anko/env"
 	"github.com/mattn/anko/vm"
 	"github.com/mattn/anko/parser"
+	"github.com/spkaeros/rscgo/pkg/crypto"
 	"github.com/spkaeros/rscgo/pkg/definitions"
 	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
 	"github.com/spkaeros/rscgo/pkg/game/entity"
 	"github.com/spkaeros/rscgo/pkg/log"
 	"github.com/spkaeros/rscgo/pkg/tasks"
@@ -41,6 +44,30 @@ var CommandHandlers = make(map[string]func(*Player, []string))
 
 const serverPrefix = "@que@@whi@[@cya@SERVER@whi@]: "
 
+//scriptContext The context that database work started by scripts runs under; see SetScriptContext.
+var scriptContext struct {
+	sync.RWMutex
+	ctx context.Context
+}
+
+//SetScriptContext Makes database work started by scripts run under ctx, which the game server cancels when it shuts
+// down.  Until this is called it runs under context.Background.
+func SetScriptContext(ctx context.Context) {
+	scriptContext.Lock()
+	defer scriptContext.Unlock()
+	scriptContext.ctx = ctx
+}
+
+//ScriptContext Returns the context that database work started by scripts runs under.
+func ScriptContext() context.Context {
+	scriptContext.RLock()
+	defer scriptContext.RUnlock()
+	if scriptContext.ctx == nil {
+		return context.Background()
+	}
+	return scriptContext.ctx
+}
+
 func init() {
 	env.Packages["state"] = map[string]reflect.Value{
 		"UsingItem":			  reflect.ValueOf(MSItemAction),
@@ -71,6 +98,8 @@ func init() {
 		"getItem":                reflect.ValueOf(GetItem),
 		"itemActions":            reflect.ValueOf(&ItemTriggers),
 		"unhandledMessage":       reflect.ValueOf(DefaultActionMessage),
+		"WelcomeMessage":         reflect.ValueOf(WelcomeMessage),
+		"FriendUpdate":           reflect.ValueOf(FriendUpdate),
 		"addItem":                reflect.ValueOf(AddItem),
 		"removeItem":             reflect.ValueOf(RemoveItem),
 		"maxX":                   reflect.ValueOf(MaxX),
@@ -83,6 +112,38 @@ func init() {
 		"kickPlayer": reflect.ValueOf(func(client *Player) {
 			client.Unregister()
 		}),
+		"banAccount": reflect.ValueOf(func(moderator *Player, username string, hours int, reason string) bool {
+			return BanAccount(ScriptContext(), moderator.Username(), username, time.Duration(hours)*time.Hour, reason)
+		}),
+		"banAddress": reflect.ValueOf(func(moderator *Player, address string, hours int, reason string) bool {
+			return BanAddress(ScriptContext(), moderator.Username(), address, time.Duration(hours)*time.Hour, reason)
+		}),
+		"validLogin": reflect.ValueOf(func(userHash uint64, password string) bool {
+			return DefaultPlayerService.PlayerValidLogin(ScriptContext(), userHash, password)
+		}),
+		"changePassword": reflect.ValueOf(func(userHash uint64, password string) bool {
+			hash, err := crypto.Hash(password)
+			if err != nil {
+				log.Warn("Could not hash new password:", err)
+				return false
+			}
+			return DefaultPlayerService.PlayerChangePassword(ScriptContext(), userHash, hash)
+		}),
+		"saveRecoverys": reflect.ValueOf(func(userHash uint64, questions []string, answers []uint64) bool {
+			return DefaultPlayerService.SaveRecoveryQuestions(ScriptContext(), userHash, questions, answers)
+		}),
+		"loginHistory": reflect.ValueOf(func(username string, limit int) []*LoginAttempt {
+			return LoginHistory(ScriptContext(), username, limit)
+		}),
+		"unban": reflect.ValueOf(func(moderator *Player, target string) bool {
+			return RemoveBan(ScriptContext(), moderator.Username(), target)
+		}),
+		"unthrottle": reflect.ValueOf(func(moderator *Player, target string) bool {
+			return RemoveThrottle(moderator.Username(), target)
+		}),
+		"capture": reflect.ValueOf(func(moderator *Player, target string) string {
+			return ToggleCapture(moderator.Username(), target)
+		}),
 		"updateStarted": reflect.ValueOf(func() bool {
 			return !UpdateTime.IsZero()
 		}),
@@ -99,11 +160,7 @@ func init() {
 			UpdateTime = time.Now().Add(time.Second * time.Duration(t))
 			tasks.Schedule(1, func() bool {
 				if time.Since(start) >= time.Duration(t) * time.Second {
-					Players.Range(func(player *Player) {
-						player.Unregister()
-					})
-					time.Sleep(2 * time.Second)
-					os.Exit(200)
+					DefaultServer.Stop()
 					return true
 				}
 				if CurrentTick() % 10 == 0 {
@@ -160,67 +217,11 @@ func init() {
 		"npc":        reflect.TypeOf(&NPC{}),
 		"location":   reflect.TypeOf(Location{}),
 	}
-	env.Packages["packets"] = map[string]reflect.Value{
-		"ping": reflect.ValueOf(67),
-		"tradeRequest": reflect.ValueOf(142),
-		"tradeDecline": reflect.ValueOf(230),
-		"tradeAccept": reflect.ValueOf(55),
-		"tradeAccept2": reflect.ValueOf(104),
-		"tradeUpdate": reflect.ValueOf(46),
-		"follow": reflect.ValueOf(165),
-		"closeBank": reflect.ValueOf(212),
-		"depositBank": reflect.ValueOf(23),
-		"withdrawBank": reflect.ValueOf(22),
-		"menuAnswer": reflect.ValueOf(116),
-		"equip": reflect.ValueOf(169),
-		"npcChat": reflect.ValueOf(153),
-		"npcAction": reflect.ValueOf(202),
-		"sceneAction": reflect.ValueOf(136),
-		"sceneAction2": reflect.ValueOf(79),
-		"boundaryAction": reflect.ValueOf(127),
-		"boundaryAction2": reflect.ValueOf(14),
-		"invOnScene": reflect.ValueOf(115),
-		"invOnBoundary": reflect.ValueOf(161),
-		"unequip": reflect.ValueOf(170),
-		"dropItem": reflect.ValueOf(246),
-		"recoverAccount": reflect.ValueOf(220),
-		"closeStream": reflect.ValueOf(31),
-		"logout": reflect.ValueOf(102),
-		"pickupItem": reflect.ValueOf(247),
-		"itemAction": reflect.ValueOf(90),
-		"spellOnNpc": reflect.ValueOf(50),
-		"spellOnGroundItem": reflect.ValueOf(249),
-		"spellOnInvItem": reflect.ValueOf(4),
-		"spellOnPlayer": reflect.ValueOf(229),
-		"spellOnSelf": reflect.ValueOf(137),
-		"ticketRequests": reflect.ValueOf(163),
-		"appearance": reflect.ValueOf(235),
-		"report": reflect.ValueOf(206),
-		"attackNpc": reflect.ValueOf(190),
-		"attackPlayer": reflect.ValueOf(171),
-		"fightMode": reflect.ValueOf(29),
-		"duelRequest": reflect.ValueOf(103),
-		"duelDecline": reflect.ValueOf(197),
-		"duelAccept": reflect.ValueOf(176),
-		"duelAccept2": reflect.ValueOf(77),
-		"duelUpdate": reflect.ValueOf(33),
-		"duelSettings": reflect.ValueOf(8),
-		"settings": reflect.ValueOf(111),
-		"privacySettings": reflect.ValueOf(64),
-		"shopSell": reflect.ValueOf(221),
-		"shopBuy": reflect.ValueOf(236),
-		"shopClose": reflect.ValueOf(166),
-		"command": reflect.ValueOf(38),
-		"chat": reflect.ValueOf(216),
-		"cancelRecoverys": reflect.ValueOf(196),
-		"changePassword": reflect.ValueOf(25),
-		"changeRecoverys": reflect.ValueOf(203),
-		"recoverys": reflect.ValueOf(208),
-		"prayerOn": reflect.ValueOf(60),
-		"prayerOff": reflect.ValueOf(254),
-		"walkRequest": reflect.ValueOf(187),
-		"walkAction": reflect.ValueOf(16),
+	packets := make(map[string]reflect.Value, len(protocol.Inbound))
+	for name, opcode := range protocol.Inbound {
+		packets[name] = reflect.ValueOf(int(opcode))
 	}
+	env.Packages["packets"] = packets
 	env.Packages["ids"] = map[string]reflect.Value{
 		"COOKEDMEAT":               reflect.ValueOf(132),
 		"BURNTMEAT":                reflect.ValueOf(134),
@@ -660,19 +661,11 @@ func ScriptEnv() *env.Env {
 }
 func init() {
 	CommandHandlers["shutdown"] = func(player *Player, args []string) {
-		wait := sync.WaitGroup{}
 		Players.Range(func(p1 *Player) {
-			wait.Add(1)
-			go func() {
-				defer wait.Done()
-				p1.Message(serverPrefix + "Shutting down.")
-				time.Sleep(TickMillis)
-				p1.WriteNow(*Logout)
-				p1.Unregister()
-			}()
+			p1.Message(serverPrefix + "Shutting down.")
 		})
-		wait.Wait()
-		os.Exit(1)
+		log.Command(player.Username() + " has shut the server down.")
+		player.Server().Stop()
 	}
 	CommandHandlers["memdump"] = func(player *Player, args []string) {
 		file, err := os.Create("rscgo.mprof")
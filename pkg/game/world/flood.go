@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package world
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+	"github.com/spkaeros/rscgo/pkg/log"
+)
+
+//floodWindow How long going over a packet budget counts against a client.
+const floodWindow = time.Minute
+
+//packetBudgets How many of each opcode a client may send per tick, as loaded from the packets file.  0 means no limit.
+var packetBudgets [256]int
+
+//packetName Returns the name that opcode has in the packets file, or failing that its logical name, for logging.
+func packetName(opcode byte) string {
+	for _, limit := range pDefinitions.Limits {
+		if byte(limit.Opcode) == opcode {
+			return limit.Name
+		}
+	}
+	for name, inbound := range protocol.Inbound {
+		if inbound == opcode {
+			return name
+		}
+	}
+	return "opcode " + strconv.Itoa(int(opcode))
+}
+
+//packetCounter Counts how many of each opcode a client has sent during one tick.
+type packetCounter [256]int
+
+//allow Counts another packet with the provided opcode, and returns false if that puts it over its budget.
+func (c *packetCounter) allow(opcode byte) bool {
+	c[opcode]++
+	return packetBudgets[opcode] <= 0 || c[opcode] <= packetBudgets[opcode]
+}
+
+//checkBudgets Logs every opcode that p sent more of this tick than its budget allows, and disconnects p if it has
+// gone over budget on too many ticks within floodWindow.
+func (p *Player) checkBudgets(sent *packetCounter) {
+	over := false
+	for opcode, count := range sent {
+		if budget := packetBudgets[opcode]; budget > 0 && count > budget {
+			if !over {
+				over = true
+				if time.Since(p.floodSince) >= floodWindow {
+					p.floodStrikes, p.floodSince = 0, time.Now()
+				}
+				p.floodStrikes++
+			}
+			log.Suspicious.Printf("%v sent %d %s packets in one tick; dropped %d over its budget of %d (strike %d)\n",
+				p, count, packetName(byte(opcode)), count-budget, budget, p.floodStrikes)
+		}
+	}
+	if over && pDefinitions.MaxStrikes > 0 && p.floodStrikes >= pDefinitions.MaxStrikes {
+		log.Suspicious.Println(p, "was disconnected for going over its packet budgets", p.floodStrikes, "times within", floodWindow)
+		p.Unregister()
+	}
+}
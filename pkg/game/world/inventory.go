@@ -315,6 +315,8 @@ type Inventory struct {
 	Capacity        int
 	stackEverything bool
 	Lock            sync.RWMutex
+	// changes counts every change made to the items in this inventory
+	changes         atomic.Int64
 }
 
 type itemSorter []*Item
@@ -450,6 +452,7 @@ func (i *Inventory) Add(id int, qty int) int {
 		if item.Amount < 0 {
 			log.Suspicious.Println(errors.NewArgsError("*Inventory.Add(id,amt) Resulting item amount less than zero: " + strconv.FormatUint(uint64(item.Amount+qty), 10)))
 		}
+		i.touch()
 		if item.Amount+qty > math.MaxInt32 {
 			item.Amount = math.MaxInt32
 			return i.GetIndex(id)
@@ -466,6 +469,7 @@ func (i *Inventory) Add(id int, qty int) int {
 	i.Lock.Lock()
 	i.List = append(i.List, newItem)
 	i.Lock.Unlock()
+	i.touch()
 	return i.Size() - 1
 }
 
@@ -481,6 +485,7 @@ func (i *Inventory) Remove(index int) bool {
 	}
 	i.Lock.Lock()
 	defer i.Lock.Unlock()
+	i.touch()
 	size := len(i.List)
 	if index >= size {
 		log.Cheatf("Attempted removing item out of inventory bounds.  index:%d,size:%d,capacity:%d\n", index, size, i.Capacity)
@@ -505,6 +510,7 @@ func (i *Inventory) RemoveByID(id, amt int) int {
 			i.Remove(index)
 		} else {
 			i.Get(index).Amount -= amt
+			i.touch()
 		}
 	} else {
 		for j := 0; j < amt; j++ {
@@ -574,6 +580,19 @@ func (i *Inventory) Clear() {
 	i.Lock.Lock()
 	defer i.Lock.Unlock()
 	i.List = i.List[:0]
+	i.touch()
+}
+
+//touch Records a change made to one of the items in this inventory.  It doesn't take the inventory lock, so it is
+// safe to call while ranging over the items.
+func (i *Inventory) touch() {
+	i.changes.Inc()
+}
+
+//Changes Returns how many times the items in this inventory have changed.  A save can compare it to the count it last
+// persisted, to tell whether there is anything new to write.
+func (i *Inventory) Changes() int {
+	return int(i.changes.Load())
 }
 
 func (i *Item) String() string {
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package world
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//LoginAttempt One attempt at logging in to an account, successful or not.
+type LoginAttempt struct {
+	UserHash  uint64
+	Time      time.Time
+	IP        string
+	Version   int
+	Websocket bool
+	//Reason Why the attempt failed, or empty if it succeeded.
+	Reason string
+}
+
+//Success Returns true if this attempt logged in.
+func (l *LoginAttempt) Success() bool {
+	return len(l.Reason) == 0
+}
+
+//Transport Returns what the client connected with; either websocket or tcp.
+func (l *LoginAttempt) Transport() string {
+	if l.Websocket {
+		return "websocket"
+	}
+	return "tcp"
+}
+
+//String Returns a one line description of this attempt, for moderators to read.
+func (l *LoginAttempt) String() string {
+	outcome := "ok"
+	if !l.Success() {
+		outcome = "failed: " + l.Reason
+	}
+	return l.Time.Format("2006-01-02 15:04") + " " + l.IP + " v" + strconv.Itoa(l.Version) + " " + l.Transport() + " " + outcome
+}
+
+//LoginHistoryService An interface for persisting the login history of accounts.  Every method takes a context that
+// cancels whatever it is waiting on the database for, and on top of that gives up once the configured query timeout
+// has passed.
+type LoginHistoryService interface {
+	//LoginAdd Saves a new login attempt.
+	LoginAdd(context.Context, *LoginAttempt) bool
+	//LoginList Returns up to limit of the most recent login attempts on the provided account, newest first.
+	LoginList(ctx context.Context, userHash uint64, limit int) []*LoginAttempt
+	//LoginLast Returns the most recent successful login to the provided account, or nil if there never was one.
+	LoginLast(ctx context.Context, userHash uint64) *LoginAttempt
+}
+
+//DefaultLoginHistoryService The login history service in use by the game server, set up the same way as
+// DefaultPlayerService.
+var DefaultLoginHistoryService LoginHistoryService
+
+//RecordLogin Saves an attempt by p to log in with the provided client version.  reason is why it failed, or empty
+// if it succeeded.  The last successful login before this one is kept on p, for the welcome box.
+func RecordLogin(ctx context.Context, p *Player, version int, reason string) {
+	if DefaultLoginHistoryService == nil {
+		return
+	}
+	if len(reason) == 0 {
+		if last := DefaultLoginHistoryService.LoginLast(ctx, p.UsernameHash()); last != nil {
+			p.SetVar("previousLogin", last)
+		}
+	}
+	DefaultLoginHistoryService.LoginAdd(ctx, &LoginAttempt{UserHash: p.UsernameHash(), Time: time.Now(), IP: p.CurrentIP(),
+		Version: version, Websocket: p.IsWebsocket(), Reason: reason})
+}
+
+//LoginHistory Returns up to limit of the most recent login attempts on the account with the provided username,
+// newest first.
+func LoginHistory(ctx context.Context, username string, limit int) []*LoginAttempt {
+	if DefaultLoginHistoryService == nil {
+		return nil
+	}
+	return DefaultLoginHistoryService.LoginList(ctx, strutil.Base37.Encode(username), limit)
+}
+
+//PreviousLogin Returns when and where from this player last logged in before now.  Players that never logged in
+// before get the current time and address.
+func (p *Player) PreviousLogin() (time.Time, string) {
+	if last, ok := p.VarChecked("previousLogin").(*LoginAttempt); ok && last != nil {
+		return last.Time, last.IP
+	}
+	// accounts from before login history was kept only have what got saved with them
+	if p.Attributes.Contains("lastLogin") {
+		return p.Attributes.VarTime("lastLogin"), p.Attributes.VarString("lastIP", p.CurrentIP())
+	}
+	return time.Now(), p.CurrentIP()
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package world
+
+import (
+	"github.com/spkaeros/rscgo/pkg/metrics"
+)
+
+var (
+	//PacketsIn Counts every packet that has been handled by ProcPacketsIn, by its decrypted opcode.
+	PacketsIn = metrics.NewCounterVec("rscgo_packets_in_total", "Packets received from clients, by opcode.", "opcode")
+	//BytesOut Counts every byte written to a player socket, framing included.
+	BytesOut = metrics.NewCounter("rscgo_bytes_out_total", "Bytes sent to clients.")
+)
+
+func init() {
+	metrics.NewGaugeFunc("rscgo_players", "Players in the world.", func() float64 {
+		return float64(Players.Size())
+	})
+	metrics.NewGaugeFunc("rscgo_npcs", "NPCs in the world.", func() float64 {
+		return float64(Npcs.Size())
+	})
+	metrics.NewCounterFunc("rscgo_player_saves_total", "Player saves attempted, from autosaves and logouts.", func() float64 {
+		return float64(SaveStats.Saves.Load())
+	})
+	metrics.NewCounterFunc("rscgo_player_save_failures_total", "Player saves that failed.", func() float64 {
+		return float64(SaveStats.Failures.Load())
+	})
+	metrics.NewCounterFunc("rscgo_player_save_seconds_total", "Time spent saving players.", func() float64 {
+		return SaveStats.Latency.Load().Seconds()
+	})
+}
@@ -43,9 +43,7 @@ func PrivateMessage(hash uint64, msg string) (p *net.Packet) {
 	p = net.NewEmptyPacket(120)
 	p.AddUint64(hash)
 	p.AddUint32(rand.Rng.Uint32()) // unique Message ID to prevent duplicate messages somehow arriving or something idk
-	for _, c := range []byte(strutil.ChatFilter.Format(msg)) {
-		p.AddUint8(c)
-	}
+	p.AddEncryptedString(msg)
 	return p
 }
 
@@ -920,7 +918,9 @@ func Sound(name string) (p *net.Packet) {
 }
 
 //LoginBox Builds a packet to create a welcome box on the client with the inactiveDays since login, and lastIP connected from.
-func LoginBox(inactiveDays int, lastIP string) (p *net.Packet) {
+// recoveryDays is how many days ago the recovery questions were set, or -1 if they never were.  The client warns the
+// player about questions that were set recently, in case it wasn't them that set them.
+func LoginBox(inactiveDays int, lastIP string, recoveryDays int) (p *net.Packet) {
 	p = net.NewEmptyPacket(182)
 	i, err := strconv.Atoi(strutil.IPToInteger(lastIP).String())
 	if err != nil {
@@ -930,8 +930,15 @@ func LoginBox(inactiveDays int, lastIP string) (p *net.Packet) {
 		p.AddUint32(uint32(i)) // IP
 	}
 	p.AddUint16(uint16(inactiveDays)) // Last logged in
-	// TODO: Recoverys
-	p.AddUint8(201) // recovery questions set days, 200 = unset, 201 = set
+	// recovery questions set days, 200 = unset, 201 = set too long ago to matter
+	switch {
+	case recoveryDays < 0:
+		p.AddUint8(200)
+	case recoveryDays >= 200:
+		p.AddUint8(201)
+	default:
+		p.AddUint8(uint8(recoveryDays))
+	}
 	// TODO: Message center
 	p.AddUint16(0) // Unread messages, number minus one, 0 does not render anything
 	return p
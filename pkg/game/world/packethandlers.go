@@ -13,14 +13,14 @@ import (
 	"github.com/BurntSushi/toml"
 
 	"github.com/spkaeros/rscgo/pkg/config"
-	"github.com/spkaeros/rscgo/pkg/game/net"
 	"github.com/spkaeros/rscgo/pkg/log"
 	// "github.com/spkaeros/rscgo/pkg/rand"
 )
 
 //HandlerFunc Represents a func that is to be called whenever a connected client receives
-// a specific incoming handlers.
-type HandlerFunc = func(*Player, *net.Packet)
+// a specific incoming handlers.  It gets the same argument that a script Trigger would: the decoded message for
+// any packet with a schema, and the raw packet otherwise.
+type HandlerFunc = func(*Player, interface{})
 
 //handlers A map with descriptive names for the keys, and functions to run for the value.
 var Handlers = make(map[string]HandlerFunc)
@@ -35,9 +35,23 @@ type packetDefinition struct {
 	//	Handler HandlerFunc
 }
 
+//packetLimit How many of one packet a client may send per tick.
+type packetLimit struct {
+	Opcode int    `toml:"opcode"`
+	Name   string `toml:"name"`
+	Budget int    `toml:"budget"`
+}
+
 //packetList Represents a mapping of descriptive names to handlers opcodes.
 type packetList struct {
 	Set []packetDefinition `toml:"packets"`
+	//DefaultBudget How many of any one packet a client may send per tick, unless it has a limit of its own.
+	// 0 means no limit.
+	DefaultBudget int `toml:"default_budget"`
+	//MaxStrikes How many ticks within floodWindow a client may go over budget on, before it gets disconnected.
+	// 0 means never.
+	MaxStrikes int           `toml:"max_strikes"`
+	Limits     []packetLimit `toml:"limits"`
 }
 
 func init() {
@@ -45,7 +59,7 @@ func init() {
 	// AddHandler("pingreq", func(*Player, *net.Packet) {})
 	// AddHandler("sessionreq", func(player *Player, p *net.Packet) {
 		// // TODO: Remove maybe...TLS deprecates the need for it
-		player.SetConnected(true)
+		// player.SetConnected(true)
 		// p.ReadUint8() // UID, useful?
 		// player.SetServerSeed(rand.Rng.Uint64())
 		// player.SendPacket(net.NewReplyPacket(nil).AddUint64(player.ServerSeed()))
@@ -58,6 +72,25 @@ func UnmarshalPackets() {
 		log.Error.Fatalln("Could not open handlers handler pDefinitions data file:", err)
 		return
 	}
+	for i := range packetBudgets {
+		packetBudgets[i] = pDefinitions.DefaultBudget
+	}
+	for _, limit := range pDefinitions.Limits {
+		packetBudgets[byte(limit.Opcode)] = limit.Budget
+	}
+	// handlers only find their opcode through the packets list, so one missing from it would never run
+	for name := range Handlers {
+		found := false
+		for _, h := range pDefinitions.Set {
+			if h.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Warning.Printf("Handler '%v' has no opcode assigned in %v, and will never be called.\n", name, config.PacketHandlers())
+		}
+	}
 }
 
 //Handler Returns the handlers handler function assigned to this opcode.  If it can't be found, returns nil.
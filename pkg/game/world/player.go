@@ -28,6 +28,9 @@ import (
 	"github.com/spkaeros/rscgo/pkg/errors"
 	"github.com/spkaeros/rscgo/pkg/game/entity"
 	"github.com/spkaeros/rscgo/pkg/game/net"
+	"github.com/spkaeros/rscgo/pkg/game/net/capture"
+	"github.com/spkaeros/rscgo/pkg/game/net/packets"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
 	"github.com/spkaeros/rscgo/pkg/game/social"
 	"github.com/spkaeros/rscgo/pkg/isaac"
 	"github.com/spkaeros/rscgo/pkg/log"
@@ -64,16 +67,30 @@ type (
 		ActionLock        sync.RWMutex
 		ReplyMenuC        chan int8
 		killer            sync.Once
+		saveLock          sync.Mutex
+		lastSave          time.Time
+		// persisted is the last snapshot that got saved, which the next save only has to write the differences from
+		persisted         *PlayerSnapshot
 		Cancel            func()
 		inFrame			  bool
 		hasReader         bool
 		Websocket         bool
 		InQueue, OutQueue chan *net.Packet
+		// floodStrikes counts the ticks that went over packet budgets since floodSince
+		floodStrikes      int
+		floodSince        time.Time
 		Reader            *bufio.Reader
 		webFrame 			  ws.Header
 		Writer            net.WriteFlusher
 		DatabaseIndex     int
 		OpCiphers         [2]*isaac.ISAAC
+		// Revision is the client revision this player logged in with, which its packets get translated to and from
+		Revision          *protocol.Revision
+		captureLock       sync.Mutex
+		capture           *capture.Writer
+		// lingerLock is held to claim a lingering player, which either a reconnecting client or the end of its
+		// reconnect window gets to do, but never both
+		lingerLock        sync.Mutex
 		Mob
 	}
 )
@@ -230,12 +247,13 @@ func (p *Player) DuelBlocked() bool {
 	return p.BoolAttribute("duel_block")
 }
 
+//UpdateStatus Tells everyone that has this player on their friend list, and is allowed to see it, that this player
+// is now online or offline.
 func (p *Player) UpdateStatus(status bool) {
 	Players.Range(func(player *Player) {
-		if player.FriendList.Contains(p.Username()) {
-			if p.FriendList.Contains(player.Username()) || !p.FriendBlocked() {
-				p.WritePacket(FriendUpdate(p.UsernameHash(), status))
-			}
+		if player.FriendList.Contains(p.Username()) && (!p.FriendBlocked() || p.FriendList.Contains(player.Username())) {
+			player.FriendList.Set(p.Username(), status)
+			player.WritePacket(FriendUpdate(p.UsernameHash(), status))
 		}
 	})
 }
@@ -302,16 +320,6 @@ func (p *Player) SetPrivacySettings(chatBlocked, friendBlocked, tradeBlocked, du
 	p.Attributes.SetVar("friend_block", friendBlocked)
 	p.Attributes.SetVar("trade_block", tradeBlocked)
 	p.Attributes.SetVar("duel_block", duelBlocked)
-
-	// p.UpdateStatus(true)
-
-	Players.Range(func(player *Player) {
-		if player.FriendList.Contains(p.Username()) {
-			if !p.FriendList.Contains(player.Username()) || p.FriendBlocked() {
-				p.WritePacket(FriendUpdate(p.UsernameHash(), !p.FriendBlocked() || p.FriendList.Contains(player.Username())))
-			}
-		}
-	})
 }
 
 //SetClientSetting sets the specified client setting to flag.
@@ -418,29 +426,50 @@ func (l Location) Reachable(other entity.Location) bool {
 
 func (p *Player) WriteNow(packet net.Packet) {
 	if packet.Bare {
-		if count, err := p.Writer.Write(packet.FrameBuffer); err != nil || count < packet.Length() {
+		count, err := p.Writer.Write(packet.FrameBuffer)
+		BytesOut.Add(uint64(count))
+		if err != nil || count < packet.Length() {
 			log.Warn("Failed to write raw packet to player socket!")
 		}
 		return
 	}
-	header := []byte{0, 0}
+	if len(packet.FrameBuffer) == 0 {
+		log.Warn("Tried to write a packet with no opcode to", p)
+		return
+	}
+	opcode := packet.FrameBuffer[0]
+	// captures are recorded with the server's opcodes and layouts, but only hold what this client was really sent
+	captured := packet.FrameBuffer
+	if p.Revision != nil {
+		var encoded *net.Packet
+		if opcode, encoded = p.Revision.Encode(&packet); encoded == nil {
+			// this client's revision has no such packet
+			return
+		}
+		packet = *encoded
+	}
+	p.captured(false, captured[0], captured[1:])
 	frameLength := len(packet.FrameBuffer)
+	// the frame buffer may be shared with every other player that this packet is going to, so the translated and
+	// ciphered opcode goes in a copy of the frame instead
+	frame := make([]byte, 2, frameLength+2)
+	frame = append(frame, packet.FrameBuffer...)
+	frame[2] = opcode
 	if cipher := p.OpCiphers[0]; cipher != nil {
-		packet.FrameBuffer[0] = byte(uint32(packet.FrameBuffer[0]) + cipher.Uint32()) & 0xFF
-	} else {
-		log.Debug("nil isaac thingy")
+		frame[2] = byte(uint32(frame[2]) + cipher.Uint32())
 	}
 	if frameLength >= 160 {
-		header[0] = byte(frameLength>>8 + 160)
-		header[1] = byte(frameLength)
+		frame[0] = byte(frameLength>>8 + 160)
+		frame[1] = byte(frameLength)
 	} else {
-		header[0] = byte(frameLength)
-		if frameLength > 0 {
-			frameLength--
-			header[1] = packet.FrameBuffer[frameLength]
-		}
-	}
-	if count, err := p.Writer.Write(append(header, packet.FrameBuffer[:frameLength]...)); err != nil || count < packet.Length()+1 {
+		// short frames carry their last byte in the header
+		frame[0] = byte(frameLength)
+		frame[1] = frame[len(frame)-1]
+		frame = frame[:len(frame)-1]
+	}
+	count, err := p.Writer.Write(frame)
+	BytesOut.Add(uint64(count))
+	if err != nil || count < packet.Length()+1 {
 		log.Warn("Failed to write formatted packet to player socket!")
 	}
 }
@@ -520,6 +549,7 @@ func (p *Player) EquipItem(item *Item) {
 		return true
 	})
 	item.Worn = true
+	p.Inventory.touch()
 	p.IncAimPoints(def.Aim)
 	p.IncPowerPoints(def.Power)
 	p.IncArmourPoints(def.Armour)
@@ -560,6 +590,7 @@ func (p *Player) DequipItem(item *Item) {
 		return
 	}
 	item.Worn = false
+	p.Inventory.touch()
 	p.IncAimPoints(-def.Aim)
 	p.IncPowerPoints(-def.Power)
 	p.IncArmourPoints(-def.Armour)
@@ -686,8 +717,12 @@ type Server interface {
 	SubmitLogin(*Player)
 	SubmitLogout(*Player)
 	DebugTicks()
+	Stop()
 }
 
+//DefaultServer The game server hosting this world.  The server sets this to itself when it starts.
+var DefaultServer Server
+
 func (p *Player) Server() Server {
 	return p.Value("server").(Server)
 }
@@ -895,14 +930,17 @@ func (p *Player) ResetDuelRules() {
 
 //WritePacket sends a net to the client.
 func (p *Player) WritePacket(packet *net.Packet) {
-	if p == nil || (!p.Connected() && !packet.Bare) {
+	if p == nil || (!p.Connected() && !packet.Bare) || p.Lingering() {
 		return
 	}
 	p.OutQueue <- packet
 }
 
 type PlayerService interface {
-	PlayerSave(*Player)
+	PlayerSaveSnapshot(context.Context, *PlayerSnapshot) bool
+	PlayerValidLogin(context.Context, uint64, string) bool
+	PlayerChangePassword(context.Context, uint64, string) bool
+	SaveRecoveryQuestions(context.Context, uint64, []string, []uint64) bool
 }
 
 var DefaultPlayerService PlayerService
@@ -923,9 +961,17 @@ func (p *Player) OpenTradeScreen(target *Player) {
 }
 
 //Destroy sends a kill signal to the underlying client to tear down all of the I/O routines and save the player.
+// Returns false if the player was in the world and could not be saved, otherwise returns true.
 // Note: This should probably be ran in its own goroutine as it saves the player to the database.
-func (p *Player) Destroy() {
-	p.WriteNow(*Logout)
+func (p *Player) Destroy() bool {
+	saved := true
+	lingering := p.Lingering()
+	if !lingering {
+		p.WriteNow(*Logout)
+		if err := p.Writer.Flush(); err != nil {
+			log.Debug("Couldn't flush logout packet:", err)
+		}
+	}
 	p.killer.Do(func() {
 		defer p.Cancel()
 		p.SetConnected(false)
@@ -934,18 +980,20 @@ func (p *Player) Destroy() {
 		close(p.InQueue)
 		close(p.OutQueue)
 		
-		if err := p.Socket.Close(); err != nil {
+		if err := p.Socket.Close(); err != nil && !lingering {
 			log.Warn("Couldn't close socket:", err)
 		}
+		p.StopCapture()
 		if Players.Find(p) > -1 {
 			log.Debug("Unregistered:", p.Username() + "@" + p.CurrentIP())
 			p.ResetAll()
-			go DefaultPlayerService.PlayerSave(p)
 			RemovePlayer(p)
+			saved = p.save(p.Snapshot())
 			return
 		}
 		log.Debug("Unregistered:", p.CurrentIP())
 	})
+	return saved
 }
 
 func (p *Player) AtObject(object *Object) bool {
@@ -1015,6 +1063,14 @@ func (p *Player) CanReachDiag(bounds [2]entity.Location) bool {
 	*/
 }
 
+//RecoveryDays Returns how many days ago this players recovery questions were set, or -1 if they never were.
+func (p *Player) RecoveryDays() int {
+	if _, ok := p.Var("recoveryChanged"); !ok {
+		return -1
+	}
+	return int(time.Since(p.VarTime("recoveryChanged")).Hours() / 24)
+}
+
 //Initialize informs the client of all of the various attributes of this player, and starts the stat normalization
 // routine.
 func (p *Player) Initialize() {
@@ -1022,7 +1078,6 @@ func (p *Player) Initialize() {
 	// defer p.Enqueue(playerEvents, map[string]int {"index": int(p.ServerIndex()), "ticket": int(p.AppearanceTicket())})
 	// defer AddPlayer(p)
 	p.SetConnected(true)
-	p.Attributes.SetVar("lastLogin", time.Now())
 	// p.UpdatedRegions()
 	p.SetVar("authTime", time.Now())
 	p.SetVar("authTick", CurrentTick())
@@ -1039,8 +1094,11 @@ func (p *Player) Initialize() {
 	p.WritePacket(PlaneInfo(p))
 	p.WritePacket(QuestStatus(p))
 	if !p.Reconnecting() {
-		p.WritePacket(LoginBox(int(time.Since(p.Attributes.VarTime("lastLogin")).Hours()/24), p.Attributes.VarString("lastIP", "127.0.0.1")))
+		lastLogin, lastIP := p.PreviousLogin()
+		p.WritePacket(LoginBox(int(time.Since(lastLogin).Hours()/24), lastIP, p.RecoveryDays()))
 	}
+	// only once the welcome box knows when we were last here
+	p.Attributes.SetVar("lastLogin", time.Now())
 
 	p.WritePacket(InventoryItems(p))
 	// This is authentically redundant--probably related to the water-world bug, or some thing...
@@ -1051,8 +1109,8 @@ func (p *Player) Initialize() {
 	p.WritePacket(PrayerStatus(p))
 	p.WritePacket(Fatigue(p))
 	// social panel
-	// p.WritePacket(FriendList(p))
-	// p.WritePacket(IgnoreList(p))
+	p.WritePacket(FriendList(p))
+	p.WritePacket(IgnoreList(p))
 	// TODO: Not canonical RSC, but definitely good QoL update...
 	//  p.WritePacket(FightMode(p))
 
@@ -1063,9 +1121,13 @@ func (p *Player) Initialize() {
 	if !p.Attributes.Contains("madeAvatar") {
 		p.OpenAppearanceChanger()
 	}
-	for _, fn := range LoginTriggers {
-		go fn(p)
+	if !p.VarBool("rebound", false) {
+		for _, fn := range LoginTriggers {
+			go fn(p)
+		}
 	}
+	// the login triggers from before we reconnected are all still running
+	p.UnsetVar("rebound")
 	p.Enqueue(playerEvents, map[string]int {"index": int(p.ServerIndex()), "ticket": int(p.AppearanceTicket())})
 }
 
@@ -1742,17 +1804,14 @@ func (p *Player) Read(data []byte) (n int, err error) {
 
 
 
+//maxPacketLength The longest packet frame that a client may send.  The real client never sends one anywhere near this long.
+const maxPacketLength = 5000
+
 func (p *Player) ReadPacket() (*net.Packet, error) {
 	header := make([]byte, 2)
 	
 	n, err := p.Read(header)
 	if err != nil {
-		switch err.(type) {
-		case errors.NetError:
-			if err.(errors.NetError).Fatal {
-				p.Unregister()
-			}
-		}
 		log.Warn("Error reading packet header:", err)
 		return nil, errors.NewNetworkError("Error reading header for packet:" + err.Error(), true)
 	}
@@ -1765,6 +1824,11 @@ func (p *Player) ReadPacket() (*net.Packet, error) {
 	} else {
 		length -= 1
 	}
+	// checked before anything gets allocated, so that lying about the length can't make us allocate much
+	if length < 0 || length > maxPacketLength || (length == 0 && header[0] >= 160) {
+		log.Suspicious.Println(p, "sent a packet frame with an invalid length of", length)
+		return nil, errors.NewNetworkError("Invalid packet-frame length recv; got " + strconv.Itoa(length), true)
+	}
 
 	var frame = make([]byte, length)
 	if length > 0 {
@@ -1778,28 +1842,54 @@ func (p *Player) ReadPacket() (*net.Packet, error) {
 	if length < 160 {
 		frame = append(frame, header[1])
 	}
-	// if cipher := p.OpCiphers[1]; cipher != nil {
-		// frame[0] = byte(uint32(frame[0]) - cipher.Uint32()) & 0xFF
-	// }
+	// the opcode is deciphered by ProcPacketsIn, if opcodes are being ciphered
 
 	return net.NewPacket(frame[0], frame[1:]), nil
 }
 
 func (p *Player) ProcPacketsIn() {
+	var sent packetCounter
 	for {
 	select {
 	case packet, ok := <-p.InQueue:
 		if packet == nil || !ok {
 			return
 		}
-		// script packet handlers are the most `modern` solution, and will be the default selected for any incoming packet
 		opcode := packet.Opcode
 		if cipher := p.OpCiphers[1]; cipher != nil {
 			opcode = byte(uint32(packet.Opcode) - cipher.Uint32()) & 0xFF
 		}
+		if p.Revision != nil {
+			if opcode, packet = p.Revision.Decode(opcode, packet); packet == nil {
+				log.Debugf("Dropped packet that revision %d has no counterpart for\n", p.Revision.Version)
+				continue
+			}
+		}
+		p.captured(true, opcode, packet.FrameBuffer)
+		PacketsIn.With(strconv.Itoa(int(opcode))).Inc()
+		// over budget packets still had to be deciphered above, or the opcodes after them would come out wrong
+		if !sent.allow(opcode) {
+			continue
+		}
 
-		if handlePacket := PacketTriggers[opcode]; handlePacket != nil {
-			handlePacket(p, packet)
+		// script packet triggers take precedence over the Go handlers, so that any packet's handling can be
+		// replaced without rebuilding the server
+		handlePacket := PacketTriggers[opcode]
+		if handlePacket == nil {
+			handlePacket = Handler(opcode)
+		}
+		if handlePacket != nil {
+			// handlers get the decoded message for any packet with a schema, and the raw packet otherwise
+			message, err := packets.Decode(opcode, packet)
+			if err != nil {
+				log.Suspicious.Printf("%v sent a malformed %v packet: %v\n", p, packetName(opcode), err)
+				continue
+			}
+			if message != nil {
+				handlePacket(p, message)
+			} else {
+				handlePacket(p, packet)
+			}
 			continue
 		}
 
@@ -1808,6 +1898,7 @@ func (p *Player) ProcPacketsIn() {
 	case <-p.Done():
 		return
 	default:
+		p.checkBudgets(&sent)
 		return
 	}
 	}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package world
+
+import (
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/tasks"
+)
+
+//Lingering Returns true if this player lost its connection, and is being kept in the world in case it reconnects.
+func (p *Player) Lingering() bool {
+	return p.VarBool("lingering", false)
+}
+
+//Linger Keeps this player in the world for window after its connection was lost, so that a client can reconnect to it
+// with Rebind.  Once window passes without that happening, the player is logged out like normal.
+func (p *Player) Linger(window time.Duration) {
+	// the socket is already dead, so we don't care whether this errors
+	p.Socket.Close()
+	deadline := time.Now().Add(window)
+	p.lingerLock.Lock()
+	p.SetVar("lingerUntil", deadline)
+	p.SetVar("lingering", true)
+	p.lingerLock.Unlock()
+	log.Debug("Lingering:", p.Username()+"@"+p.CurrentIP(), "for", window)
+	tasks.Schedule(1, func() bool {
+		p.lingerLock.Lock()
+		if !p.Lingering() || !p.VarTime("lingerUntil").Equal(deadline) {
+			// either we reconnected, or we reconnected and dropped again, in which case a newer task has this now
+			p.lingerLock.Unlock()
+			return true
+		}
+		if time.Now().Before(deadline) {
+			p.lingerLock.Unlock()
+			return false
+		}
+		// still lingering as far as logging out goes, but nobody can reconnect to it any more
+		p.UnsetVar("lingerUntil")
+		p.lingerLock.Unlock()
+		// the logout queue can fill up, and it gets emptied by the same tick that runs this
+		go p.Unregister()
+		return true
+	})
+}
+
+//Rebind Moves the connection of conn, a freshly logged in client, over to this lingering player, and forgets about
+// conn.  Everything in view gets sent to the client again on the next tick, as if the player had just logged in.
+// Returns false, leaving both players alone, if this player is not lingering any more, e.g because its reconnect
+// window ran out and it is being logged out.
+// This should only be called during the game engine tick, before incoming packets are handled.
+func (p *Player) Rebind(conn *Player) bool {
+	p.lingerLock.Lock()
+	if _, ok := p.Var("lingerUntil"); !ok || !p.Lingering() {
+		p.lingerLock.Unlock()
+		return false
+	}
+	p.UnsetVar("lingering")
+	p.UnsetVar("lingerUntil")
+	p.lingerLock.Unlock()
+
+	p.Socket = conn.Socket
+	p.Reader = conn.Reader
+	p.Writer = conn.Writer
+	p.Websocket = conn.Websocket
+	p.webFrame = conn.webFrame
+	p.inFrame = conn.inFrame
+	p.hasReader = conn.hasReader
+	p.OpCiphers = conn.OpCiphers
+	p.Revision = conn.Revision
+	p.SetReconnecting(conn.Reconnecting())
+	conn.Cancel()
+
+	// anything still queued was encrypted with, or meant for, the old connection's ciphers
+	for drained := false; !drained; {
+		select {
+		case <-p.InQueue:
+		case <-p.OutQueue:
+		default:
+			drained = true
+		}
+	}
+	// forget everything we told the old client about, so that it all gets sent again
+	p.LocalPlayers = NewMobList()
+	p.LocalNPCs = NewMobList()
+	p.LocalObjects = &entityList{}
+	p.LocalItems = &entityList{}
+	p.KnownAppearances = make(map[int]int)
+	p.UnsetVar("lastPlane")
+	p.SetVar("rebound", true)
+	// Initialize will send the client everything it needs on the next tick
+	p.SetConnected(false)
+	log.Debug("Reconnected:", p.Username()+"@"+p.CurrentIP())
+	return true
+}
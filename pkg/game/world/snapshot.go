@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package world
+
+import (
+	"time"
+
+	"github.com/spkaeros/rscgo/pkg/game/entity"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//SavedItem An item as it gets persisted in an inventory or bank.
+type SavedItem struct {
+	ID     int
+	Amount int
+	Worn   bool
+}
+
+//PlayerSnapshot A copy of everything about a player that gets persisted, taken at a single point in time.
+// Nothing in here is shared with the player it was taken from, so it is safe to read from any goroutine.
+type PlayerSnapshot struct {
+	Taken         time.Time
+	DatabaseIndex int
+	Username      string
+	UsernameHash  uint64
+	// Online is true when the player is still in the game world, e.g for autosaves.
+	Online        bool
+	X, Y          int
+	Appearance    entity.AppearanceTable
+	Attributes    map[string]interface{}
+	Friends       []uint64
+	Ignores       []uint64
+	// Stats holds the current level and experience for each skill, in that order
+	Stats         [18][2]int
+	Inventory     []SavedItem
+	Bank          []SavedItem
+	// The change counts of the players attributes, friends, inventory and bank when this was taken.  A save can skip
+	// any of them that still match Previous.
+	AttributeChanges int
+	FriendChanges    int
+	InventoryChanges int
+	BankChanges      int
+	// Previous is the last snapshot of this player to be persisted during this session, if any.  Services can save
+	// only what differs from it, rather than rewriting everything.
+	Previous      *PlayerSnapshot
+}
+
+//Snapshot Copies the persisted state of this player.  To get a consistent copy, this should be called from the
+// game engine tick, as that is where the player gets mutated.
+func (p *Player) Snapshot() *PlayerSnapshot {
+	s := &PlayerSnapshot{
+		Taken:         time.Now(),
+		DatabaseIndex: p.DatabaseIndex,
+		Username:      p.Username(),
+		UsernameHash:  p.UsernameHash(),
+		Online:        p.Connected(),
+		X:             p.X(),
+		Y:             p.Y(),
+		Appearance:    p.Appearance,
+		Attributes:    make(map[string]interface{}),
+		Ignores:       append([]uint64{}, p.IgnoreList...),
+		// the change counts have to be read before anything gets copied; if something changes in between, the next
+		// save sees a new count and writes it, rather than thinking it was already saved
+		AttributeChanges: p.Attributes.Changes(),
+		FriendChanges:    p.FriendList.Changes(),
+		InventoryChanges: p.Inventory.Changes(),
+		BankChanges:      p.Bank().Changes(),
+	}
+	p.Attributes.ForEach(func(name string, value interface{}) {
+		s.Attributes[name] = value
+	})
+	p.FriendList.ForEach(func(name string, online bool) bool {
+		s.Friends = append(s.Friends, strutil.Base37.Encode(name))
+		return false
+	})
+	for i := range s.Stats {
+		s.Stats[i][0] = p.Skills().Current(i)
+		s.Stats[i][1] = p.Skills().Experience(i)
+	}
+	saveItems := func(items *Inventory) (saved []SavedItem) {
+		items.Range(func(item *Item) bool {
+			saved = append(saved, SavedItem{item.ID, item.Amount, item.Worn})
+			return true
+		})
+		return
+	}
+	s.Inventory = saveItems(p.Inventory)
+	s.Bank = saveItems(p.Bank())
+	return s
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package world
+
+import (
+	stdnet "net"
+
+	"github.com/spkaeros/rscgo/pkg/game/net/handshake"
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//RemoveThrottle Forgets every failed attempt from target, and lifts any lockout on it.  target is either an IP
+// address, which clears its login, registration and recovery throttles, or a username, which clears its account and
+// account recovery throttles.
+// Returns true if there was anything to lift.
+func RemoveThrottle(moderator, target string) bool {
+	removed := false
+	if ip := stdnet.ParseIP(target); ip != nil {
+		removed = handshake.LoginThrottle.Reset(ip.String())
+		removed = handshake.RegisterThrottle.Reset(ip.String()) || removed
+		removed = handshake.RecoveryThrottle.Reset(ip.String()) || removed
+	} else {
+		account := handshake.AccountKey(strutil.Base37.Encode(target))
+		removed = handshake.AccountThrottle.Reset(account)
+		removed = handshake.RecoveryAccountThrottle.Reset(account) || removed
+	}
+	if removed {
+		log.Command(moderator, "lifted throttles on", target)
+	}
+	return removed
+}
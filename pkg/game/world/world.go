@@ -277,16 +277,7 @@ func WithinWorld(x, y int) bool {
 func AddPlayer(p *Player) {
 	Players.Put(p)
 	Region(p.X(), p.Y()).Players.Add(p)
-	// Players.Range(func(player *Player) {
-		// if player.FriendList.Contains(p.Username()) && (!p.FriendBlocked() || p.FriendList.Contains(player.Username())) {
-			// player.FriendList.Set(p.Username(), true)
-			// player.WritePacket(FriendUpdate(p.UsernameHash(), true))
-		// }
-// 
-		// //		if player.FriendList.Contains(p.Username()) {
-		// //			player.WritePacket(FriendUpdate(p.UsernameHash(), p.FriendList.Contains(player.Username()) || !p.FriendBlocked()))
-		// //		}
-	// })
+	p.UpdateStatus(true)
 }
 
 //RemovePlayer Remove a player from the game world.
@@ -294,12 +285,7 @@ func RemovePlayer(p *Player) {
 	p.SetRegionRemoved()
 	Region(p.X(), p.Y()).Players.Remove(p)
 	Players.Remove(p)
-	// Players.Range(func(player *Player) {
-		// if player.FriendList.Contains(p.Username()) && (!p.FriendBlocked() || p.FriendList.Contains(player.Username())) {
-			// player.FriendList.Set(p.Username(), false)
-			// player.WritePacket(FriendUpdate(p.UsernameHash(), false))
-		// }
-	// })
+	p.UpdateStatus(false)
 }
 
 //AddNpc Add a NPC to the region.
@@ -0,0 +1,303 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+//Package metrics is a small set of counters, gauges and histograms that can be scraped by Prometheus, in its text
+// exposition format.  Every metric made by this package is registered with it as soon as it is made, and gets written
+// out by Handler, in the order they were made.
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/spkaeros/rscgo/pkg/log"
+)
+
+//metric Anything that can write itself out in the Prometheus text format.
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+var (
+	lock       sync.RWMutex
+	registered []metric
+)
+
+func register(m metric) {
+	lock.Lock()
+	defer lock.Unlock()
+	registered = append(registered, m)
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	w.WriteString("# HELP " + name + " " + strings.Replace(strings.Replace(help, `\`, `\\`, -1), "\n", `\n`, -1) + "\n")
+	w.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+//label Formats a single label pair, escaped as the text format wants it.
+func label(name, value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	value = strings.Replace(value, "\n", `\n`, -1)
+	return name + `="` + value + `"`
+}
+
+//Counter A number that only ever goes up.
+type Counter struct {
+	name, help string
+	value      *atomic.Uint64
+}
+
+//NewCounter Returns a new registered counter.
+func NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help, value: atomic.NewUint64(0)}
+	register(c)
+	return c
+}
+
+//Inc Adds one to this counter.
+func (c *Counter) Inc() {
+	c.value.Inc()
+}
+
+//Add Adds n to this counter.
+func (c *Counter) Add(n uint64) {
+	c.value.Add(n)
+}
+
+//Value Returns the current count.
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	writeSample(w, c.name, "", float64(c.Value()))
+}
+
+//CounterVec A set of counters that share a name, told apart by the value of a single label.
+type CounterVec struct {
+	name, help, label string
+	counters          sync.Map
+}
+
+//NewCounterVec Returns a new registered set of counters, labelled by label.
+func NewCounterVec(name, help, label string) *CounterVec {
+	c := &CounterVec{name: name, help: help, label: label}
+	register(c)
+	return c
+}
+
+//With Returns the counter for the provided label value, making it if it does not exist yet.
+func (c *CounterVec) With(value string) *Counter {
+	if counter, ok := c.counters.Load(value); ok {
+		return counter.(*Counter)
+	}
+	counter, _ := c.counters.LoadOrStore(value, &Counter{name: c.name, value: atomic.NewUint64(0)})
+	return counter.(*Counter)
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	for _, value := range sortedKeys(&c.counters) {
+		counter, _ := c.counters.Load(value)
+		writeSample(w, c.name, label(c.label, value), float64(counter.(*Counter).Value()))
+	}
+}
+
+func sortedKeys(m *sync.Map) (keys []string) {
+	m.Range(func(key, _ interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool {
+		// numeric labels, e.g opcodes, read better in numeric order
+		a, errA := strconv.Atoi(keys[i])
+		b, errB := strconv.Atoi(keys[j])
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+	return
+}
+
+//funcMetric A metric that gets its value from a function each time it is scraped, for things that are already
+// counted somewhere else, such as the number of players in the world.
+type funcMetric struct {
+	name, help, kind string
+	value            func() float64
+}
+
+//NewGaugeFunc Registers a gauge, a number that can go up and down, which is read by calling value.
+func NewGaugeFunc(name, help string, value func() float64) {
+	register(&funcMetric{name, help, "gauge", value})
+}
+
+//NewCounterFunc Registers a counter which is read by calling value.  value should never go down.
+func NewCounterFunc(name, help string, value func() float64) {
+	register(&funcMetric{name, help, "counter", value})
+}
+
+func (m *funcMetric) write(w *bufio.Writer) {
+	writeHeader(w, m.name, m.help, m.kind)
+	writeSample(w, m.name, "", m.value())
+}
+
+//Histogram Counts observations into buckets, along with their sum, so that both their distribution and mean can be
+// charted.
+type Histogram struct {
+	name, help string
+	labels     string
+	buckets    []float64
+	// counts has one more entry than buckets, for observations that are bigger than every bucket
+	counts []*atomic.Uint64
+	sum    *atomic.Float64
+}
+
+//NewHistogram Returns a new registered histogram.  buckets are the upper bounds of each bucket, in ascending order.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := newHistogram(name, help, "", buckets)
+	register(h)
+	return h
+}
+
+func newHistogram(name, help, labels string, buckets []float64) *Histogram {
+	h := &Histogram{name: name, help: help, labels: labels, buckets: buckets, sum: atomic.NewFloat64(0)}
+	h.counts = make([]*atomic.Uint64, len(buckets)+1)
+	for i := range h.counts {
+		h.counts[i] = atomic.NewUint64(0)
+	}
+	return h
+}
+
+//Observe Adds v to this histogram.
+func (h *Histogram) Observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.buckets, v)].Inc()
+	h.sum.Add(v)
+}
+
+//ObserveDuration Adds d to this histogram, in seconds.
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+//Since Adds the time since start to this histogram, and returns the current time, so that back to back phases can be
+// timed like:
+//
+//	start = a.Since(start)
+//	start = b.Since(start)
+func (h *Histogram) Since(start time.Time) time.Time {
+	now := time.Now()
+	h.ObserveDuration(now.Sub(start))
+	return now
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.writeSamples(w)
+}
+
+func (h *Histogram) writeSamples(w *bufio.Writer) {
+	prefix := h.labels
+	if len(prefix) > 0 {
+		prefix += ","
+	}
+	total := uint64(0)
+	for i, bound := range h.buckets {
+		total += h.counts[i].Load()
+		writeSample(w, h.name+"_bucket", prefix+label("le", formatFloat(bound)), float64(total))
+	}
+	total += h.counts[len(h.buckets)].Load()
+	writeSample(w, h.name+"_bucket", prefix+label("le", "+Inf"), float64(total))
+	writeSample(w, h.name+"_sum", h.labels, h.sum.Load())
+	writeSample(w, h.name+"_count", h.labels, float64(total))
+}
+
+//HistogramVec A set of histograms that share a name and buckets, told apart by the value of a single label.
+type HistogramVec struct {
+	name, help, label string
+	buckets           []float64
+	histograms        sync.Map
+}
+
+//NewHistogramVec Returns a new registered set of histograms, labelled by label.
+func NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, label: label, buckets: buckets}
+	register(h)
+	return h
+}
+
+//With Returns the histogram for the provided label value, making it if it does not exist yet.
+func (h *HistogramVec) With(value string) *Histogram {
+	if histogram, ok := h.histograms.Load(value); ok {
+		return histogram.(*Histogram)
+	}
+	histogram, _ := h.histograms.LoadOrStore(value, newHistogram(h.name, "", label(h.label, value), h.buckets))
+	return histogram.(*Histogram)
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, value := range sortedKeys(&h.histograms) {
+		histogram, _ := h.histograms.Load(value)
+		histogram.(*Histogram).writeSamples(w)
+	}
+}
+
+//WriteTo Writes every registered metric to out, in the Prometheus text format.
+func WriteTo(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	lock.RLock()
+	for _, m := range registered {
+		m.write(w)
+	}
+	lock.RUnlock()
+	return w.Flush()
+}
+
+//Handler Serves every registered metric to HTTP requests, in the Prometheus text format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := WriteTo(w); err != nil {
+			log.Warn("Error writing metrics to scraper:", err)
+		}
+	})
+}
+
+//Serve Binds to addr and serves every registered metric at /metrics.
+// Note: This is a blocking call, it will not return to caller unless the listener fails.
+func Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Warn("Could not bind to metrics address "+addr+":", err)
+	}
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spkaeros/rscgo/pkg/game/net/protocol"
+)
+
+//field One field of a packet schema, as it is written in packets.toml.
+type field struct {
+	Name    string      `toml:"name"`
+	Type    string      `toml:"type"`
+	Count   interface{} `toml:"count"`
+	Length  interface{} `toml:"length"`
+	Element string      `toml:"element"`
+	Fields  []field     `toml:"fields"`
+}
+
+type schema struct {
+	Like   string  `toml:"like"`
+	Fields []field `toml:"fields"`
+}
+
+//goTypes The Go type that each scalar field type decodes into.
+var goTypes = map[string]string{
+	"bool":    "bool",
+	"uint8":   "int",
+	"int8":    "int",
+	"uint16":  "int",
+	"uint32":  "int",
+	"smart":   "int",
+	"uint64":  "uint64",
+	"string":  "string",
+	"stringN": "string",
+	"bytes":   "[]byte",
+}
+
+//encoders How each scalar field type gets written back to a packet; %s is the value.
+var encoders = map[string]string{
+	"bool":    "p.AddBoolean(%s)",
+	"uint8":   "p.AddUint8(uint8(%s))",
+	"int8":    "p.AddInt8(int8(%s))",
+	"uint16":  "p.AddUint16(uint16(%s))",
+	"uint32":  "p.AddUint32(uint32(%s))",
+	"smart":   "p.AddSmart0816(%s)",
+	"uint64":  "p.AddUint64(%s)",
+	"string":  "p.AddBytes([]byte(%s)).AddUint8(0)",
+	"stringN": "p.AddBytes([]byte(%s))",
+	"bytes":   "p.AddBytes(%s)",
+}
+
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+//generator Writes the message types for every schema into one Go source file.
+type generator struct {
+	types, code bytes.Buffer
+}
+
+func (g *generator) typef(format string, args ...interface{}) {
+	fmt.Fprintf(&g.types, format, args...)
+}
+
+func (g *generator) codef(format string, args ...interface{}) {
+	fmt.Fprintf(&g.code, format, args...)
+}
+
+//count Returns the Go expression for how many times f repeats within the struct held in v, or an empty string if
+// it repeats until the packet runs out.
+func count(f field, v string) (string, error) {
+	switch c := f.Count.(type) {
+	case int64:
+		return strconv.FormatInt(c, 10), nil
+	case string:
+		if c == "rest" {
+			return "", nil
+		}
+		return v + "." + exported(c), nil
+	}
+	return "", fmt.Errorf("field %v has an invalid count: %v", f.Name, f.Count)
+}
+
+//length Returns the Go expression for how many bytes long f is within the struct held in v, or -1 if it has no length.
+func length(f field, v string) string {
+	switch l := f.Length.(type) {
+	case int64:
+		return strconv.FormatInt(l, 10)
+	case string:
+		return v + "." + exported(l)
+	}
+	return "-1"
+}
+
+//checkFields Makes sure that every field has a known type and what that type needs.
+func checkFields(fields []field) error {
+	for _, f := range fields {
+		switch {
+		case f.Type == "skip":
+			if _, ok := f.Length.(int64); !ok {
+				return fmt.Errorf("skip fields need a numeric length")
+			}
+			continue
+		case f.Name == "":
+			return fmt.Errorf("a %v field has no name", f.Type)
+		case f.Type == "group":
+			if f.Element == "" || len(f.Fields) == 0 {
+				return fmt.Errorf("group %v needs an element name and fields", f.Name)
+			}
+			if err := checkFields(f.Fields); err != nil {
+				return err
+			}
+		case goTypes[f.Type] == "":
+			return fmt.Errorf("field %v has unknown type %v", f.Name, f.Type)
+		case f.Type == "stringN" && f.Length == nil:
+			return fmt.Errorf("stringN field %v needs a length", f.Name)
+		}
+		if f.Count != nil {
+			if _, err := count(f, "m"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//structType Declares the struct type name for fields, and every group element type within them.
+func (g *generator) structType(name, doc string, fields []field) {
+	var elements []field
+	g.typef("//%s %s\ntype %s struct {\n", name, doc, name)
+	for _, f := range fields {
+		switch {
+		case f.Type == "skip":
+			continue
+		case f.Type == "group":
+			g.typef("%s []%s\n", exported(f.Name), name+exported(f.Element))
+			elements = append(elements, f)
+		case f.Count != nil:
+			g.typef("%s []%s\n", exported(f.Name), goTypes[f.Type])
+		default:
+			g.typef("%s %s\n", exported(f.Name), goTypes[f.Type])
+		}
+	}
+	g.typef("}\n\n")
+	for _, f := range elements {
+		g.structType(name+exported(f.Element), "One of the elements of "+name+"."+exported(f.Name)+".", f.Fields)
+	}
+}
+
+//decodeFields Writes the statements that read fields into the struct held in v, whose type is name.
+func (g *generator) decodeFields(name, v string, depth int, fields []field) {
+	for _, f := range fields {
+		target := v + "." + exported(f.Name)
+		read := func() string {
+			switch f.Type {
+			case "stringN", "bytes":
+				return "r." + f.Type + "(" + length(f, v) + ")"
+			}
+			return "r." + f.Type + "()"
+		}
+		if f.Type == "skip" {
+			g.codef("r.take(%s)\n", length(f, v))
+			continue
+		}
+		if f.Count == nil {
+			g.codef("%s = %s\n", target, read())
+			continue
+		}
+		n, _ := count(f, v)
+		if n == "" {
+			g.codef("for r.more() {\n")
+		} else {
+			g.codef("for i%d := 0; i%d < %s && r.err == nil; i%d++ {\n", depth, depth, n, depth)
+		}
+		if f.Type == "group" {
+			e := "e" + strconv.Itoa(depth)
+			g.codef("var %s %s\n", e, name+exported(f.Element))
+			g.decodeFields(name+exported(f.Element), e, depth+1, f.Fields)
+			g.codef("%s = append(%s, %s)\n}\n", target, target, e)
+		} else {
+			g.codef("%s = append(%s, %s)\n}\n", target, target, read())
+		}
+	}
+}
+
+//encodeFields Writes the statements that add the fields of the struct held in v to the packet p.  Counts and lengths
+// that come from other fields are set from the data that they describe first, so that they can not disagree.
+func (g *generator) encodeFields(name, v string, depth int, fields []field) {
+	for _, f := range fields {
+		target := v + "." + exported(f.Name)
+		if c, ok := f.Count.(string); ok && c != "rest" {
+			g.codef("%s.%s = len(%s)\n", v, exported(c), target)
+		}
+		if l, ok := f.Length.(string); ok {
+			g.codef("%s.%s = len(%s)\n", v, exported(l), target)
+		}
+	}
+	for _, f := range fields {
+		target := v + "." + exported(f.Name)
+		switch {
+		case f.Type == "skip":
+			g.codef("p.AddBytes(make([]byte, %s))\n", length(f, v))
+		case f.Type == "group":
+			e := "e" + strconv.Itoa(depth)
+			g.codef("for _, %s := range %s {\n", e, target)
+			g.encodeFields(name+exported(f.Element), e, depth+1, f.Fields)
+			g.codef("}\n")
+		case f.Count != nil:
+			e := "e" + strconv.Itoa(depth)
+			g.codef("for _, %s := range %s {\n", e, target)
+			g.codef(encoders[f.Type]+"\n}\n", e)
+		default:
+			g.codef(encoders[f.Type]+"\n", target)
+		}
+	}
+}
+
+func (g *generator) message(name string, s schema) {
+	typeName := exported(name)
+	g.structType(typeName, "The fields of the "+name+" packet.", s.Fields)
+	g.codef("//Decode Reads the fields of the %s packet out of p.\n", name)
+	g.codef("func (m *%s) Decode(p *net.Packet) error {\nr := &reader{Packet: p}\n", typeName)
+	g.decodeFields(typeName, "m", 0, s.Fields)
+	g.codef("return r.err\n}\n\n")
+	g.codef("//Encode Builds the %s packet out of the fields.\n", name)
+	g.codef("func (m *%s) Encode() *net.Packet {\np := net.NewEmptyPacket(protocol.Inbound[%q])\n", typeName, name)
+	g.encodeFields(typeName, "m", 0, s.Fields)
+	g.codef("return p\n}\n\n")
+}
+
+func main() {
+	in := flag.String("in", "data/packets.toml", "the packets file holding the schemas")
+	out := flag.String("out", "pkg/game/net/packets/schemas.go", "the Go file to write the message types to")
+	flag.Parse()
+
+	var file struct {
+		Schemas map[string]schema `toml:"schemas"`
+	}
+	if _, err := toml.DecodeFile(*in, &file); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not read schemas:", err)
+		os.Exit(1)
+	}
+	var names []string
+	for name, s := range file.Schemas {
+		if _, ok := protocol.Inbound[name]; !ok {
+			fmt.Fprintln(os.Stderr, "Schema for unknown packet:", name)
+			os.Exit(1)
+		}
+		if s.Like != "" {
+			like, ok := file.Schemas[s.Like]
+			if !ok || like.Like != "" {
+				fmt.Fprintln(os.Stderr, "Schema", name, "is like", s.Like, "which does not have fields of its own")
+				os.Exit(1)
+			}
+			s.Fields = like.Fields
+			file.Schemas[name] = s
+		}
+		if err := checkFields(s.Fields); err != nil {
+			fmt.Fprintln(os.Stderr, "Bad schema for", name+":", err)
+			os.Exit(1)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g := &generator{}
+	for _, name := range names {
+		g.message(name, file.Schemas[name])
+	}
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by pkg/packetgen.go from %s; DO NOT EDIT.\n\npackage packets\n\n", strings.TrimLeft(*in, "./"))
+	fmt.Fprintf(&src, "import (\n\"github.com/spkaeros/rscgo/pkg/game/net\"\n\"github.com/spkaeros/rscgo/pkg/game/net/protocol\"\n)\n\n")
+	src.Write(g.types.Bytes())
+	src.Write(g.code.Bytes())
+	src.WriteString("func init() {\n")
+	for _, name := range names {
+		fmt.Fprintf(&src, "register(%q, func() Message { return &%s{} })\n", name, exported(name))
+	}
+	src.WriteString("}\n")
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Generated invalid Go:", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(*out, formatted, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not write", *out+":", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+//Package proxy works out where clients that connect through a trusted reverse proxy really are, from the PROXY
+// protocol header or X-Forwarded-For header that the proxy sends along with them.
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	stdnet "net"
+	"strconv"
+	"strings"
+
+	"github.com/spkaeros/rscgo/pkg/config"
+)
+
+//v1Prefix What a version 1 (text) PROXY protocol header starts with.
+var v1Prefix = []byte("PROXY ")
+
+//v2Signature What a version 2 (binary) PROXY protocol header starts with.
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+//v1MaxLength The longest that a version 1 header can be, including its CRLF.
+const v1MaxLength = 107
+
+//ErrMalformed Returned when a PROXY protocol header can not be understood.
+var ErrMalformed = errors.New("malformed PROXY protocol header")
+
+//Trusted Returns true if proxy support is enabled and the host part of addr is one of the trusted proxies.
+// addr may be a bare IP address, or a host:port pair.
+func Trusted(addr string) bool {
+	if !config.ProxyEnabled() {
+		return false
+	}
+	if host, _, err := stdnet.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return trustedIP(stdnet.ParseIP(addr))
+}
+
+func trustedIP(ip stdnet.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, entry := range config.ProxyTrusted() {
+		if _, network, err := stdnet.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if ip.Equal(stdnet.ParseIP(entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+//hasPrefix Returns true if the next bytes in r are prefix, without consuming them.  Bytes are peeked one at a time so
+// that a short packet from a client with no header doesn't have to be waited on past its first mismatch.
+func hasPrefix(r *bufio.Reader, prefix []byte) (bool, error) {
+	for i := range prefix {
+		peeked, err := r.Peek(i + 1)
+		if err != nil {
+			return false, err
+		}
+		if peeked[i] != prefix[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+//ReadHeader Reads a PROXY protocol header, version 1 or 2, from the start of r if there is one, and returns the
+// client address that it gives.  Returns a nil address if there is no header, or if the header does not name a client,
+// e.g a proxy health check; the connection should then be treated as coming from the proxy itself.
+func ReadHeader(r *bufio.Reader) (stdnet.Addr, error) {
+	if ok, err := hasPrefix(r, v1Prefix); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		return readV1(r)
+	}
+	if ok, err := hasPrefix(r, v2Signature); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		return readV2(r)
+	}
+	return nil, nil
+}
+
+//readV1 Reads a text header:
+//	PROXY TCP4|TCP6 source destination sourcePort destinationPort\r\n
+// or PROXY UNKNOWN followed by anything up to the CRLF.
+func readV1(r *bufio.Reader) (stdnet.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= v1MaxLength {
+			return nil, ErrMalformed
+		}
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, c)
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrMalformed
+	}
+	ip := stdnet.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, ErrMalformed
+	}
+	return &stdnet.TCPAddr{IP: ip, Port: port}, nil
+}
+
+//readV2 Reads a binary header:
+//	12 byte signature
+//	byte version (high 4 bits, always 2) and command (low 4 bits, 0 for LOCAL or 1 for PROXY)
+//	byte address family (high 4 bits, 1 for IPv4 or 2 for IPv6) and protocol (low 4 bits)
+//	uint16 length of the rest of the header
+//	source address, destination address, source port, destination port, then any TLVs, which are skipped
+func readV2(r *bufio.Reader) (stdnet.Addr, error) {
+	header := make([]byte, len(v2Signature)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	versionCommand, family := header[12], header[13]
+	if versionCommand>>4 != 2 {
+		return nil, ErrMalformed
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	if versionCommand&0xF == 0 {
+		// LOCAL; the proxy is talking for itself
+		return nil, nil
+	}
+	if versionCommand&0xF != 1 {
+		return nil, ErrMalformed
+	}
+	switch family >> 4 {
+	case 1:
+		if len(body) < 12 {
+			return nil, ErrMalformed
+		}
+		return &stdnet.TCPAddr{IP: stdnet.IP(body[:4]), Port: int(binary.BigEndian.Uint16(body[8:]))}, nil
+	case 2:
+		if len(body) < 36 {
+			return nil, ErrMalformed
+		}
+		return &stdnet.TCPAddr{IP: stdnet.IP(body[:16]), Port: int(binary.BigEndian.Uint16(body[32:]))}, nil
+	default:
+		// AF_UNSPEC or unix sockets, neither of which says anything useful about the client
+		return nil, nil
+	}
+}
+
+//ForwardedFor Returns the client address from the value of an X-Forwarded-For header sent by a trusted proxy, or nil
+// if it does not have one.  Each proxy appends the address it got the request from, so this is the rightmost address
+// that is not itself a trusted proxy; anything left of that could have been made up by the client.
+func ForwardedFor(header string) stdnet.IP {
+	var last stdnet.IP
+	hops := strings.Split(header, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if host, _, err := stdnet.SplitHostPort(hop); err == nil {
+			hop = host
+		}
+		ip := stdnet.ParseIP(strings.Trim(hop, "[]"))
+		if ip == nil {
+			break
+		}
+		last = ip
+		if !trustedIP(ip) {
+			break
+		}
+	}
+	return last
+}
@@ -14,6 +14,11 @@ type RSA struct {
 var RsaKeyPair = &RSA{new(big.Int),new(big.Int),new(big.Int)}
 
 func init() {
+	LoadKeyPair()
+}
+
+//LoadKeyPair (Re)loads RsaKeyPair from the key files in ./data/rsa/, relative to the working directory.
+func LoadKeyPair() {
 	file,err := os.OpenFile("./data/rsa/mod.der",os.O_RDONLY, 0644)
 	if err != nil {
 		log.Debug("Error: ./data/mod.der not found, can not find RSA key data")
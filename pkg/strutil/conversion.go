@@ -223,16 +223,29 @@ var BaseConversion struct {
 	String func(int, uint64) string
 }
 
+//chatCharset The runes that chat messages packed with ChatFilter.Pack are made from, in order of their codes.
+// Presumably this charset is optimized to be in order of most-used in the English language, as I think I've
+// encountered this character array before elsewhere and that was its stated design
+var chatCharset = []rune{
+	' ', 'e', 't', 'a', 'o', 'i', 'h', 'n', 's', 'r', 'd', 'l', 'u', 'm', 'w',
+	'c', 'y', 'f', 'g', 'p', 'b', 'v', 'k', 'x', 'j', 'q', 'z', '0', '1', '2',
+	'3', '4', '5', '6', '7', '8', '9', ' ', '!', '?', '.', ',', ':', ';', '(',
+	')', '-', '&', '*', '\\', '\'', '@', '#', '+', '=', '\243', '$', '%', '"',
+	'[', ']'}
+
+//chatCharCode Returns the code of c in chatCharset, or the code for a space if it is not in there.
+func chatCharCode(c rune) int {
+	for i, cs := range chatCharset {
+		if cs == c {
+			return i
+		}
+	}
+	return 0
+}
+
 func init() {
-	// Presumably this charset is optimized to be in order of most-used in the English language, as I think I've encountered this character array before elsewhere and that was its stated design
 	validChar := func(c byte) bool {
-		charset := []rune{
-				' ', 'e', 't', 'a', 'o', 'i', 'h', 'n', 's', 'r', 'd', 'l', 'u', 'm', 'w',
-				'c', 'y', 'f', 'g', 'p', 'b', 'v', 'k', 'x', 'j', 'q', 'z', '0', '1', '2',
-				'3', '4', '5', '6', '7', '8', '9', ' ', '!', '?', '.', ',', ':', ';', '(',
-				')', '-', '&', '*', '\\', '\'', '@', '#', '+', '=', '\243', '$', '%', '"',
-				'[', ']' }
-		for _, cs := range charset {
+		for _, cs := range chatCharset {
 			if cs == rune(c) {
 				return true
 			}
@@ -248,73 +261,62 @@ func init() {
 		}
 		return false
 	}
-	// ChatFilter.Pack = func(msg string) []byte {
-		// var buf []byte
-		// if len(msg) > 80 {
-			// msg = msg[:80]
-		// }
-		// msg = strings.ToLower(msg)
-		// cachedValue := -1
-		// for _, c := range msg {
-			// code := getCharCode(c)
-			// if code > 12 {
-				// code += 195
-			// }
-			// if cachedValue == -1 {
-				// if code < 13 {
-					// cachedValue = code
-				// } else {
-					// buf = append(buf, byte(code))
-				// }
-			// } else if code < 13 {
-				// buf = append(buf, byte((cachedValue<<4)|code)) // little end
-				// cachedValue = -1
-			// } else {
-				// buf = append(buf, byte((cachedValue<<4)|(code>>4)))
-				// cachedValue = code & 0xF // big end
-			// }
-		// }
-		// if cachedValue != -1 {
-			// buf = append(buf, byte(cachedValue<<4))
-		// }
-// 
-		// return buf
-	// }
-	// ChatFilter.Unpack = func(data []byte) string {
-		// // deprecated
-		// var buf []rune
-		// dataOffset := 0
-		// cachedValue := -1
-		// for i1 := 0; i1 < len(data); i1++ {
-			// nextChar := data[dataOffset] & 0xFF
-			// dataOffset++
-// 
-			// upperHalf := nextChar & 0xF      // Mask out first half of byte
-			// lowerHalf := nextChar >> 4 & 0xF // mask out last half of byte
-			// if cachedValue == -1 {
-				// if lowerHalf < 13 {
-					// buf = append(buf, charset[lowerHalf])
-				// } else {
-					// cachedValue = int(lowerHalf)
-				// }
-			// } else {
-				// buf = append(buf, charset[byte(cachedValue<<4)|lowerHalf-195])
-				// cachedValue = -1
-			// }
-// 
-			// if cachedValue == -1 {
-				// if upperHalf < 13 {
-					// buf = append(buf, charset[upperHalf])
-				// } else {
-					// cachedValue = int(upperHalf)
-				// }
-			// } else {
-				// buf = append(buf, charset[byte(cachedValue<<4)|upperHalf-195])
-				// cachedValue = -1
-			// }
-		// }
-		// return string(buf)
-	// }
+	ChatFilter.Pack = func(msg string) []byte {
+		var buf []byte
+		if len(msg) > 80 {
+			msg = msg[:80]
+		}
+		msg = strings.ToLower(msg)
+		cachedValue := -1
+		for _, c := range msg {
+			code := chatCharCode(c)
+			if code > 12 {
+				code += 195
+			}
+			if cachedValue == -1 {
+				if code < 13 {
+					cachedValue = code
+				} else {
+					buf = append(buf, byte(code))
+				}
+			} else if code < 13 {
+				buf = append(buf, byte((cachedValue<<4)|code)) // little end
+				cachedValue = -1
+			} else {
+				buf = append(buf, byte((cachedValue<<4)|(code>>4)))
+				cachedValue = code & 0xF // big end
+			}
+		}
+		if cachedValue != -1 {
+			buf = append(buf, byte(cachedValue<<4))
+		}
+
+		return buf
+	}
+	ChatFilter.Unpack = func(data []byte) string {
+		var buf []rune
+		cachedValue := -1
+		unpack := func(nibble int) {
+			if cachedValue == -1 {
+				if nibble < 13 {
+					buf = append(buf, chatCharset[nibble])
+				} else {
+					cachedValue = nibble
+				}
+				return
+			}
+			if code := (cachedValue<<4 | nibble) - 195; code < len(chatCharset) {
+				buf = append(buf, chatCharset[code])
+			}
+			cachedValue = -1
+		}
+		for _, b := range data {
+			unpack(int(b >> 4))
+			unpack(int(b & 0xF))
+		}
+		// odd length messages get padded out with a space
+		return strings.TrimRight(string(buf), " ")
+	}
 	ChatFilter.Format = func(msg string) string {
 		builder := &strings.Builder{}
 		startingSentence := true
@@ -528,6 +530,13 @@ func convertMsg(txt string) []rune {
 }
 
 func Decipher(msg []byte, decipheredLength int) string {
+	s, _ := DecipherLength(msg, decipheredLength)
+	return s
+}
+
+//DecipherLength Works like Decipher, but also returns how many bytes of msg the message took up, so whatever was
+// written after it can be found.
+func DecipherLength(msg []byte, decipheredLength int) (string, int) {
 	bufferIndex := 0
 	off := 0
 	decipherIndex := 0
@@ -583,7 +592,7 @@ func Decipher(msg []byte, decipheredLength int) string {
 			s[bufferIndex] = byte(bufferValue)
 		}
 	}
-	return string(s[:])
+	return string(s[:]), off
 }
 
 func Encipher(txt string) ([]byte, int) {
@@ -208,17 +208,23 @@ func (s *Scripts) Tick(ctx context.Context) {
 type sigFin chan struct{}
 
 func (s *Scripts) ForEach(ctx context.Context, arg interface{}) {
-	var list = s.ScriptCalls[:0]
+	// scripts can add tasks while these run, so the ones that are kept go in a list of their own
+	s.RLock()
+	scripts := s.ScriptCalls
+	s.RUnlock()
+	var list ScriptCalls
 	tickCtx, cancel := context.WithTimeout(ctx, 640*time.Millisecond)
 	done := make(sigFin)
-	s.RLock()
 	// log.Debug(tickCtx.Value("server"))
 	go func(ctx context.Context) {
+		defer close(done)
 		defer cancel()
-		for _, script := range s.ScriptCalls {
+		for i, script := range scripts {
 			select {
 			case <-ctx.Done():
 				log.Debug("Task scheduling context reached timeout with the error value:", ctx.Err())
+				// whatever didn't get its turn gets one next tick
+				list = append(list, scripts[i:]...)
 				return
 			default:
 				if script == nil {
@@ -272,13 +278,11 @@ func (s *Scripts) ForEach(ctx context.Context, arg interface{}) {
 				}
 		}
 	}
-		close(done)
 	}(tickCtx)
-	s.RUnlock()
 	<-done
 	s.Lock()
 	defer s.Unlock()
-	s.ScriptCalls = list
+	s.ScriptCalls = append(list, s.ScriptCalls[len(scripts):]...)
 	Ticks.Inc()
 	// wait.Wait()
 }
@@ -7,49 +7,317 @@
  *
  */
 
+//Package ipThrottle counts failed attempts at something, e.g logging in, for each key they came from, e.g an IP address
+// or an account, and locks out keys that fail too often.
 package ipThrottle
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"net"
+	"os"
+	"sync"
 	"time"
 
-	"github.com/spkaeros/rscgo/pkg/strutil"
+	"github.com/spkaeros/rscgo/pkg/log"
 )
 
-type ipThrottle map[string][]time.Time
+//Policy How many failures a throttle allows, and how long it locks out the keys that go over.
+type Policy struct {
+	//Attempts How many failures each key gets within Window before it is locked out.  0 never locks anything out.
+	Attempts int
+	//Window How long failures count against their key.
+	Window time.Duration
+	//Lockout How long the first lockout of a key lasts.  Every lockout after that lasts twice as long as the one
+	// before, up to MaxLockout; once a key goes MaxLockout without failing, it starts again from Lockout.
+	Lockout, MaxLockout time.Duration
+}
+
+//Bucket What a throttle knows about one key.
+type Bucket struct {
+	Failures []time.Time
+	//Lockouts How many times in a row the key has been locked out, which decides how long the next lockout lasts.
+	Lockouts int
+	Until    time.Time
+	//Updated When this bucket last changed.  Throttles that share a file keep whichever copy of a bucket is newer.
+	Updated time.Time
+}
+
+//NetworkThrottle Counts failed attempts for each key, and locks out keys that fail too often.  It is safe to use from
+// multiple goroutines.
+type NetworkThrottle interface {
+	//Add Records a failed attempt from key, and locks it out if that is one too many.
+	Add(key string)
+	//Recent Returns how many failed attempts from key are on record within the past timeFrame.
+	Recent(key string, timeFrame time.Duration) int
+	//Locked Returns how much longer key is locked out for, or 0 if it is not.
+	Locked(key string) time.Duration
+	//Reset Forgets every failure from key, and lifts any lockout on it.  Returns true if there was anything to forget.
+	Reset(key string) bool
+	//Save Writes every key to the file that the throttle persists to, if it has one.
+	Save() error
+}
+
+//Throttle The NetworkThrottle implementation.  It is kept in memory, and optionally saved to a file.
+type Throttle struct {
+	sync.Mutex
+	policy  Policy
+	buckets map[string]*Bucket
+	swept   time.Time
+	// file is empty unless Persist was called, and modified is what it looked like when it was last read or written
+	file     string
+	modified os.FileInfo
+	saving   sync.Mutex
+}
+
+//New Returns a new throttle that enforces policy.
+func New(policy Policy) *Throttle {
+	if policy.MaxLockout < policy.Lockout {
+		policy.MaxLockout = policy.Lockout
+	}
+	return &Throttle{policy: policy, buckets: make(map[string]*Bucket), swept: time.Now()}
+}
 
-func (l ipThrottle) Add(ip string) {
-	l[net.ParseIP(ip).String()] = append(l[strutil.IPToInteger(ip).String()], time.Now())
+//NewThrottle Returns a new throttle that only counts failures, keeping them for an hour, and never locks anything out.
+func NewThrottle() NetworkThrottle {
+	return New(Policy{Window: time.Hour})
 }
 
-//Recent returns the number of entries that match the provided IP which were added within the past specified timeFrame
-func (l ipThrottle) Recent(ip string, timeFrame time.Duration) int {
-	valid := 0
-	var removing []string
-	if attempts, ok := l[strutil.IPToInteger(ip).String()]; ok {
-		for _, v := range attempts {
-			if time.Since(v) < timeFrame {
-				valid++
-				continue
-			}
-			removing = append(removing, ip)
+//normalize Returns key in the form it is kept in.  IP addresses can be written more than one way, so they get parsed.
+func normalize(key string) string {
+	if ip := net.ParseIP(key); ip != nil {
+		return ip.String()
+	}
+	return key
+}
+
+//prune Drops the failures that are older than window.
+func (b *Bucket) prune(now time.Time, window time.Duration) {
+	kept := b.Failures[:0]
+	for _, failure := range b.Failures {
+		if now.Sub(failure) < window {
+			kept = append(kept, failure)
 		}
-		for i := range removing {
-			if i == len(attempts) {
-				l[strutil.IPToInteger(ip).String()] = attempts[:i]
-			} else {
-				l[strutil.IPToInteger(ip).String()] = append(attempts[:i], attempts[i+1:]...)
-			}
+	}
+	b.Failures = kept
+}
+
+//lockout Returns how long the lockout after lockouts earlier ones in a row lasts.
+func (t *Throttle) lockout(lockouts int) time.Duration {
+	length := t.policy.Lockout
+	for i := 0; i < lockouts && length < t.policy.MaxLockout; i++ {
+		length *= 2
+	}
+	if length > t.policy.MaxLockout {
+		return t.policy.MaxLockout
+	}
+	return length
+}
+
+func (t *Throttle) Add(key string) {
+	key = normalize(key)
+	t.refresh()
+	now := time.Now()
+	t.Lock()
+	b, ok := t.buckets[key]
+	if !ok {
+		b = &Bucket{}
+		t.buckets[key] = b
+	}
+	b.prune(now, t.policy.Window)
+	if b.Lockouts > 0 && now.Sub(b.Until) >= t.policy.MaxLockout {
+		b.Lockouts = 0
+	}
+	b.Failures = append(b.Failures, now)
+	b.Updated = now
+	if t.policy.Attempts > 0 && len(b.Failures) >= t.policy.Attempts {
+		b.Until = now.Add(t.lockout(b.Lockouts))
+		b.Lockouts++
+		b.Failures = nil
+	}
+	t.sweep(now)
+	persist := len(t.file) > 0
+	t.Unlock()
+	if persist {
+		go t.saveLater()
+	}
+}
+
+func (t *Throttle) Recent(key string, timeFrame time.Duration) int {
+	t.refresh()
+	t.Lock()
+	defer t.Unlock()
+	b, ok := t.buckets[normalize(key)]
+	if !ok {
+		return 0
+	}
+	recent := 0
+	for _, failure := range b.Failures {
+		if time.Since(failure) < timeFrame {
+			recent++
 		}
 	}
-	return valid
+	return recent
 }
 
-type NetworkThrottle interface {
-	Recent(string, time.Duration) int
-	Add(string)
+func (t *Throttle) Locked(key string) time.Duration {
+	t.refresh()
+	t.Lock()
+	defer t.Unlock()
+	if b, ok := t.buckets[normalize(key)]; ok {
+		if left := time.Until(b.Until); left > 0 {
+			return left
+		}
+	}
+	return 0
 }
 
-func NewThrottle() NetworkThrottle {
-	return make(ipThrottle)
+func (t *Throttle) Reset(key string) bool {
+	key = normalize(key)
+	t.refresh()
+	t.Lock()
+	b, ok := t.buckets[key]
+	ok = ok && (len(b.Failures) > 0 || b.Lockouts > 0)
+	persist := len(t.file) > 0
+	if persist {
+		// left behind empty rather than deleted, so that it replaces the old bucket in the file as well
+		t.buckets[key] = &Bucket{Updated: time.Now()}
+	} else {
+		delete(t.buckets, key)
+	}
+	t.Unlock()
+	if ok && persist {
+		go t.saveLater()
+	}
+	return ok
+}
+
+//sweep Forgets the keys that have nothing left that counts against them, at most once per Window.
+// The caller must hold the lock.
+func (t *Throttle) sweep(now time.Time) {
+	if now.Sub(t.swept) < t.policy.Window {
+		return
+	}
+	t.swept = now
+	for key, b := range t.buckets {
+		b.prune(now, t.policy.Window)
+		if len(b.Failures) == 0 && (b.Lockouts == 0 || now.Sub(b.Until) >= t.policy.MaxLockout) {
+			delete(t.buckets, key)
+		}
+	}
+}
+
+//Persist Loads every key saved in file, if it exists, and saves them all back to it whenever anything changes, and
+// whenever Save is called.  More than one throttle, even in different processes, can persist to the same file; each
+// one picks up what the others saved before it is next used.
+func (t *Throttle) Persist(file string) error {
+	buckets, info, err := load(file)
+	if err != nil {
+		return err
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.merge(buckets)
+	t.file, t.modified = file, info
+	return nil
+}
+
+//load Returns the buckets saved in file, and what the file looked like when they were read.  A file that does not
+// exist has no buckets in it.
+func load(file string) (map[string]*Bucket, os.FileInfo, error) {
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	buckets := make(map[string]*Bucket)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &buckets); err != nil {
+			return nil, nil, err
+		}
+	}
+	return buckets, info, nil
+}
+
+//changed Returns true if the file is not what info says that it was.
+func changed(info, now os.FileInfo) bool {
+	if info == nil || now == nil {
+		return info != now
+	}
+	return !info.ModTime().Equal(now.ModTime()) || info.Size() != now.Size()
+}
+
+//refresh Picks up whatever was saved to the throttles file since it was last read or written.
+func (t *Throttle) refresh() {
+	t.Lock()
+	file, modified := t.file, t.modified
+	t.Unlock()
+	if len(file) == 0 {
+		return
+	}
+	if info, err := os.Stat(file); err == nil && !changed(modified, info) {
+		return
+	}
+	buckets, info, err := load(file)
+	if err != nil {
+		log.Warn("Could not reload throttle from", file, ":", err)
+		return
+	}
+	t.Lock()
+	t.merge(buckets)
+	t.modified = info
+	t.Unlock()
+}
+
+//merge Takes every bucket from buckets that is newer than the one kept for the same key.
+// The caller must hold the lock.
+func (t *Throttle) merge(buckets map[string]*Bucket) {
+	for key, b := range buckets {
+		if ours, ok := t.buckets[key]; !ok || b.Updated.After(ours.Updated) {
+			t.buckets[key] = b
+		}
+	}
+}
+
+//saveLater Saves the throttle from its own goroutine, where nothing is waiting around to hear about errors.
+func (t *Throttle) saveLater() {
+	if err := t.Save(); err != nil {
+		log.Warn("Could not save throttle to", t.file, ":", err)
+	}
+}
+
+func (t *Throttle) Save() error {
+	t.saving.Lock()
+	defer t.saving.Unlock()
+	// whatever else saved to the file since we last looked has to go back in with ours
+	t.refresh()
+	t.Lock()
+	file := t.file
+	data, err := json.Marshal(t.buckets)
+	t.Unlock()
+	if len(file) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// written aside then moved over, so that a crash part way through can't leave half a file behind
+	if err := ioutil.WriteFile(file+".tmp", data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		return err
+	}
+	if info, err := os.Stat(file); err == nil {
+		t.Lock()
+		t.modified = info
+		t.Unlock()
+	}
+	return nil
 }
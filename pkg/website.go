@@ -16,6 +16,8 @@ import (
 
 	"github.com/spkaeros/rscgo/pkg/config"
 	"github.com/spkaeros/rscgo/pkg/db"
+	"github.com/spkaeros/rscgo/pkg/game/net/handshake"
+	"github.com/spkaeros/rscgo/pkg/game/world"
 	"github.com/spkaeros/rscgo/pkg/log"
 	"github.com/spkaeros/rscgo/pkg/website"
 )
@@ -47,8 +49,11 @@ func main() {
 	config.TomlConfig.Crypto.HashLength = 32
 	config.TomlConfig.Crypto.HashMemory = 8
 	config.TomlConfig.Crypto.HashSalt = "rscgo./GOLANG!RULES/.1994"
-	config.TomlConfig.Version = 204
+	config.TomlConfig.Version = 235
 	config.TomlConfig.Port = 43595 // +1 for websockets
+	config.TomlConfig.Timeouts.Query = 5
+	config.TomlConfig.Timeouts.Load = 10
+	config.TomlConfig.Timeouts.Save = 30
 
 	// if _, err := flags.Parse(cliFlags); err != nil {
 	// log.Warn("Error parsing command arguments:", cliFlags)
@@ -66,7 +71,11 @@ func main() {
 	// return
 	// }
 
-	// TODO: data backend default to JSON or BSON maybe?
+	// the recovery throttles are shared with the game server, so they have to be kept in the same place it keeps them
+	if _, err := toml.DecodeFile("config.toml", &config.TomlConfig); err != nil {
+		log.Warn("Error reading config.toml, carrying on with the defaults:", err)
+	}
+
 	config.TomlConfig.Database.PlayerDriver = "sqlite3"
 	config.TomlConfig.Database.PlayerDB = "file:./data/players.db"
 	config.TomlConfig.Database.WorldDriver = "sqlite3"
@@ -77,7 +86,9 @@ func main() {
 	}
 
 	run(db.ConnectEntityService, func() {
-		db.DefaultPlayerService = db.NewPlayerServiceSql()
+		db.DefaultPlayerService = db.NewPlayerService()
+		world.DefaultBanService = db.NewBanService()
+		handshake.ConfigureThrottles()
 	})
 	website.Start()
 }
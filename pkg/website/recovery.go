@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2020 Zachariah Knight <aeros.storkpk@gmail.com>
+ *
+ * Permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted, provided that the above copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ *
+ */
+
+package website
+
+import (
+	"html/template"
+	stdnet "net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spkaeros/rscgo/pkg/db"
+	"github.com/spkaeros/rscgo/pkg/log"
+	"github.com/spkaeros/rscgo/pkg/proxy"
+	"github.com/spkaeros/rscgo/pkg/strutil"
+)
+
+//recoveryPage The data that the account recovery page is rendered with.
+type recoveryPage struct {
+	InformationData
+	Username  string
+	Questions []string
+	Message   string
+}
+
+//bindAccountRecovery Serves the account recovery page, which does the same thing as the forgotten password screen of
+// the game client; asks the recovery questions of an account, and sets a new password if they are answered right.
+func bindAccountRecovery() {
+	muxCtx.HandleFunc("/recover.ws", func(w http.ResponseWriter, r *http.Request) {
+		page := recoveryPage{InformationData: Information, Username: strings.TrimSpace(r.FormValue("username"))}
+		if len(page.Username) > 0 {
+			userHash := strutil.Base37.Encode(page.Username)
+			var result db.RecoveryResult
+			page.Questions, result = db.RecoveryQuestions(r.Context(), userHash, clientIP(r))
+			if result == db.RecoveryThrottled {
+				page.Message = "There have been too many wrong answers from your address recently.  Try again later."
+			} else if len(page.Questions) == 0 {
+				page.Message = "That account does not have any recovery questions set."
+			} else if r.Method == http.MethodPost {
+				var recovered bool
+				if page.Message, recovered = recoverAccount(r, userHash); recovered {
+					page.Questions = nil
+				}
+			}
+		}
+
+		tmpl, ok := pageTemplates["/recover.html"]
+		if !ok {
+			var err error
+			tmpl, err = template.ParseFiles(filepath.Join("website/layouts", "layout.html"), filepath.Join("website", "recover.html"))
+			if err != nil {
+				log.Warn(err.Error())
+				http.Error(w, http.StatusText(500), 500)
+				return
+			}
+			pageTemplates["/recover.html"] = tmpl
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.ExecuteTemplate(w, "layout", page); err != nil {
+			log.Warn("Problem encountered executing a webpage template:", err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+}
+
+//recoverAccount Tries to recover the account with the provided username hash using the answers posted in r, and
+// returns a message for the page describing how that went, and true if the password was changed.
+func recoverAccount(r *http.Request, userHash uint64) (string, bool) {
+	password := r.PostFormValue("password")
+	if password != r.PostFormValue("confirm") {
+		return "The new passwords you entered do not match.", false
+	}
+	answers := make([]uint64, 5)
+	for i := range answers {
+		// the game client sends each answer base37 hashed, so that is what they are saved as
+		answers[i] = strutil.Base37.Encode(strings.TrimSpace(r.PostFormValue("answer" + strconv.Itoa(i))))
+	}
+	switch db.RecoverAccount(r.Context(), userHash, answers, password, clientIP(r)) {
+	case db.RecoverySuccess:
+		return "Success!  Your password has been changed, and you can now log in with it.", true
+	case db.RecoveryThrottled:
+		return "There have been too many wrong answers from your address, or for this account, recently.  Try again later.", false
+	case db.RecoveryBadPassword:
+		return "Your new password must be 5-20 characters long.", false
+	default:
+		return "Those answers are not right.  Try again.", false
+	}
+}
+
+//clientIP Returns the IP address that r came from, which is where a trusted proxy says it came from if it went through
+// one.
+func clientIP(r *http.Request) string {
+	ip, _, err := stdnet.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if proxy.Trusted(ip) {
+		if forwarded := proxy.ForwardedFor(strings.Join(r.Header["X-Forwarded-For"], ",")); forwarded != nil {
+			return forwarded.String()
+		}
+	}
+	return ip
+}
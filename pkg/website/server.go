@@ -10,6 +10,7 @@
 package website
 
 import (
+	"context"
 	"html/template"
 	"mime"
 	"net/http"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/spkaeros/rscgo/pkg/log"
 	"github.com/spkaeros/rscgo/pkg/db"
+	"github.com/spkaeros/rscgo/pkg/game/world"
 )
 
 var muxCtx = http.NewServeMux()
@@ -43,7 +45,16 @@ func (s InformationData) ToLower(s2 string) string {
 }
 
 func (s InformationData) OnlineCount() int {
-	return db.DefaultPlayerService.OnlineCount()
+	return db.DefaultPlayerService.OnlineCount(context.Background())
+}
+
+//Bans Returns every ban that has not expired yet, newest first.
+func (s InformationData) Bans() []*world.Ban {
+	if world.DefaultBanService == nil {
+		return nil
+	}
+	bans, _ := world.DefaultBanService.BanList(context.Background())
+	return bans
 }
 
 //writeContent is a helper function to write to a http.ResponseWriter easily with error handling
@@ -141,6 +152,7 @@ func Start() {
 	// muxCtx.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./data/client"))))
 	muxCtx.Handle("/game/static/", http.StripPrefix("/game/static/", http.FileServer(http.Dir("./website/game"))))
 	bindGameProcManager()
+	bindAccountRecovery()
 	if err := http.ListenAndServe(":8080", muxCtx); err != nil {
 		log.Error.Println("Could not bind to website port:", err)
 		os.Exit(99)
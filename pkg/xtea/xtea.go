@@ -41,3 +41,29 @@ func (x *Xteakeys) Decrypt(in []byte) []byte {
 	}
 	return out
 }
+
+//Encrypt Takes a block of plaintext as input, and encrypts it using the stored keys.
+// This is the inverse of Decrypt, including leaving any trailing bytes past the closest multiple of 8 as they are.
+func (x *Xteakeys) Encrypt(in []byte) []byte {
+	out := make([]byte, len(in))
+	blocks := len(in) >> 3
+
+	i := 0
+	for ; i < blocks; i++ {
+		word1 := binary.BigEndian.Uint32(in[i<<3:])
+		word2 := binary.BigEndian.Uint32(in[i<<3+4:])
+		sum := uint32(0)
+
+		for j := 0; j < 1<<5; j++ {
+			word1 += (((word2 << 4) ^ (word2 >> 5)) + word2) ^ (sum + uint32(x.keys[sum & 3]))
+			sum += phi
+			word2 += (((word1 << 4) ^ (word1 >> 5)) + word1) ^ (sum + uint32(x.keys[(sum >> 11) & 3]))
+		}
+		binary.BigEndian.PutUint32(out[i<<3:], word1)
+		binary.BigEndian.PutUint32(out[i<<3+4:], word2)
+	}
+	for i <<= 3; i < len(in); i++ {
+		out[i] = in[i]
+	}
+	return out
+}